@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyPlan bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <name>",
+	Short: "Apply VM resource changes from agentbox.yaml in place",
+	Long: `Apply changes to vm.cpus, vm.memory and vm.disk without a reset.
+
+The VM as it was created (.agentbox/lima.yaml) is compared with
+agentbox.yaml. CPUs and memory are changed in place and the disk is grown;
+a running VM is stopped for the change and started again, so apply refuses
+while an 'agentbox enter' or 'agentbox run' session is open. Anything that
+can't be changed in place (VM type, arch, images, mounts, a smaller disk)
+is listed with the reason, to be applied with 'agentbox reset'.
+
+Example:
+  agentbox apply myproject --plan   # Only show the changes
+  agentbox apply myproject`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApply,
+}
+
+func init() {
+	applyCmd.Flags().BoolVar(&applyPlan, "plan", false, "only show what would change")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return applyConfig(cmd.Context(), name, cfg, applyPlan)
+}
+
+// applyConfig shows how cfg differs from the box and, unless planOnly,
+// makes the changes that don't need a reset
+func applyConfig(ctx context.Context, name string, cfg *config.Config, planOnly bool) error {
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rt, err := newRuntime(cfg)
+	if err != nil {
+		return err
+	}
+	vmName := rt.InstanceName(name)
+
+	status, err := rt.Status(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check VM status: %w", err)
+	}
+	if status == runtime.StatusNotCreated {
+		return fmt.Errorf("VM %q does not exist. Run 'agentbox create %s' first", vmName, name)
+	}
+
+	spec := runtime.Spec{Name: name, ProjectDir: absPath, Config: cfg}
+	changes, err := rt.Plan(ctx, spec)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("VM %s matches agentbox.yaml, nothing to apply\n", vmName)
+		return nil
+	}
+
+	fmt.Printf("Changes for %s:\n", vmName)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var needReset []string
+	for _, c := range changes {
+		how := "in place"
+		if !c.InPlace {
+			how = "needs reset: " + c.Reason
+			needReset = append(needReset, c.Field)
+		}
+		fmt.Fprintf(w, "  %s\t%s -> %s\t(%s)\n", c.Field, c.From, c.To, how)
+	}
+	w.Flush()
+
+	if planOnly {
+		return nil
+	}
+
+	res := runtime.InPlaceResources(changes)
+	if res == (runtime.Resources{}) {
+		fmt.Printf("\nNothing can be changed in place. Run 'agentbox reset %s' to apply the rest.\n", name)
+		return nil
+	}
+
+	running := status == runtime.StatusRunning
+	if running {
+		// Stopping the VM would kill every enter and run in progress
+		pids, err := session.Active(session.Dir(filepath.Join(absPath, ".agentbox")))
+		if err != nil {
+			return fmt.Errorf("failed to list running sessions: %w", err)
+		}
+		if len(pids) > 0 {
+			return fmt.Errorf("VM %s has %d running session(s) (pids %v); exit them and run 'agentbox apply %s' again", vmName, len(pids), pids, name)
+		}
+
+		if err := rt.WipeSecrets(ctx, name); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		fmt.Printf("\nStopping VM: %s\n", vmName)
+		if err := rt.Stop(ctx, name); err != nil {
+			return fmt.Errorf("failed to stop VM: %w", err)
+		}
+	}
+
+	fmt.Printf("Resizing VM: %s\n", vmName)
+	if err := rt.Resize(ctx, spec, res); err != nil {
+		return fmt.Errorf("failed to resize VM: %w", err)
+	}
+
+	if running {
+		fmt.Printf("Starting VM: %s\n", vmName)
+		startErr := rt.Start(ctx, name)
+		reportProvisioning(ctx, rt, name, filepath.Join(absPath, ".agentbox"), startErr)
+		if startErr != nil {
+			return fmt.Errorf("failed to start VM: %w", startErr)
+		}
+	}
+
+	fmt.Printf("\nVM resized successfully\n")
+	if len(needReset) > 0 {
+		fmt.Printf("Still different: %s. Run 'agentbox reset %s' to apply them.\n", strings.Join(needReset, ", "), name)
+	}
+	return nil
+}
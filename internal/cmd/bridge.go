@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/davidsenack/agentbox/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bridgeSocket string
+	bridgeListen string
+	bridgeTTY    bool
+)
+
+// sandboxBridgeCmd runs inside bwrap sandboxes, which only have loopback:
+// it serves the proxy port there by forwarding to the host's unix socket
+var sandboxBridgeCmd = &cobra.Command{
+	Use:    "sandbox-bridge --socket <path> --listen <addr> -- <command> [args...]",
+	Short:  "Forward a sandbox's proxy port to the host (internal)",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE:   runSandboxBridge,
+}
+
+func init() {
+	sandboxBridgeCmd.Flags().StringVar(&bridgeSocket, "socket", "", "unix socket connected to the host proxy")
+	sandboxBridgeCmd.Flags().StringVar(&bridgeListen, "listen", "127.0.0.1:3128", "address to serve the proxy on")
+	sandboxBridgeCmd.Flags().BoolVar(&bridgeTTY, "tty", false, "make stdin (a pty) the command's controlling terminal")
+	sandboxBridgeCmd.MarkFlagRequired("socket")
+}
+
+func runSandboxBridge(cmd *cobra.Command, args []string) error {
+	ln, err := net.Listen("tcp", bridgeListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", bridgeListen, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.Bridge(ctx, ln, func() (net.Conn, error) {
+		return net.Dial("unix", bridgeSocket)
+	})
+
+	// The child owns the terminal; don't let ^C take the bridge down first
+	// (Notify rather than Ignore, so the child still gets default handlers)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGQUIT)
+	defer signal.Stop(sigCh)
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	if bridgeTTY {
+		// bwrap --new-session left us without a terminal; claim the pty
+		child.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	}
+	err = child.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cancel()
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
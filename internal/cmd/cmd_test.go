@@ -0,0 +1,952 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/session"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// setupCLI runs the test in a scratch directory against a fresh fake runtime
+func setupCLI(t *testing.T) *runtime.Fake {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	t.Setenv("AGENTBOX_CONFIG_DIR", t.TempDir())
+	t.Setenv("AGENTBOX_RUNTIME", "fake")
+	// Keep gh/gt/limactl on the host out of reach
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	fake := runtime.NewFake()
+	runtime.Register("fake", fake.Factory)
+	return fake
+}
+
+// runCLI executes agentbox with args and returns what it printed to stdout
+func runCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&out, r)
+		close(done)
+	}()
+
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	err = rootCmd.Execute()
+
+	w.Close()
+	<-done
+	os.Stdout = stdout
+	resetFlags(rootCmd)
+	return out.String(), err
+}
+
+// resetFlags restores flag defaults, which cobra keeps between executions
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		// Slice flags append on Set, and their DefValue is "[]"
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			v.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// createProject creates a project and points its proxy at a free port
+func createProject(t *testing.T, name string) {
+	t.Helper()
+
+	if _, err := runCLI(t, "create", name); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Network.ProxyPort = 0
+	if err := config.Save(name, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCLICreate(t *testing.T) {
+	fake := setupCLI(t)
+
+	out, err := runCLI(t, "create", "demo")
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if !strings.Contains(out, "Creating VM: agentbox-demo") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	for _, path := range []string{"demo/agentbox.yaml", "demo/workspace", "demo/artifacts", "demo/.agentbox"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s: %v", path, err)
+		}
+	}
+
+	box, ok := fake.Box("demo")
+	if !ok {
+		t.Fatal("box was not created")
+	}
+	if box.Running {
+		t.Error("new box should be stopped")
+	}
+	abs, _ := filepath.Abs("demo")
+	if box.Spec.ProjectDir != abs || box.Spec.Config == nil {
+		t.Errorf("unexpected spec: %+v", box.Spec)
+	}
+
+	if _, err := runCLI(t, "create", "demo"); err == nil {
+		t.Error("creating an existing project should fail")
+	}
+}
+
+func TestCLICreateProfile(t *testing.T) {
+	fake := setupCLI(t)
+
+	out, err := runCLI(t, "create", "ml", "--profile", "python-ml")
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if !strings.Contains(out, "Using profile python-ml") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	cfg, err := config.Load("ml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Toolchains.Python != "3.12" || len(cfg.Provision.Pip) == 0 {
+		t.Errorf("profile not written to agentbox.yaml: %+v", cfg)
+	}
+	if box, ok := fake.Box("ml"); !ok || box.Spec.Config.Toolchains.Python != "3.12" {
+		t.Error("box should be created from the profile")
+	}
+
+	if _, err := runCLI(t, "create", "bad", "--profile", "cobol"); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("expected unknown profile error, got %v", err)
+	}
+	if _, err := os.Stat("bad"); !os.IsNotExist(err) {
+		t.Error("a bad profile should not leave a project behind")
+	}
+}
+
+func TestCLIEnter(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test-key-value")
+
+	out, err := runCLI(t, "enter", "demo")
+	if err != nil {
+		t.Fatalf("enter failed: %v", err)
+	}
+	if !strings.Contains(out, "Starting VM: agentbox-demo") || !strings.Contains(out, "Exited AgentBox") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	calls := strings.Join(fake.Calls(), ",")
+	// A fresh boot fetches the provisioning logs before the session
+	want := "Start demo,CopyFrom demo,CopyFrom demo,InstallCA demo,InjectSecrets demo,Shell demo,WipeSecrets demo"
+	if !strings.Contains(calls, want) {
+		t.Errorf("calls = %s, want sequence %s", calls, want)
+	}
+
+	box, _ := fake.Box("demo")
+	if !box.Running {
+		t.Error("box should still be running after the shell exits")
+	}
+	if len(box.Secrets) != 0 {
+		t.Errorf("secrets should be wiped after the last session, got %v", box.Secrets)
+	}
+}
+
+func TestCLIEnterDeliversSecrets(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test-key-value")
+
+	var during map[string]string
+	fake.ShellFunc = func(project string) error {
+		box, _ := fake.Box(project)
+		during = box.Secrets
+		return nil
+	}
+
+	if _, err := runCLI(t, "enter", "demo"); err != nil {
+		t.Fatalf("enter failed: %v", err)
+	}
+	if during["ANTHROPIC_API_KEY"] != "sk-ant-test-key-value" {
+		t.Errorf("secrets during the session = %v", during)
+	}
+}
+
+func TestCLIEnterMissingVM(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	if err := fake.Delete(t.Context(), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runCLI(t, "enter", "demo")
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing VM error, got %v", err)
+	}
+}
+
+func TestCLIExec(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test-key-value")
+
+	var (
+		gotCommand []string
+		gotOpts    runtime.ExecOptions
+		during     map[string]string
+	)
+	fake.ExecFunc = func(project string, command []string, opts runtime.ExecOptions) error {
+		gotCommand, gotOpts = command, opts
+		box, _ := fake.Box(project)
+		during = box.Secrets
+		io.WriteString(opts.Stdout, "hello\n")
+		return &runtime.ExitError{Code: 3}
+	}
+
+	out, err := runCLI(t, "exec", "demo", "--workdir", "/workspace/api", "--env", "CI=1", "--", "make", "-j4", "test")
+	var exitErr *runtime.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("err = %v, want exit status 3", err)
+	}
+	// agentbox's own messages stay off stdout
+	if out != "hello\n" {
+		t.Errorf("stdout = %q, want only the command's output", out)
+	}
+	if strings.Join(gotCommand, " ") != "make -j4 test" {
+		t.Errorf("command = %v", gotCommand)
+	}
+	if gotOpts.Dir != "/workspace/api" || strings.Join(gotOpts.Env, ",") != "CI=1" || gotOpts.TTY {
+		t.Errorf("options = %+v", gotOpts)
+	}
+	if during["ANTHROPIC_API_KEY"] != "sk-ant-test-key-value" {
+		t.Errorf("secrets during the command = %v", during)
+	}
+
+	calls := strings.Join(fake.Calls(), ",")
+	want := "InstallCA demo,InjectSecrets demo,Exec demo,WipeSecrets demo"
+	if !strings.Contains(calls, want) {
+		t.Errorf("calls = %s, want sequence %s", calls, want)
+	}
+}
+
+func TestCLIExecArgs(t *testing.T) {
+	setupCLI(t)
+	createProject(t, "demo")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no command", []string{"exec", "demo"}, "requires at least 2 arg(s)"},
+		{"name after dash", []string{"exec", "--", "demo", "ls"}, "missing <name>"},
+		{"extra before dash", []string{"exec", "demo", "ls", "--", "-l"}, `unexpected argument "ls"`},
+		{"malformed env", []string{"exec", "demo", "--env", "CI", "--", "ls"}, "use KEY=VALUE"},
+		{"blocked env", []string{"exec", "demo", "--env", "GITHUB_TOKEN=x", "--", "ls"}, "blocked by built-in pattern"},
+		{"missing project", []string{"exec", "other", "--", "ls"}, "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runCLI(t, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCLIRun(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	if err := os.WriteFile(filepath.Join("demo", "workspace", "old.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("task.md", []byte("Write a report"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotCommand []string
+	var gotPrompt string
+	t.Setenv("ANTHROPIC_API_KEY", "plain-host-value-42")
+	fake.ExecFunc = func(project string, command []string, opts runtime.ExecOptions) error {
+		gotCommand = command
+		prompt, _ := io.ReadAll(opts.Stdin)
+		gotPrompt = string(prompt)
+		io.WriteString(opts.Stdout, "working on it\n")
+		// Split across writes, as streamed output may be
+		io.WriteString(opts.Stderr, "key=plain-host")
+		io.WriteString(opts.Stderr, "-value-42\n")
+		// Mounts are shared with the host, so write through them
+		os.Remove(filepath.Join("demo", "workspace", "old.txt"))
+		os.WriteFile(filepath.Join("demo", "workspace", "main.go"), []byte("package main"), 0644)
+		os.WriteFile(filepath.Join("demo", "artifacts", "report.md"), []byte("# Report"), 0644)
+		return nil
+	}
+
+	out, err := runCLI(t, "run", "demo", "--prompt-file", "task.md", "--timeout", "90s", "--stop", "--result", "result.json")
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if out != "working on it\n" {
+		t.Errorf("stdout = %q, want only the transcript", out)
+	}
+	if strings.Join(gotCommand, " ") != "timeout --kill-after=30s 90s claude -p --dangerously-skip-permissions" {
+		t.Errorf("command = %v", gotCommand)
+	}
+	if gotPrompt != "Write a report" {
+		t.Errorf("prompt = %q", gotPrompt)
+	}
+
+	data, err := os.ReadFile("result.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result runResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("invalid result: %v\n%s", err, data)
+	}
+	if result.Status != runSucceeded || result.ExitCode != 0 || result.Timeout != "1m30s" {
+		t.Errorf("result = %+v", result)
+	}
+	if strings.Join(result.FilesChanged.Added, ",") != "/artifacts/report.md,/workspace/main.go" ||
+		strings.Join(result.FilesChanged.Deleted, ",") != "/workspace/old.txt" {
+		t.Errorf("files changed = %+v", result.FilesChanged)
+	}
+	if strings.Join(result.Artifacts, ",") != "report.md" {
+		t.Errorf("artifacts = %v", result.Artifacts)
+	}
+
+	runDir := filepath.Dir(result.Transcript)
+	transcript, err := os.ReadFile(result.Transcript)
+	if err != nil || string(transcript) != "working on it\nkey=[REDACTED:ANTHROPIC_API_KEY]\n" {
+		t.Errorf("transcript = %q, %v", transcript, err)
+	}
+	if _, err := os.Stat(filepath.Join(runDir, "artifacts", "report.md")); err != nil {
+		t.Errorf("artifact not collected: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(runDir, "result.json")); err != nil {
+		t.Errorf("result not saved in the run directory: %v", err)
+	}
+
+	box, _ := fake.Box("demo")
+	if box.Running {
+		t.Error("--stop should stop the VM after the run")
+	}
+	if len(box.Secrets) != 0 {
+		t.Errorf("secrets should be wiped after the run, got %v", box.Secrets)
+	}
+}
+
+func TestCLIRunFailure(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	fake.ExecFunc = func(project string, command []string, opts runtime.ExecOptions) error {
+		return &runtime.ExitError{Code: 2}
+	}
+
+	_, err := runCLI(t, "run", "demo", "--agent", "opencode", "--prompt", "Fix it", "--result", "result.json")
+	var exitErr *runtime.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("err = %v, want exit status 2", err)
+	}
+
+	data, err := os.ReadFile("result.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result runResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != runFailed || result.ExitCode != 2 || result.Agent != "opencode" {
+		t.Errorf("result = %+v", result)
+	}
+
+	box, _ := fake.Box("demo")
+	if !box.Running {
+		t.Error("the VM should keep running without --stop")
+	}
+
+	// A run that can't start its sandbox leaves nothing behind
+	if err := os.RemoveAll(filepath.Join("demo", ".agentbox", "runs")); err != nil {
+		t.Fatal(err)
+	}
+	fake.Errors = map[string]error{"Start": errors.New("boot failed")}
+	fake.Stop(context.Background(), "demo")
+	if _, err := runCLI(t, "run", "demo", "--prompt", "Fix it"); err == nil {
+		t.Error("run should fail when the VM can't start")
+	}
+	if _, err := os.Stat(filepath.Join("demo", ".agentbox", "runs")); !os.IsNotExist(err) {
+		t.Errorf("a failed start should not create a run directory: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"run", "demo"},
+		{"run", "demo", "--prompt", "x", "--prompt-file", "task.md"},
+		{"run", "demo", "--prompt", "x", "--agent", "nope"},
+	} {
+		if _, err := runCLI(t, args...); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+}
+
+func TestCLIStop(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+
+	out, err := runCLI(t, "stop", "demo")
+	if err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if !strings.Contains(out, "already stopped") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	fake.Start(t.Context(), "demo")
+	if _, err := runCLI(t, "stop", "demo"); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if box, _ := fake.Box("demo"); box.Running {
+		t.Error("box should be stopped")
+	}
+}
+
+func TestCLIReset(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	fake.Start(t.Context(), "demo")
+	os.WriteFile(filepath.Join("demo", "workspace", "keep.txt"), []byte("work"), 0644)
+
+	if _, err := runCLI(t, "reset", "demo"); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+
+	calls := strings.Join(fake.Calls(), ",")
+	if !strings.Contains(calls, "Stop demo,Delete demo,Create demo") {
+		t.Errorf("calls = %s", calls)
+	}
+	if box, ok := fake.Box("demo"); !ok || box.Running {
+		t.Error("reset should leave a fresh, stopped box")
+	}
+	if _, err := os.Stat(filepath.Join("demo", "workspace", "keep.txt")); err != nil {
+		t.Error("reset must preserve the workspace")
+	}
+}
+
+func TestCLIDelete(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+
+	if _, err := runCLI(t, "delete", "demo", "--force"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if exists, _ := fake.Exists(t.Context(), "demo"); exists {
+		t.Error("box should be deleted")
+	}
+	if _, err := os.Stat("demo"); !os.IsNotExist(err) {
+		t.Error("project directory should be removed")
+	}
+}
+
+func TestCLIDeleteUnknownVM(t *testing.T) {
+	setupCLI(t)
+	createProject(t, "demo")
+
+	// If limactl fails, the VM may still exist; keep the project
+	limactl := filepath.Join(t.TempDir(), "limactl")
+	if err := os.WriteFile(limactl, []byte("#!/bin/sh\necho 'level=fatal msg=\"lima broke\"' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AGENTBOX_RUNTIME", "lima")
+	t.Setenv("AGENTBOX_LIMACTL", limactl)
+
+	_, err := runCLI(t, "delete", "demo", "--force")
+	if err == nil || !strings.Contains(err.Error(), "failed to check VM") || !strings.Contains(err.Error(), "lima broke") {
+		t.Fatalf("delete should fail when limactl does, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("demo", "agentbox.yaml")); err != nil {
+		t.Errorf("project directory must be kept: %v", err)
+	}
+}
+
+func TestCLIList(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "alpha")
+	createProject(t, "beta")
+	fake.Start(t.Context(), "beta")
+
+	out, err := runCLI(t, "list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	for _, want := range []string{"agentbox-alpha (stopped)", "agentbox-beta (running)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("list output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Every project is answered from a single listing
+	if n := strings.Count(strings.Join(fake.Calls(), ","), "Statuses"); n != 1 {
+		t.Errorf("listed the runtime %d times, want 1", n)
+	}
+}
+
+func TestCLIUnknownRuntime(t *testing.T) {
+	setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("AGENTBOX_RUNTIME", "")
+
+	cfg, _ := config.Load("demo")
+	cfg.Runtime = "nope"
+	config.Save("demo", cfg)
+
+	_, err := runCLI(t, "enter", "demo")
+	if err == nil || !strings.Contains(err.Error(), `unknown runtime "nope"`) {
+		t.Fatalf("expected unknown runtime error, got %v", err)
+	}
+}
+
+func TestCLISnapshot(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+
+	tool := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(tool, []byte("v1"), 0644)
+	fake.Start(t.Context(), "demo")
+	fake.CopyTo(t.Context(), "demo", tool, "/usr/local/bin/tool")
+
+	if _, err := runCLI(t, "snapshot", "save", "demo", "tooled"); err == nil || !strings.Contains(err.Error(), "is running") {
+		t.Fatalf("saving a running VM should fail, got %v", err)
+	}
+
+	fake.Stop(t.Context(), "demo")
+	if _, err := runCLI(t, "snapshot", "save", "demo", "tooled", "--note", "tool v1"); err != nil {
+		t.Fatalf("snapshot save failed: %v", err)
+	}
+	if _, err := runCLI(t, "snapshot", "save", "demo", "tooled"); err == nil {
+		t.Error("saving over an existing snapshot should fail")
+	}
+
+	out, err := runCLI(t, "snapshot", "list", "demo")
+	if err != nil {
+		t.Fatalf("snapshot list failed: %v", err)
+	}
+	if !strings.Contains(out, "tooled") || !strings.Contains(out, "tool v1") {
+		t.Errorf("unexpected list output:\n%s", out)
+	}
+
+	// Change the guest, then roll it back
+	os.WriteFile(tool, []byte("v2"), 0644)
+	fake.CopyTo(t.Context(), "demo", tool, "/usr/local/bin/tool")
+	if _, err := runCLI(t, "snapshot", "restore", "demo", "tooled"); err != nil {
+		t.Fatalf("snapshot restore failed: %v", err)
+	}
+	if box, _ := fake.Box("demo"); string(box.Files["/usr/local/bin/tool"]) != "v1" {
+		t.Errorf("restore left %q", box.Files["/usr/local/bin/tool"])
+	}
+
+	// reset --to recreates the VM and restores the snapshot onto it
+	if _, err := runCLI(t, "reset", "demo", "--to", "tooled"); err != nil {
+		t.Fatalf("reset --to failed: %v", err)
+	}
+	calls := strings.Join(fake.Calls(), ",")
+	if !strings.Contains(calls, "Delete demo,Create demo,RestoreSnapshot demo") {
+		t.Errorf("calls = %s", calls)
+	}
+	if box, _ := fake.Box("demo"); string(box.Files["/usr/local/bin/tool"]) != "v1" {
+		t.Errorf("reset --to left %q", box.Files["/usr/local/bin/tool"])
+	}
+
+	if _, err := runCLI(t, "snapshot", "rm", "demo", "tooled"); err != nil {
+		t.Fatalf("snapshot rm failed: %v", err)
+	}
+	if _, err := runCLI(t, "reset", "demo", "--to", "tooled"); err == nil {
+		t.Error("reset --to a missing snapshot should fail")
+	}
+	if exists, _ := fake.Exists(t.Context(), "demo"); !exists {
+		t.Error("a failed reset --to must not destroy the VM")
+	}
+}
+
+func TestCLIProvision(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("ANTHROPIC_API_KEY", "plain-host-value-42")
+
+	var gotSections []string
+	fake.ProvisionFunc = func(spec runtime.Spec, sections []string, out io.Writer) error {
+		gotSections = sections
+		io.WriteString(out, "::agentbox:: section agents\nInstalling gt v0.2.0...\nkey=plain-host-value-42\n::agentbox:: changed gt v0.2.0\n::agentbox:: failed bd v0.20.1\n")
+		return nil
+	}
+
+	out, err := runCLI(t, "provision", "demo", "--only", "agents")
+	if err == nil || !strings.Contains(err.Error(), "1 provisioning item(s) failed") {
+		t.Errorf("expected the failed item to fail the command, got %v", err)
+	}
+	if strings.Join(gotSections, ",") != "agents" {
+		t.Errorf("unexpected sections: %v", gotSections)
+	}
+	for _, want := range []string{"Starting VM", "==> agents", "Installing gt", "Changed: gt v0.2.0", "Failed:  bd v0.20.1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	log, err := os.ReadFile("demo/.agentbox/provision.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "::agentbox:: changed gt v0.2.0") {
+		t.Errorf("log should keep the raw output:\n%s", log)
+	}
+	if strings.Contains(string(log), "plain-host-value-42") || !strings.Contains(string(log), "[REDACTED:ANTHROPIC_API_KEY]") {
+		t.Errorf("host secrets should be redacted in the log:\n%s", log)
+	}
+
+	fake.ProvisionFunc = func(spec runtime.Spec, sections []string, out io.Writer) error {
+		io.WriteString(out, "::agentbox:: section steps\n")
+		return &runtime.ExitError{Code: 1}
+	}
+	if _, err := runCLI(t, "provision", "demo"); err == nil || !strings.Contains(err.Error(), "stopped in section steps") {
+		t.Errorf("expected the failing section to be named, got %v", err)
+	}
+
+	if _, err := runCLI(t, "provision", "demo", "--only", "kernel"); err == nil || !strings.Contains(err.Error(), "unknown provision section") {
+		t.Errorf("expected unknown section error, got %v", err)
+	}
+}
+
+func TestCLIStatus(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+
+	out, err := runCLI(t, "status", "demo")
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if !strings.Contains(out, "Status:    stopped") || !strings.Contains(out, "No provisioning log yet") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	// Seed the guest's step log, as the provision script would
+	stepLog := filepath.Join(t.TempDir(), "steps.jsonl")
+	os.WriteFile(stepLog, []byte(`{"section":"base","step":"agent user","status":"ok","duration_s":1,"exit_code":0}
+{"section":"agents","step":"gt v0.2.0","status":"changed","duration_s":40,"exit_code":0}
+{"section":"agents","step":"bd v0.20.1","status":"failed","duration_s":12,"exit_code":1,"stderr_tail":["go: module not found"]}
+`), 0644)
+	if err := fake.Start(t.Context(), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.CopyTo(t.Context(), "demo", stepLog, lima.GuestStepLog); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = runCLI(t, "status", "demo")
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	for _, want := range []string{"Status:    running", "3 steps (1 changed, 1 ok, 1 failed)", "FAILED  agents/bd v0.20.1 (exit 1, 12s)", "| go: module not found"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("demo", ".agentbox", lima.StepLogFile)); err != nil {
+		t.Errorf("step log should be kept in .agentbox: %v", err)
+	}
+
+	// Guest logs are redacted on the way to the host
+	t.Setenv("ANTHROPIC_API_KEY", "plain-host-value-42")
+	provisionLog := filepath.Join(t.TempDir(), "provision.log")
+	os.WriteFile(provisionLog, []byte("installing with plain-host-value-42\n"), 0644)
+	os.WriteFile(stepLog, []byte(`{"section":"base","step":"auth","status":"failed","duration_s":1,"exit_code":1,"stderr_tail":["bad key plain-host-value-42"]}
+`), 0644)
+	fake.CopyTo(t.Context(), "demo", provisionLog, lima.GuestProvisionLog)
+	fake.CopyTo(t.Context(), "demo", stepLog, lima.GuestStepLog)
+
+	out, err = runCLI(t, "status", "demo")
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if strings.Contains(out, "plain-host-value-42") || !strings.Contains(out, "bad key [REDACTED:ANTHROPIC_API_KEY]") {
+		t.Errorf("failed step output should be redacted:\n%s", out)
+	}
+	for _, file := range []string{lima.ProvisionLogFile, lima.StepLogFile} {
+		data, err := os.ReadFile(filepath.Join("demo", ".agentbox", file))
+		if err != nil || strings.Contains(string(data), "plain-host-value-42") || !strings.Contains(string(data), "[REDACTED:ANTHROPIC_API_KEY]") {
+			t.Errorf("%s should be redacted: %q, %v", file, data, err)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join("demo", ".agentbox"))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".fetch-") {
+			t.Errorf("temp dir %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestCLIEnv(t *testing.T) {
+	setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("EDITOR", "vim-host-value")
+	t.Setenv("GITHUB_TOKEN", "ghp_host-token-value")
+
+	out, err := runCLI(t, "env", "demo")
+	if err != nil {
+		t.Fatalf("env failed: %v", err)
+	}
+	if !strings.Contains(out, "EDITOR\n") || strings.Contains(out, "GITHUB_TOKEN") {
+		t.Errorf("expected only passing names:\n%s", out)
+	}
+	if strings.Contains(out, "vim-host-value") {
+		t.Errorf("env must not print host values:\n%s", out)
+	}
+
+	out, err = runCLI(t, "env", "demo", "--dry-run")
+	if err != nil {
+		t.Fatalf("env --dry-run failed: %v", err)
+	}
+	if !strings.Contains(out, "GITHUB_TOKEN") || strings.Contains(out, "ghp_host-token-value") {
+		t.Errorf("dry run should list names without values:\n%s", out)
+	}
+}
+
+func TestCLIApply(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+
+	out, err := runCLI(t, "apply", "demo")
+	if err != nil || !strings.Contains(out, "nothing to apply") {
+		t.Fatalf("unchanged config should be a no-op: %v\n%s", err, out)
+	}
+
+	cfg, err := config.Load("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.VM.Memory = "16GiB"
+	cfg.VM.Type = "qemu"
+	if err := config.Save("demo", cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = runCLI(t, "apply", "demo", "--plan")
+	if err != nil {
+		t.Fatalf("apply --plan failed: %v", err)
+	}
+	for _, want := range []string{"vm.memory", "-> 16GiB", "(in place)", "vm.type", "needs reset"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan missing %q:\n%s", want, out)
+		}
+	}
+	if box, _ := fake.Box("demo"); box.Spec.Config.VM.Memory == "16GiB" {
+		t.Error("--plan should not change the box")
+	}
+
+	// A running box is stopped for the change and started again
+	if err := fake.Start(t.Context(), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCLI(t, "apply", "demo")
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if !strings.Contains(out, "Still different: vm.type") {
+		t.Errorf("the reset-only change should be listed:\n%s", out)
+	}
+	box, _ := fake.Box("demo")
+	if box.Spec.Config.VM.Memory != "16GiB" || !box.Running {
+		t.Errorf("box should be resized and running again, got memory %s running %v", box.Spec.Config.VM.Memory, box.Running)
+	}
+	calls := strings.Join(fake.Calls(), ",")
+	if !strings.Contains(calls, "Stop demo,Resize demo,Start demo") {
+		t.Errorf("calls = %s", calls)
+	}
+
+	// An open session would be killed by the stop, so apply refuses
+	sess, err := session.Begin(session.Dir(filepath.Join("demo", ".agentbox")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.End()
+	cfg.VM.Memory = "12GiB"
+	if err := config.Save("demo", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCLI(t, "apply", "demo"); err == nil || !strings.Contains(err.Error(), "running session") {
+		t.Fatalf("expected apply to refuse with a session open, got %v", err)
+	}
+	if box, _ := fake.Box("demo"); box.Spec.Config.VM.Memory != "16GiB" || !box.Running {
+		t.Error("apply should leave the box alone while a session is open")
+	}
+}
+
+func TestCLIResize(t *testing.T) {
+	fake := setupCLI(t)
+	createProject(t, "demo")
+
+	if _, err := runCLI(t, "resize", "demo"); err == nil {
+		t.Error("expected an error without --cpus, --memory or --disk")
+	}
+	if _, err := runCLI(t, "resize", "demo", "--disk", "huge"); err == nil {
+		t.Error("expected an invalid size to be rejected")
+	}
+
+	if _, err := runCLI(t, "resize", "demo", "--cpus", "6", "--plan"); err != nil {
+		t.Fatalf("resize --plan failed: %v", err)
+	}
+	if cfg, _ := config.Load("demo"); cfg.VM.CPUs == 6 {
+		t.Error("--plan should leave agentbox.yaml alone")
+	}
+
+	if _, err := runCLI(t, "resize", "demo", "--cpus", "6"); err != nil {
+		t.Fatalf("resize failed: %v", err)
+	}
+	if cfg, _ := config.Load("demo"); cfg.VM.CPUs != 6 {
+		t.Errorf("agentbox.yaml cpus = %d, want 6", cfg.VM.CPUs)
+	}
+	if box, _ := fake.Box("demo"); box.Spec.Config.VM.CPUs != 6 {
+		t.Errorf("box cpus = %d, want 6", box.Spec.Config.VM.CPUs)
+	}
+}
+
+func TestCLIStatusJSON(t *testing.T) {
+	setupCLI(t)
+	createProject(t, "demo")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-status-test-value")
+	os.WriteFile(filepath.Join("demo", ".agentbox", "network.log"), []byte("2026-10-18T12:00:00Z [PASS] host=a.example\n2026-10-18T12:01:00Z [DENY] host=b.example\n"), 0600)
+
+	out, err := runCLI(t, "status", "demo", "--json")
+	if err != nil {
+		t.Fatalf("status --json failed: %v", err)
+	}
+	if strings.Contains(out, "sk-ant-status-test-value") {
+		t.Fatal("status must never print secret values")
+	}
+
+	var report struct {
+		Project string `json:"project"`
+		VM      struct {
+			State string `json:"state"`
+			CPUs  int    `json:"cpus"`
+		} `json:"vm"`
+		Secrets []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"secrets"`
+		Network struct {
+			LastActivity string `json:"last_activity"`
+		} `json:"network"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if report.Project != "demo" || report.VM.State != "stopped" || report.VM.CPUs == 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if !strings.Contains(report.Network.LastActivity, "host=b.example") {
+		t.Errorf("last network activity = %q", report.Network.LastActivity)
+	}
+	statuses := make(map[string]string)
+	for _, s := range report.Secrets {
+		statuses[s.Name] = s.Status
+	}
+	if statuses["ANTHROPIC_API_KEY"] != "resolved" {
+		t.Errorf("secret statuses = %v", statuses)
+	}
+
+	out, err = runCLI(t, "status", "demo", "--output", "yaml")
+	if err != nil || !strings.Contains(out, "project: demo") {
+		t.Errorf("status --output yaml: %v\n%s", err, out)
+	}
+	if _, err := runCLI(t, "status", "demo", "--output", "xml"); err == nil {
+		t.Error("expected an unknown output format to be rejected")
+	}
+}
+
+func TestCLISecretRmNotifiesSessions(t *testing.T) {
+	setupCLI(t)
+
+	store, err := openSecretStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("openai", "sk-test-value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stand in for a running 'agentbox enter'
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	dir, err := sessionRegistryDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := session.Begin(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.End()
+
+	out, err := runCLI(t, "secret", "rm", "openai")
+	if err != nil {
+		t.Fatalf("secret rm failed: %v", err)
+	}
+	select {
+	case <-hup:
+	case <-time.After(5 * time.Second):
+		t.Fatal("secret rm should signal running sessions")
+	}
+	if !strings.Contains(out, "Updated 1 running session") {
+		t.Errorf("output = %q", out)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,7 +9,7 @@ import (
 	"strings"
 
 	"github.com/davidsenack/agentbox/internal/config"
-	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +17,7 @@ var (
 	createGitHub  bool
 	createPublic  bool
 	createGasTown bool
+	createProfile string
 )
 
 var createCmd = &cobra.Command{
@@ -25,8 +27,8 @@ var createCmd = &cobra.Command{
 
 This command:
   1. Creates the project directory structure
-  2. Generates default agentbox.yaml configuration
-  3. Provisions a Lima VM (stopped)
+  2. Generates agentbox.yaml (the defaults, or a built-in --profile)
+  3. Provisions a VM (stopped) with the configured runtime
   4. Optionally creates a GitHub repo for the project
   5. Optionally registers as a Gas Town rig
 
@@ -34,7 +36,8 @@ Example:
   agentbox create myproject
   agentbox create myproject --github           # Create with private GitHub repo
   agentbox create myproject --github --public  # Create with public GitHub repo
-  agentbox create myproject --gastown          # Create as Gas Town rig (implies --github)`,
+  agentbox create myproject --gastown          # Create as Gas Town rig (implies --github)
+  agentbox create myproject --profile web      # Start from a built-in profile`,
 	Args: cobra.ExactArgs(1),
 	RunE: runCreate,
 }
@@ -43,14 +46,25 @@ func init() {
 	createCmd.Flags().BoolVar(&createGitHub, "github", false, "Create a GitHub repository for the project")
 	createCmd.Flags().BoolVar(&createPublic, "public", false, "Make the GitHub repo public (default: private)")
 	createCmd.Flags().BoolVar(&createGasTown, "gastown", false, "Register as a Gas Town rig (implies --github)")
+	createCmd.Flags().StringVar(&createProfile, "profile", "", "Built-in profile to start from ("+strings.Join(config.ProfileNames(), ", ")+")")
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
 	name := args[0]
+	ctx := cmd.Context()
+
+	// Catch a bad profile before anything is created
+	if createProfile != "" {
+		profile, err := config.LookupProfile(createProfile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Using profile %s: %s\n", profile.Name, profile.Description)
+	}
 
 	// Gas Town mode has different flow - create repo first, then let gt rig add handle directory
 	if createGasTown {
-		return runCreateGasTown(name)
+		return runCreateGasTown(ctx, name)
 	}
 
 	// Standard mode: create local directory structure
@@ -82,8 +96,11 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Create default configuration
-	cfg := config.DefaultConfig()
+	// Create configuration
+	cfg, err := newProjectConfig()
+	if err != nil {
+		return err
+	}
 	if err := config.Save(name, cfg); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
@@ -103,33 +120,30 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Get absolute path for Lima template
+	// Get absolute path for the runtime
 	absPath, err := filepath.Abs(name)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	// Generate Lima template
-	limaTemplate, err := lima.GenerateTemplate(cfg, absPath)
+	// Create the VM with the configured runtime
+	rt, err := newRuntime(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to generate Lima template: %w", err)
+		return err
 	}
+	vmName := rt.InstanceName(name)
 
-	templatePath := filepath.Join(name, ".agentbox", "lima.yaml")
-	if err := os.WriteFile(templatePath, []byte(limaTemplate), 0600); err != nil {
-		return fmt.Errorf("failed to write Lima template: %w", err)
+	exists, err := rt.Exists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check VM %s: %w", vmName, err)
 	}
-
-	// Create Lima VM
-	mgr := lima.NewManager()
-	vmName := lima.VMName(name)
-
-	if mgr.Exists(vmName) {
-		fmt.Printf("Lima VM %q already exists, skipping creation\n", vmName)
+	if exists {
+		fmt.Printf("VM %q already exists, skipping creation\n", vmName)
 	} else {
-		fmt.Printf("Creating Lima VM: %s\n", vmName)
-		if err := mgr.Create(vmName, templatePath); err != nil {
-			return fmt.Errorf("failed to create Lima VM: %w", err)
+		fmt.Printf("Creating VM: %s\n", vmName)
+		if err := rt.Create(ctx, runtime.Spec{Name: name, ProjectDir: absPath, Config: cfg}); err != nil {
+			reportProvisioning(ctx, rt, name, filepath.Join(absPath, ".agentbox"), err)
+			return fmt.Errorf("failed to create VM: %w", err)
 		}
 	}
 
@@ -141,9 +155,23 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// newProjectConfig returns the default config with --profile applied
+func newProjectConfig() (*config.Config, error) {
+	cfg := config.DefaultConfig()
+	if createProfile == "" {
+		return cfg, nil
+	}
+	profile, err := config.LookupProfile(createProfile)
+	if err != nil {
+		return nil, err
+	}
+	profile.Apply(cfg)
+	return cfg, nil
+}
+
 // runCreateGasTown handles project creation for Gas Town mode
 // Flow: create GitHub repo -> create directory -> create VM -> rig is built inside VM on first boot
-func runCreateGasTown(name string) error {
+func runCreateGasTown(ctx context.Context, name string) error {
 	fmt.Printf("Creating AgentBox + Gas Town project: %s\n", name)
 
 	// Check prerequisites - only need gh CLI on host, gt runs inside VM
@@ -224,36 +252,32 @@ func runCreateGasTown(name string) error {
 		return fmt.Errorf("failed to create .gitignore: %w", err)
 	}
 
-	// Create default config
-	cfg := config.DefaultConfig()
+	// Create config
+	cfg, err := newProjectConfig()
+	if err != nil {
+		return err
+	}
 	if err := config.Save(name, cfg); err != nil {
 		return fmt.Errorf("failed to save configuration: %w", err)
 	}
 
-	// Get absolute path for Lima template
+	// Get absolute path for the runtime
 	absPath, err := filepath.Abs(name)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	// Step 3: Generate Lima template - passes repo URL so rig is built inside VM
-	limaTemplate, err := lima.GenerateTemplateGasTown(cfg, absPath, name, repoURL)
+	// Step 3: Create the VM - passes repo URL so rig is built inside VM
+	rt, err := newRuntime(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to generate Lima template: %w", err)
+		return err
 	}
 
-	templatePath := filepath.Join(name, ".agentbox", "lima.yaml")
-	if err := os.WriteFile(templatePath, []byte(limaTemplate), 0600); err != nil {
-		return fmt.Errorf("failed to write Lima template: %w", err)
-	}
-
-	// Step 4: Create Lima VM
-	mgr := lima.NewManager()
-	vmName := lima.VMName(name)
-
-	fmt.Printf("Creating Lima VM: %s\n", vmName)
-	if err := mgr.Create(vmName, templatePath); err != nil {
-		return fmt.Errorf("failed to create Lima VM: %w", err)
+	fmt.Printf("Creating VM: %s\n", rt.InstanceName(name))
+	spec := runtime.Spec{Name: name, ProjectDir: absPath, Config: cfg, RepoURL: repoURL}
+	if err := rt.Create(ctx, spec); err != nil {
+		reportProvisioning(ctx, rt, name, filepath.Join(absPath, ".agentbox"), err)
+		return fmt.Errorf("failed to create VM: %w", err)
 	}
 
 	fmt.Printf("\nAgentBox + Gas Town project created successfully!\n\n")
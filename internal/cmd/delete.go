@@ -9,7 +9,7 @@ import (
 	"strings"
 
 	"github.com/davidsenack/agentbox/internal/config"
-	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -44,6 +44,7 @@ func init() {
 
 func runDelete(cmd *cobra.Command, args []string) error {
 	name := args[0]
+	ctx := cmd.Context()
 
 	// Check if project exists
 	if !config.Exists(name) {
@@ -68,25 +69,33 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	mgr := lima.NewManager()
-	vmName := lima.VMName(name)
+	rt, err := projectRuntime(name)
+	if err != nil {
+		return err
+	}
+	vmName := rt.InstanceName(name)
 
 	// Stop and delete VM if it exists
-	if mgr.Exists(vmName) {
-		running, err := mgr.IsRunning(vmName)
+	// Don't remove the project if we can't tell: it would orphan the VM
+	exists, err := rt.Exists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check VM %s: %w", vmName, err)
+	}
+	if exists {
+		status, err := rt.Status(ctx, name)
 		if err != nil {
 			fmt.Printf("Warning: failed to check VM status: %v\n", err)
 		}
 
-		if running {
+		if status == runtime.StatusRunning {
 			fmt.Printf("Stopping VM: %s\n", vmName)
-			if err := mgr.Stop(vmName); err != nil {
+			if err := rt.Stop(ctx, name); err != nil {
 				return fmt.Errorf("failed to stop VM: %w", err)
 			}
 		}
 
 		fmt.Printf("Deleting VM: %s\n", vmName)
-		if err := mgr.Delete(vmName); err != nil {
+		if err := rt.Delete(ctx, name); err != nil {
 			return fmt.Errorf("failed to delete VM: %w", err)
 		}
 	}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -11,19 +12,26 @@ import (
 	"github.com/davidsenack/agentbox/internal/config"
 	"github.com/davidsenack/agentbox/internal/lima"
 	"github.com/davidsenack/agentbox/internal/proxy"
+	"github.com/davidsenack/agentbox/internal/runtime"
 	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/davidsenack/agentbox/internal/session"
 	"github.com/spf13/cobra"
 )
 
+var (
+	enterSkipScan bool
+)
+
 var enterCmd = &cobra.Command{
 	Use:   "enter <name>",
 	Short: "Enter an AgentBox sandbox",
 	Long: `Enter an AgentBox sandbox environment.
 
 This command:
-  1. Starts the HTTP proxy (with auth injection for configured hosts)
-  2. Starts the Lima VM if not running
-  3. Opens an interactive shell inside the VM
+  1. Scans the workspace for secrets (see secrets.preflight)
+  2. Starts the HTTP proxy (with auth injection for configured hosts)
+  3. Starts the VM if not running
+  4. Opens an interactive shell inside the VM
 
 API keys are injected by the proxy - they never enter the VM.
 The shell starts in /workspace. Exit the shell to return to the host.
@@ -34,8 +42,13 @@ Example:
 	RunE: runEnter,
 }
 
+func init() {
+	enterCmd.Flags().BoolVar(&enterSkipScan, "skip-scan", false, "skip the workspace secret scan (secrets.preflight)")
+}
+
 func runEnter(cmd *cobra.Command, args []string) error {
 	name := args[0]
+	ctx := cmd.Context()
 
 	// Check if project exists
 	if !config.Exists(name) {
@@ -48,27 +61,105 @@ func runEnter(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	// Warn about (or refuse) secrets the agent could read in the workspace
+	if !enterSkipScan {
+		if err := preflightScan(name, cfg); err != nil {
+			return err
+		}
+	}
+
+	sb, err := openSandbox(ctx, name, cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	fmt.Printf("Entering AgentBox: %s\n", name)
+	if len(sb.secretEnv) > 0 {
+		fmt.Printf("API keys injected securely (hidden from env)\n")
+	}
+	if len(sb.surrogates) > 0 {
+		fmt.Printf("%d key(s) replaced with session placeholders (swapped by proxy)\n", len(sb.surrogates))
+	}
+	fmt.Println("Type 'exit' to leave the sandbox")
+	fmt.Println()
+
+	// Enter shell - secrets are in root-only files, not the environment
+	if err := sb.rt.Shell(ctx, name); err != nil {
+		return fmt.Errorf("shell error: %w", err)
+	}
+
+	fmt.Println("\nExited AgentBox")
+	return nil
+}
+
+// sandbox is a running box with its proxy, injected secrets and session
+// enter and exec share it, so commands see the same egress and secrets as
+// the interactive shell
+type sandbox struct {
+	rt         runtime.Runtime
+	network    *proxy.Logger
+	redactor   *secrets.Redactor
+	secretEnv  map[string]string
+	surrogates []*secrets.Surrogate
+	cleanup    []func()
+}
+
+// openSandbox starts the proxy, starts the VM if needed, registers a session
+// and delivers secrets. Progress goes to out; Close undoes it all
+func openSandbox(ctx context.Context, name string, cfg *config.Config, out io.Writer) (_ *sandbox, err error) {
+	sb := &sandbox{}
+	defer func() {
+		if err != nil {
+			sb.Close()
+		}
+	}()
+
 	absPath, err := filepath.Abs(name)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Secrets come from the host env or the secret store (secret://name)
+	resolver, err := secrets.NewResolver(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up secret store: %w", err)
 	}
 
 	// Create redactor for log sanitization
-	redactor := secrets.NewRedactor(cfg.Secrets.RedactPatterns)
+	redactor, err := newRedactor(cfg, resolver)
+	if err != nil {
+		return nil, err
+	}
+	sb.redactor = redactor
 
 	// Set up network log
 	networkLogPath := filepath.Join(absPath, ".agentbox", "network.log")
 	networkLog, err := proxy.NewLogger(networkLogPath, redactor)
 	if err != nil {
-		return fmt.Errorf("failed to create network logger: %w", err)
+		return nil, fmt.Errorf("failed to create network logger: %w", err)
+	}
+	sb.network = networkLog
+	sb.onClose(func() { networkLog.Close() })
+
+	// Hand the guest placeholders for secrets with surrogate hosts
+	sb.secretEnv, sb.surrogates, err = resolveSecretEnv(cfg, resolver)
+	if err != nil {
+		return nil, err
 	}
-	defer networkLog.Close()
 
-	// Start proxy with auth injection
-	proxyServer := proxy.New(cfg.Network.ProxyPort, cfg.Network.InjectAuth, networkLog)
+	// Start proxy with auth injection; the box's CA lets it intercept TLS
+	// for the hosts it injects into
+	ca, err := proxy.LoadCA(filepath.Join(absPath, ".agentbox", "proxy-ca"))
+	if err != nil {
+		return nil, err
+	}
+	proxyServer := proxy.New(cfg.Network, sb.surrogates, resolver.Value, networkLog)
+	proxyServer.SetCA(ca)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// The proxy lives until Close, or until agentbox is interrupted
+	ctx, cancel := context.WithCancel(ctx)
+	sb.onClose(cancel)
 
 	go func() {
 		if err := proxyServer.Start(ctx); err != nil {
@@ -76,52 +167,251 @@ func runEnter(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	// Handle signals
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	// Start VM if needed
+	rt, err := newRuntime(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sb.rt = rt
+	vmName := rt.InstanceName(name)
+
+	status, err := rt.Status(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check VM status: %w", err)
+	}
+
+	if status == runtime.StatusNotCreated {
+		return nil, fmt.Errorf("VM %q does not exist. Run 'agentbox create %s' first", vmName, name)
+	}
+
+	// Point out a provision section that changed since it was last applied
+	if lima.ProvisionChanged(filepath.Join(absPath, ".agentbox"), cfg.Provision) {
+		fmt.Fprintf(os.Stderr, "Warning: provision in agentbox.yaml changed since %s was provisioned; run 'agentbox provision %s' to apply it\n", vmName, name)
+	}
+
+	if status != runtime.StatusRunning {
+		fmt.Fprintf(out, "Starting VM: %s\n", vmName)
+		startErr := rt.Start(ctx, name)
+		// A fresh boot provisions, so show any step that failed
+		reportProvisioning(ctx, rt, name, filepath.Join(absPath, ".agentbox"), startErr)
+		if startErr != nil {
+			return nil, fmt.Errorf("failed to start VM: %w", startErr)
+		}
+	}
+
+	// Intercepted hosts only work once the guest trusts the CA
+	if err := rt.InstallCA(ctx, name, ca.CertPEM()); err != nil {
+		return nil, err
+	}
+
+	// Track this session so secrets are wiped when the last one exits
+	sess, err := session.Begin(session.Dir(filepath.Join(absPath, ".agentbox")))
+	if err != nil {
+		return nil, err
+	}
+	sb.onClose(func() {
+		last, err := sess.End()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to end session: %v\n", err)
+			return
+		}
+		if last {
+			// Wipe even when agentbox was interrupted
+			if err := rt.WipeSecrets(context.WithoutCancel(ctx), name); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	})
+
+	// Deliver secrets over stdin in one call
+	if err := rt.InjectSecrets(ctx, name, sb.secretEnv); err != nil {
+		return nil, err
+	}
+
+	// 'agentbox secret set/rotate/rm' sends SIGHUP to registered sessions
+	// Catch it before registering: the default action would kill the session
+	// without wiping its secrets
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	sb.onClose(func() { signal.Stop(hupCh) })
+	if dir, err := sessionRegistryDir(); err == nil {
+		if reg, err := session.Begin(dir); err == nil {
+			sb.onClose(func() { reg.End() })
+		}
+	}
 	go func() {
-		<-sigCh
-		cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				if err := reloadSecrets(ctx, cfg, resolver, sb.surrogates, proxyServer, redactor, rt, name); err != nil {
+					fmt.Fprintf(os.Stderr, "\nWarning: failed to reload secrets: %v\n", err)
+				}
+			}
+		}
 	}()
 
-	// Start VM if needed
-	mgr := lima.NewManager()
-	vmName := lima.VMName(name)
+	return sb, nil
+}
+
+// onClose registers f to run on Close, before everything registered earlier
+func (sb *sandbox) onClose(f func()) {
+	sb.cleanup = append(sb.cleanup, f)
+}
 
-	if !mgr.Exists(vmName) {
-		return fmt.Errorf("Lima VM %q does not exist. Run 'agentbox create %s' first", vmName, name)
+// Close ends the session (wiping secrets if it was the last) and stops the
+// proxy
+func (sb *sandbox) Close() {
+	for i := len(sb.cleanup) - 1; i >= 0; i-- {
+		sb.cleanup[i]()
 	}
+	sb.cleanup = nil
+}
+
+// resolveSecretEnv resolves allowed env vars from the host env or the store
+// Vars with surrogate hosts get a random per-session placeholder instead of
+// the real value; the proxy swaps it back only for those hosts
+func resolveSecretEnv(cfg *config.Config, resolver *secrets.Resolver) (map[string]string, []*secrets.Surrogate, error) {
+	secretEnv := make(map[string]string)
+	var surrogates []*secrets.Surrogate
+
+	for _, entry := range cfg.Secrets.AllowedEnvVars {
+		varName, ref := secrets.ParseEnvRef(entry)
+		val, err := resolver.Lookup(ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		if val == "" {
+			continue
+		}
+
+		hosts := cfg.Secrets.Surrogates[varName]
+		if len(hosts) == 0 {
+			secretEnv[varName] = val
+			continue
+		}
 
-	running, err := mgr.IsRunning(vmName)
+		sur, err := secrets.NewSurrogate(varName, val, hosts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create placeholder for %s: %w", varName, err)
+		}
+		surrogates = append(surrogates, sur)
+		secretEnv[varName] = sur.Placeholder
+	}
+
+	return secretEnv, surrogates, nil
+}
+
+// reloadSecrets pushes rotated values to a running session: the proxy's
+// injected headers and surrogates, the redactor and the guest's secret files
+// Surrogate placeholders are kept, so only plain secrets change in the guest
+func reloadSecrets(ctx context.Context, cfg *config.Config, resolver *secrets.Resolver, surrogates []*secrets.Surrogate, p *proxy.Proxy, redactor *secrets.Redactor, rt runtime.Runtime, name string) error {
+	resolver.Refresh()
+
+	hostValues, err := hostSecretValues(cfg, resolver)
 	if err != nil {
-		return fmt.Errorf("failed to check VM status: %w", err)
+		return err
 	}
+	// Old values stay redacted too
+	redactor.AddLiterals(hostValues)
 
-	if !running {
-		fmt.Printf("Starting VM: %s\n", vmName)
-		if err := mgr.Start(vmName); err != nil {
-			return fmt.Errorf("failed to start VM: %w", err)
+	placeholders := make(map[string]string, len(surrogates))
+	for _, sur := range surrogates {
+		placeholders[sur.Name] = sur.Placeholder
+	}
+
+	secretEnv := make(map[string]string)
+	surrogateValues := make(map[string]string)
+	for _, entry := range cfg.Secrets.AllowedEnvVars {
+		varName, ref := secrets.ParseEnvRef(entry)
+		val, err := resolver.Lookup(ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		if val == "" {
+			if _, ok := placeholders[varName]; ok {
+				// Removed: stop swapping the old value in
+				surrogateValues[varName] = ""
+			}
+			continue
+		}
+		if placeholder, ok := placeholders[varName]; ok {
+			surrogateValues[varName] = val
+			secretEnv[varName] = placeholder
+			continue
+		}
+		if len(cfg.Secrets.Surrogates[varName]) > 0 {
+			// Set after the session started; needs a new placeholder
+			continue
 		}
+		secretEnv[varName] = val
 	}
 
-	// Show auth injection info
-	authHosts := make([]string, 0, len(cfg.Network.InjectAuth))
-	for _, auth := range cfg.Network.InjectAuth {
-		authHosts = append(authHosts, auth.Host)
+	p.Reload(surrogateValues)
+	return rt.InjectSecrets(ctx, name, secretEnv)
+}
+
+// newRedactor builds the redactor for everything agentbox logs: the
+// configured patterns and detectors plus the literal host secret values
+func newRedactor(cfg *config.Config, resolver *secrets.Resolver) (*secrets.Redactor, error) {
+	hostValues, err := hostSecretValues(cfg, resolver)
+	if err != nil {
+		return nil, err
+	}
+	detectors, err := secrets.EnabledDetectors(cfg.Secrets.Detectors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets.detectors: %w", err)
 	}
+	redactor := secrets.NewRedactorWithDetectors(cfg.Secrets.RedactPatterns, detectors)
+	redactor.AddLiterals(hostValues)
+	return redactor, nil
+}
 
-	fmt.Printf("Entering AgentBox: %s\n", name)
-	if len(cfg.Secrets.AllowedEnvVars) > 0 {
-		fmt.Printf("API keys injected securely (hidden from env)\n")
+// hostSecretValues collects the literal host secrets agentbox handles:
+// inject_auth envs, allowed_env_vars and AWS signing credentials
+// Keyed by env var name (or secret:// reference) so redactions say where a
+// value came from
+func hostSecretValues(cfg *config.Config, resolver *secrets.Resolver) (map[string]string, error) {
+	refs := secretRefs(cfg)
+	values := make(map[string]string, len(refs))
+	for label, ref := range refs {
+		val, err := resolver.Lookup(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		if val != "" {
+			values[label] = val
+		}
 	}
-	fmt.Println("Type 'exit' to leave the sandbox")
-	fmt.Println()
+	return values, nil
+}
 
-	// Enter shell with allowed env vars
-	if err := mgr.Shell(vmName, cfg.Secrets.AllowedEnvVars); err != nil {
-		return fmt.Errorf("shell error: %w", err)
+// secretRefs maps each host secret agentbox handles to its reference
+func secretRefs(cfg *config.Config) map[string]string {
+	refs := make(map[string]string) // label -> ref
+	for _, auth := range cfg.Network.InjectAuth {
+		refs[auth.Env] = auth.Env
+	}
+	for _, entry := range cfg.Secrets.AllowedEnvVars {
+		varName, ref := secrets.ParseEnvRef(entry)
+		refs[varName] = ref
 	}
+	if aws := cfg.Network.AWSSigning; aws != nil {
+		for _, ref := range []string{
+			envOrDefault(aws.AccessKeyEnv, "AWS_ACCESS_KEY_ID"),
+			envOrDefault(aws.SecretKeyEnv, "AWS_SECRET_ACCESS_KEY"),
+			envOrDefault(aws.SessionTokenEnv, "AWS_SESSION_TOKEN"),
+		} {
+			refs[ref] = ref
+		}
+	}
+	return refs
+}
 
-	fmt.Println("\nExited AgentBox")
-	return nil
+func envOrDefault(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
 }
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envDryRun bool
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env <name>",
+	Short: "Show which host env vars reach the VM",
+	Long: `Show the host environment variables propagated to Lima for a project.
+
+Lima copies the calling environment into the VM, so agentbox filters it
+first (see secrets.env). By default a built-in denylist blocks common
+credential variables (AWS_*, *_TOKEN, *_KEY, DATABASE_URL, ...). Add your
+own patterns, or switch to allowlist mode so only listed variables pass:
+
+  secrets:
+    env:
+      mode: allowlist          # or denylist (default)
+      allow: [EDITOR, "LC_*"]
+      block: ["STAGING_*", "re:^CORP_.*_URL$"]
+
+Patterns are globs, or regular expressions prefixed with "re:".
+
+Without --dry-run, prints the names of the variables that pass.
+With --dry-run, lists every host variable, whether it would pass, and the
+rule that decided it. Values are never printed.
+
+Example:
+  agentbox env myproject --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnv,
+}
+
+func init() {
+	envCmd.Flags().BoolVar(&envDryRun, "dry-run", false, "explain each variable's decision")
+}
+
+func runEnv(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	filter, err := secrets.NewEnvFilter(cfg.Secrets.Env)
+	if err != nil {
+		return err
+	}
+
+	if !envDryRun {
+		// Names only: the values are the host's, not something to print
+		for _, e := range filter.Filter(os.Environ()) {
+			name, _, _ := strings.Cut(e, "=")
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	decisions := filter.Explain(os.Environ())
+	passed := 0
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIABLE\tRESULT\tREASON")
+	for _, d := range decisions {
+		result := "block"
+		if d.Pass {
+			result = "pass"
+			passed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Name, result, d.Reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d passed, %d blocked\n", passed, len(decisions)-passed)
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execWorkdir  string
+	execEnv      []string
+	execTTY      bool
+	execSkipScan bool
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <name> [flags] -- <command> [args...]",
+	Short: "Run a command in an AgentBox sandbox",
+	Long: `Run a command in an AgentBox sandbox and exit with its exit code.
+
+Like 'agentbox enter', this scans the workspace, starts the proxy and the
+VM if needed, and delivers secrets the same way; only the interactive
+shell is replaced by the command. stdin, stdout and stderr are streamed,
+and agentbox's own messages go to stderr so stdout is the command's alone.
+
+No terminal is allocated unless --tty is given. --env values are checked
+against secrets.env like the host environment; pass secrets with
+secrets.allowed_env_vars instead.
+
+Example:
+  agentbox exec myproject -- make test
+  agentbox exec myproject --workdir /workspace/api --env CI=1 -- go test ./...
+  agentbox exec myproject --tty -- htop`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runExec,
+}
+
+func init() {
+	execCmd.Flags().StringVarP(&execWorkdir, "workdir", "w", "/workspace", "guest directory to run in")
+	execCmd.Flags().StringArrayVarP(&execEnv, "env", "e", nil, "set an environment variable (KEY=VALUE, repeatable)")
+	execCmd.Flags().BoolVarP(&execTTY, "tty", "t", false, "allocate a terminal")
+	execCmd.Flags().BoolVar(&execSkipScan, "skip-scan", false, "skip the workspace secret scan (secrets.preflight)")
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	ctx := cmd.Context()
+	switch dash := cmd.ArgsLenAtDash(); {
+	case dash == 0:
+		return errors.New("missing <name> before '--'")
+	case dash > 1:
+		return fmt.Errorf("unexpected argument %q before '--'", args[1])
+	}
+	command := args[1:]
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := checkExecEnv(cfg, execEnv); err != nil {
+		return err
+	}
+	if execTTY && !isTerminal(os.Stdin) {
+		return errors.New("--tty needs a terminal on stdin")
+	}
+
+	if !execSkipScan {
+		if err := preflightScan(name, cfg); err != nil {
+			return err
+		}
+	}
+
+	sb, err := openSandbox(ctx, name, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	err = sb.rt.Exec(ctx, name, command, runtime.ExecOptions{
+		Dir:    execWorkdir,
+		Env:    execEnv,
+		TTY:    execTTY,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+	var exitErr *runtime.ExitError
+	if errors.As(err, &exitErr) {
+		// The command's exit code is agentbox's; there's nothing to report
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// checkExecEnv validates --env entries against secrets.env, so a var that
+// would be kept from the VM can't be passed to it by hand
+func checkExecEnv(cfg *config.Config, env []string) error {
+	filter, err := secrets.NewEnvFilter(cfg.Secrets.Env)
+	if err != nil {
+		return err
+	}
+	for _, entry := range env {
+		varName, _, ok := strings.Cut(entry, "=")
+		if !ok || varName == "" {
+			return fmt.Errorf("invalid --env %q (use KEY=VALUE)", entry)
+		}
+		if d := filter.Decide(varName); !d.Pass {
+			return fmt.Errorf("--env %s: %s (pass secrets with secrets.allowed_env_vars)", varName, d.Reason)
+		}
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a character device (a terminal)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -6,7 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/davidsenack/agentbox/internal/config"
-	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -20,15 +20,19 @@ Shows project name, VM status, and configuration summary.`,
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
 	// Find agentbox.yaml files in current directory
 	entries, err := os.ReadDir(".")
 	if err != nil {
 		return fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	mgr := lima.NewManager()
 	found := false
 
+	// One listing per runtime serves every project using it
+	snapshots := make(map[string]map[string]runtime.Status)
+
 	fmt.Println("AgentBox Projects:")
 	fmt.Println()
 
@@ -44,18 +48,32 @@ func runList(cmd *cobra.Command, args []string) error {
 		}
 
 		found = true
-		vmName := lima.VMName(name)
-		status := "not created"
-
-		if mgr.Exists(vmName) {
-			running, err := mgr.IsRunning(vmName)
-			if err != nil {
-				status = "unknown"
-			} else if running {
-				status = "running"
-			} else {
-				status = "stopped"
+
+		// Each project may use a different runtime
+		rt, err := projectRuntime(name)
+		if err != nil {
+			fmt.Printf("  %s\n", name)
+			fmt.Printf("    VM: unknown (%v)\n", err)
+			continue
+		}
+
+		vmName := rt.InstanceName(name)
+		status := "unknown"
+		if lister, ok := rt.(runtime.Lister); ok {
+			statuses, cached := snapshots[rt.Name()]
+			if !cached {
+				// A failed listing is cached too, so it isn't retried per project
+				statuses, _ = lister.Statuses(ctx)
+				snapshots[rt.Name()] = statuses
+			}
+			if statuses != nil {
+				status = string(runtime.StatusNotCreated)
+				if st, ok := statuses[vmName]; ok {
+					status = string(st)
+				}
 			}
+		} else if st, err := rt.Status(ctx, name); err == nil {
+			status = string(st)
 		}
 
 		fmt.Printf("  %s\n", name)
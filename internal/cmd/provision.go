@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	provisionOnly []string
+)
+
+var provisionCmd = &cobra.Command{
+	Use:   "provision <name>",
+	Short: "Re-run provisioning in an existing VM",
+	Long: `Re-run provisioning in an existing VM without destroying it.
+
+The provision script is regenerated from the current agentbox.yaml and run
+in the VM (starting it if needed). Every section is idempotent: packages and
+toolchains already at the configured version are left alone, and each
+provision step runs once. Output is streamed and saved to
+.agentbox/provision.log, followed by a summary of what changed and failed.
+
+Sections, in the order they run:
+  base        agent user, proxy config, shell and secure wrappers
+  toolchains  toolchains (go, node, python, rust)
+  agents      agents (claude-code, opencode, gt, bd)
+  packages    provision.apt, npm, pip and go
+  steps       provision.steps
+
+Example:
+  agentbox provision myproject
+  agentbox provision myproject --only toolchains,agents
+  agentbox provision myproject --only steps`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProvision,
+}
+
+func init() {
+	provisionCmd.Flags().StringSliceVar(&provisionOnly, "only", nil, "run only these sections ("+strings.Join(lima.ProvisionSections, ", ")+")")
+}
+
+func runProvision(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	ctx := cmd.Context()
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := lima.ValidateSections(provisionOnly); err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rt, err := newRuntime(cfg)
+	if err != nil {
+		return err
+	}
+	vmName := rt.InstanceName(name)
+
+	status, err := rt.Status(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check VM status: %w", err)
+	}
+	if status == runtime.StatusNotCreated {
+		return fmt.Errorf("VM %q does not exist. Run 'agentbox create %s' first", vmName, name)
+	}
+	if status != runtime.StatusRunning {
+		fmt.Printf("Starting VM: %s\n", vmName)
+		if err := rt.Start(ctx, name); err != nil {
+			return fmt.Errorf("failed to start VM: %w", err)
+		}
+	}
+
+	sections := provisionOnly
+	if len(sections) == 0 {
+		sections = lima.ProvisionSections
+	}
+
+	// Steps can echo secrets; keep them out of the log
+	resolver, err := secrets.NewResolver(name)
+	if err != nil {
+		return fmt.Errorf("failed to set up secret store: %w", err)
+	}
+	redactor, err := newRedactor(cfg, resolver)
+	if err != nil {
+		return err
+	}
+
+	logPath := filepath.Join(absPath, ".agentbox", lima.ProvisionLogFile)
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create provision log: %w", err)
+	}
+	defer logFile.Close()
+	fmt.Fprintf(logFile, "# agentbox provision %s (%s) at %s\n", name, strings.Join(sections, ", "), time.Now().Format(time.RFC3339))
+
+	fmt.Printf("Provisioning %s (%s)\n", vmName, strings.Join(sections, ", "))
+	logWriter := redactor.Writer(logFile)
+	report := &provisionReport{out: os.Stdout, log: logWriter}
+	runErr := rt.Provision(ctx, runtime.Spec{Name: name, ProjectDir: absPath, Config: cfg}, provisionOnly, report)
+	report.Flush()
+	if err := logWriter.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write provision log: %v\n", err)
+	}
+	// The guest wrote a fresh step log for 'agentbox status'
+	_ = fetchRedacted(ctx, rt, name, lima.GuestStepLog, filepath.Join(absPath, ".agentbox", lima.StepLogFile), redactor)
+
+	fmt.Println()
+	if len(report.changed) > 0 {
+		fmt.Printf("Changed: %s\n", strings.Join(report.changed, "; "))
+	} else {
+		fmt.Println("Changed: nothing")
+	}
+	if len(report.failed) > 0 {
+		fmt.Printf("Failed:  %s\n", strings.Join(report.failed, "; "))
+	}
+	fmt.Printf("Log:     %s\n", filepath.Join(name, ".agentbox", lima.ProvisionLogFile))
+
+	var exitErr *runtime.ExitError
+	switch {
+	case errors.As(runErr, &exitErr):
+		return fmt.Errorf("provisioning stopped in section %s (exit status %d)", report.section, exitErr.Code)
+	case runErr != nil:
+		return runErr
+	case len(report.failed) > 0:
+		return fmt.Errorf("%d provisioning item(s) failed", len(report.failed))
+	}
+	return nil
+}
+
+// provisionReport passes provision output through to out and log, turning
+// the script's progress markers into readable lines and collecting what
+// changed and failed
+type provisionReport struct {
+	out     io.Writer
+	log     io.Writer
+	partial []byte
+
+	section string
+	changed []string
+	failed  []string
+}
+
+func (r *provisionReport) Write(p []byte) (int, error) {
+	if _, err := r.log.Write(p); err != nil {
+		return 0, err
+	}
+
+	r.partial = append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i < 0 {
+			break
+		}
+		r.line(string(r.partial[:i]))
+		r.partial = r.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush handles output that didn't end in a newline
+func (r *provisionReport) Flush() {
+	if len(r.partial) > 0 {
+		r.line(string(r.partial))
+		r.partial = nil
+	}
+}
+
+func (r *provisionReport) line(line string) {
+	marker, ok := strings.CutPrefix(line, lima.ProvisionMarker)
+	if !ok {
+		fmt.Fprintln(r.out, line)
+		return
+	}
+
+	kind, detail, _ := strings.Cut(marker, " ")
+	switch kind {
+	case "section":
+		if detail != r.section {
+			r.section = detail
+			fmt.Fprintf(r.out, "==> %s\n", detail)
+		}
+	case "changed":
+		r.changed = append(r.changed, detail)
+		fmt.Fprintf(r.out, "==> changed: %s\n", detail)
+	case "failed":
+		r.failed = append(r.failed, detail)
+		fmt.Fprintf(r.out, "==> FAILED: %s\n", detail)
+	}
+}
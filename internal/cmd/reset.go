@@ -6,10 +6,15 @@ import (
 	"path/filepath"
 
 	"github.com/davidsenack/agentbox/internal/config"
-	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/snapshot"
 	"github.com/spf13/cobra"
 )
 
+var (
+	resetTo string
+)
+
 var resetCmd = &cobra.Command{
 	Use:   "reset <name>",
 	Short: "Reset an AgentBox to clean state",
@@ -19,18 +24,26 @@ This command:
   1. Stops the VM if running
   2. Destroys the VM and its disk
   3. Recreates the VM from the template
-  4. Preserves workspace/ and artifacts/ directories
+  4. With --to, restores a snapshot (see 'agentbox snapshot')
+  5. Preserves workspace/ and artifacts/ directories
 
-Use this when you want to start fresh without losing your code.
+Use this when you want to start fresh without losing your code, or
+return to a known-good state without reinstalling your tooling.
 
 Example:
-  agentbox reset myproject`,
+  agentbox reset myproject
+  agentbox reset myproject --to tooled`,
 	Args: cobra.ExactArgs(1),
 	RunE: runReset,
 }
 
+func init() {
+	resetCmd.Flags().StringVar(&resetTo, "to", "", "restore this snapshot after recreating the VM")
+}
+
 func runReset(cmd *cobra.Command, args []string) error {
 	name := args[0]
+	ctx := cmd.Context()
 
 	// Check if project exists
 	if !config.Exists(name) {
@@ -43,26 +56,48 @@ func runReset(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	mgr := lima.NewManager()
-	vmName := lima.VMName(name)
+	rt, err := newRuntime(cfg)
+	if err != nil {
+		return err
+	}
+	vmName := rt.InstanceName(name)
+
+	// Check the snapshot before destroying anything
+	var snap *snapshot.Snapshot
+	var snapDir string
+	if resetTo != "" {
+		if snapDir, err = snapshotDir(name); err != nil {
+			return err
+		}
+		if snap, err = snapshot.Get(snapDir, resetTo); err != nil {
+			return err
+		}
+		if err := checkSnapshotRuntime(rt, snap); err != nil {
+			return err
+		}
+	}
 
 	// Stop VM if running
-	if mgr.Exists(vmName) {
-		running, err := mgr.IsRunning(vmName)
+	exists, err := rt.Exists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check VM %s: %w", vmName, err)
+	}
+	if exists {
+		status, err := rt.Status(ctx, name)
 		if err != nil {
 			fmt.Printf("Warning: failed to check VM status: %v\n", err)
 		}
 
-		if running {
+		if status == runtime.StatusRunning {
 			fmt.Printf("Stopping VM: %s\n", vmName)
-			if err := mgr.Stop(vmName); err != nil {
+			if err := rt.Stop(ctx, name); err != nil {
 				return fmt.Errorf("failed to stop VM: %w", err)
 			}
 		}
 
 		// Delete VM
 		fmt.Printf("Deleting VM: %s\n", vmName)
-		if err := mgr.Delete(vmName); err != nil {
+		if err := rt.Delete(ctx, name); err != nil {
 			return fmt.Errorf("failed to delete VM: %w", err)
 		}
 	}
@@ -78,23 +113,23 @@ func runReset(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Warning: failed to clear network log: %v\n", err)
 	}
 
-	// Regenerate Lima template
-	limaTemplate, err := lima.GenerateTemplate(cfg, absPath)
-	if err != nil {
-		return fmt.Errorf("failed to generate Lima template: %w", err)
-	}
+	// Recreate VM from the current config
+	// The old VM's provisioning logs no longer apply
+	clearProvisionLogs(filepath.Join(absPath, ".agentbox"))
 
-	templatePath := filepath.Join(absPath, ".agentbox", "lima.yaml")
-	if err := os.WriteFile(templatePath, []byte(limaTemplate), 0600); err != nil {
-		return fmt.Errorf("failed to write Lima template: %w", err)
-	}
-
-	// Recreate VM
 	fmt.Printf("Creating VM: %s\n", vmName)
-	if err := mgr.Create(vmName, templatePath); err != nil {
+	if err := rt.Create(ctx, runtime.Spec{Name: name, ProjectDir: absPath, Config: cfg}); err != nil {
+		reportProvisioning(ctx, rt, name, filepath.Join(absPath, ".agentbox"), err)
 		return fmt.Errorf("failed to create VM: %w", err)
 	}
 
+	if snap != nil {
+		fmt.Printf("Restoring snapshot: %s\n", snap.Name)
+		if err := rt.RestoreSnapshot(ctx, name, snapshot.Path(snapDir, snap.Name)); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+	}
+
 	fmt.Printf("\nAgentBox reset complete!\n")
 	fmt.Printf("Workspace and artifacts preserved.\n")
 	fmt.Printf("\nRun 'agentbox enter %s' to continue.\n", name)
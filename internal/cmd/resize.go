@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resizeCPUs   int
+	resizeMemory string
+	resizeDisk   string
+	resizePlan   bool
+)
+
+var resizeCmd = &cobra.Command{
+	Use:   "resize <name>",
+	Short: "Change a VM's CPUs, memory or disk in place",
+	Long: `Change a VM's CPUs, memory or disk without a reset.
+
+The new values are written to agentbox.yaml and applied like
+'agentbox apply'. Disks can only grow.
+
+Example:
+  agentbox resize myproject --memory 8GiB --cpus 4
+  agentbox resize myproject --disk 100GiB --plan`,
+	Args: cobra.ExactArgs(1),
+	RunE: runResize,
+}
+
+func init() {
+	resizeCmd.Flags().IntVar(&resizeCPUs, "cpus", 0, "number of CPUs")
+	resizeCmd.Flags().StringVar(&resizeMemory, "memory", "", "memory size (e.g., 8GiB)")
+	resizeCmd.Flags().StringVar(&resizeDisk, "disk", "", "disk size (e.g., 100GiB)")
+	resizeCmd.Flags().BoolVar(&resizePlan, "plan", false, "only show what would change; agentbox.yaml is left alone")
+}
+
+func runResize(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+	if resizeCPUs == 0 && resizeMemory == "" && resizeDisk == "" {
+		return errors.New("nothing to resize (use --cpus, --memory or --disk)")
+	}
+	if resizeCPUs < 0 {
+		return fmt.Errorf("invalid --cpus %d", resizeCPUs)
+	}
+	for _, size := range []string{resizeMemory, resizeDisk} {
+		if size == "" {
+			continue
+		}
+		if _, err := lima.ParseSize(size); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if resizeCPUs > 0 {
+		cfg.VM.CPUs = resizeCPUs
+	}
+	if resizeMemory != "" {
+		cfg.VM.Memory = resizeMemory
+	}
+	if resizeDisk != "" {
+		cfg.VM.Disk = resizeDisk
+	}
+
+	if !resizePlan {
+		if err := config.Save(name, cfg); err != nil {
+			return fmt.Errorf("failed to save configuration: %w", err)
+		}
+	}
+	return applyConfig(cmd.Context(), name, cfg, resizePlan)
+}
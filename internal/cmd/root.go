@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/davidsenack/agentbox/internal/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -50,8 +56,22 @@ var versionCmd = &cobra.Command{
 }
 
 // Execute runs the root command
+// Ctrl-C or SIGTERM cancels the command's context, which interrupts any
+// runtime call in flight; a second one exits right away
 func Execute() int {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		// 'agentbox exec' exits with the command's exit code
+		var exitErr *runtime.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.Code
+		}
 		return 1
 	}
 	return 0
@@ -60,10 +80,21 @@ func Execute() int {
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(enterCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(provisionCmd)
 	rootCmd.AddCommand(resetCmd)
+	rootCmd.AddCommand(resizeCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(sandboxBridgeCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(secretCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(versionCmd)
 }
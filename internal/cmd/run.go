@@ -0,0 +1,436 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/proxy"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runAgent      string
+	runPrompt     string
+	runPromptFile string
+	runTimeout    time.Duration
+	runStopVM     bool
+	runResultPath string
+	runSkipScan   bool
+)
+
+// runAgents are the headless commands for each agent; the prompt is on stdin
+var runAgents = map[string][]string{
+	"claude":   {"claude", "-p", "--dangerously-skip-permissions"},
+	"opencode": {"sh", "-c", `exec opencode -q -p "$(cat)"`},
+}
+
+// Run statuses
+const (
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+	runTimedOut  = "timed_out"
+)
+
+// timeoutExitCode is what timeout(1) exits with when the limit is hit
+const timeoutExitCode = 124
+
+var runCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run an agent on a task without a terminal",
+	Long: `Run an agent headless on a one-shot task.
+
+This command:
+  1. Starts the proxy and the VM, like 'agentbox enter'
+  2. Runs the agent in /workspace with the prompt on stdin
+  3. Streams the transcript and saves it under .agentbox/runs/<id>/
+  4. Stops the agent when --timeout is reached
+  5. Copies new and changed files in artifacts/ into the run directory
+  6. Writes result.json: status, exit code, duration, files changed and
+     a network summary
+  7. Stops the VM afterwards with --stop
+
+agentbox exits with the agent's exit code (124 on timeout).
+
+Agents: claude, opencode
+
+Example:
+  agentbox run myproject --prompt-file task.md --timeout 45m
+  agentbox run myproject --agent opencode --prompt "Fix the failing tests" --stop`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRun,
+}
+
+func init() {
+	runCmd.Flags().StringVar(&runAgent, "agent", "claude", "agent to run (claude, opencode)")
+	runCmd.Flags().StringVar(&runPrompt, "prompt", "", "task for the agent")
+	runCmd.Flags().StringVar(&runPromptFile, "prompt-file", "", "file with the task for the agent")
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", 0, "stop the agent after this long (e.g., 45m; 0 for no limit)")
+	runCmd.Flags().BoolVar(&runStopVM, "stop", false, "stop the VM when the run ends")
+	runCmd.Flags().StringVar(&runResultPath, "result", "", "also write the result summary to this file")
+	runCmd.Flags().BoolVar(&runSkipScan, "skip-scan", false, "skip the workspace secret scan (secrets.preflight)")
+}
+
+// runResult is the summary written to result.json
+type runResult struct {
+	Project      string        `json:"project"`
+	Agent        string        `json:"agent"`
+	Status       string        `json:"status"`
+	ExitCode     int           `json:"exit_code"`
+	Started      string        `json:"started"`
+	Duration     float64       `json:"duration_s"`
+	Timeout      string        `json:"timeout,omitempty"`
+	Transcript   string        `json:"transcript"`
+	FilesChanged fileChanges   `json:"files_changed"`
+	Artifacts    []string      `json:"artifacts"`
+	Network      proxy.Summary `json:"network"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// fileChanges lists guest paths in writable mounts changed by a run
+type fileChanges struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+}
+
+func runRun(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	ctx := cmd.Context()
+
+	agentCmd, ok := runAgents[runAgent]
+	if !ok {
+		return fmt.Errorf("unknown agent %q (use claude or opencode)", runAgent)
+	}
+	prompt, err := readPrompt(runPrompt, runPromptFile)
+	if err != nil {
+		return err
+	}
+	if runTimeout < 0 {
+		return fmt.Errorf("invalid --timeout %s", runTimeout)
+	}
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	mounts, err := lima.ResolveMounts(cfg.Mounts, absPath)
+	if err != nil {
+		return err
+	}
+
+	if !runSkipScan {
+		if err := preflightScan(name, cfg); err != nil {
+			return err
+		}
+	}
+
+	started := time.Now()
+	before := scanMounts(mounts)
+
+	sb, err := openSandbox(ctx, name, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer sb.Close()
+
+	// Only a run that got a sandbox leaves a run directory behind
+	runDir := filepath.Join(absPath, ".agentbox", "runs", started.UTC().Format("20060102-150405"))
+	if err := os.MkdirAll(runDir, 0700); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(runDir, "prompt.md"), prompt, 0600); err != nil {
+		return fmt.Errorf("failed to save prompt: %w", err)
+	}
+	transcriptPath := filepath.Join(runDir, "transcript.log")
+	transcript, err := os.OpenFile(transcriptPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create transcript: %w", err)
+	}
+	defer transcript.Close()
+
+	result := &runResult{
+		Project:    name,
+		Agent:      runAgent,
+		Started:    started.UTC().Format(time.RFC3339),
+		Transcript: transcriptPath,
+		Artifacts:  []string{},
+	}
+	if runTimeout > 0 {
+		result.Timeout = runTimeout.String()
+	}
+
+	command := agentCmd
+	if runTimeout > 0 {
+		// Enforced in the guest, so it holds even if agentbox itself is killed
+		// Rounded up: timeout(1) treats 0s as no limit
+		secs := int64((runTimeout + time.Second - 1) / time.Second)
+		command = append([]string{"timeout", "--kill-after=30s", strconv.FormatInt(secs, 10) + "s"}, agentCmd...)
+	}
+
+	// Both streams go through one redactor, so host secrets never reach the
+	// transcript even when split across writes
+	transcriptLog := &lockedWriter{w: sb.redactor.Writer(transcript)}
+
+	fmt.Fprintf(os.Stderr, "Running %s in %s (run %s)\n\n", runAgent, name, filepath.Base(runDir))
+	runErr := sb.rt.Exec(ctx, name, command, runtime.ExecOptions{
+		Dir:    "/workspace",
+		Stdin:  bytes.NewReader(prompt),
+		Stdout: io.MultiWriter(os.Stdout, transcriptLog),
+		Stderr: io.MultiWriter(os.Stderr, transcriptLog),
+	})
+	if err := transcriptLog.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write transcript: %v\n", err)
+	}
+	elapsed := time.Since(started)
+	result.Duration = elapsed.Round(100 * time.Millisecond).Seconds()
+
+	var exitErr *runtime.ExitError
+	switch {
+	case runErr == nil:
+		result.Status = runSucceeded
+	case errors.As(runErr, &exitErr):
+		result.ExitCode = exitErr.Code
+		result.Status = runFailed
+		if runTimeout > 0 && elapsed >= runTimeout && (exitErr.Code == timeoutExitCode || exitErr.Code == 128+9) {
+			result.Status = runTimedOut
+			result.ExitCode = timeoutExitCode
+		}
+	default:
+		result.Status = runFailed
+		result.ExitCode = -1
+		result.Error = runErr.Error()
+	}
+
+	result.Network = sb.network.Summary()
+	result.FilesChanged = diffFileStates(before, scanMounts(mounts))
+	result.Artifacts = collectArtifacts(ctx, sb.rt, name, mounts, result.FilesChanged, filepath.Join(runDir, "artifacts"))
+
+	sb.Close()
+	if runStopVM {
+		fmt.Fprintf(os.Stderr, "Stopping VM: %s\n", sb.rt.InstanceName(name))
+		if err := sb.rt.Stop(ctx, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to stop VM: %v\n", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	resultPaths := []string{filepath.Join(runDir, "result.json")}
+	if runResultPath != "" {
+		resultPaths = append(resultPaths, runResultPath)
+	}
+	for _, path := range resultPaths {
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+	}
+
+	changed := len(result.FilesChanged.Added) + len(result.FilesChanged.Modified) + len(result.FilesChanged.Deleted)
+	fmt.Fprintf(os.Stderr, "\nRun %s: %s (exit %d) in %s, %d file(s) changed, %d artifact(s)\n",
+		filepath.Base(runDir), result.Status, result.ExitCode, elapsed.Round(time.Second), changed, len(result.Artifacts))
+	fmt.Fprintf(os.Stderr, "Result: %s\n", resultPaths[0])
+
+	switch {
+	case result.Error != "":
+		return fmt.Errorf("run error: %w", runErr)
+	case result.ExitCode != 0:
+		// The agent's exit code is agentbox's; the summary already says why
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &runtime.ExitError{Code: result.ExitCode}
+	}
+	return nil
+}
+
+// readPrompt returns the task from --prompt or --prompt-file
+func readPrompt(prompt, file string) ([]byte, error) {
+	switch {
+	case prompt != "" && file != "":
+		return nil, errors.New("use either --prompt or --prompt-file, not both")
+	case prompt != "":
+		return []byte(prompt), nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt: %w", err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, fmt.Errorf("prompt file %s is empty", file)
+		}
+		return data, nil
+	}
+	return nil, errors.New("a task is required (use --prompt or --prompt-file)")
+}
+
+// fileState identifies a version of a file
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// scanMounts records the files in writable mounts, keyed by guest path
+// .git directories are skipped; unreadable entries are left out
+func scanMounts(mounts []lima.Mount) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, m := range mounts {
+		if !m.Writable {
+			continue
+		}
+		filepath.WalkDir(m.Location, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			rel, err := filepath.Rel(m.Location, path)
+			if err != nil {
+				return nil
+			}
+			guest := filepath.ToSlash(filepath.Join(m.MountPoint, rel))
+			files[guest] = fileState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return files
+}
+
+// diffFileStates compares two scans
+func diffFileStates(before, after map[string]fileState) fileChanges {
+	changes := fileChanges{Added: []string{}, Modified: []string{}, Deleted: []string{}}
+	for path, st := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case old.size != st.size || !old.modTime.Equal(st.modTime):
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+	return changes
+}
+
+// collectArtifacts copies the run's new and changed files in /artifacts
+// into dir and returns their paths relative to it. Without an /artifacts
+// mount the guest directory is copied out whole.
+func collectArtifacts(ctx context.Context, rt runtime.Runtime, name string, mounts []lima.Mount, changes fileChanges, dir string) []string {
+	const guestArtifacts = "/artifacts"
+
+	var mount *lima.Mount
+	for i := range mounts {
+		if mounts[i].MountPoint == guestArtifacts {
+			mount = &mounts[i]
+		}
+	}
+
+	collected := []string{}
+	if mount == nil {
+		if err := rt.CopyFrom(ctx, name, guestArtifacts, dir); err != nil {
+			return collected
+		}
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				rel, _ := filepath.Rel(dir, path)
+				collected = append(collected, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		return collected
+	}
+
+	for _, guest := range append(append([]string{}, changes.Added...), changes.Modified...) {
+		rel, ok := strings.CutPrefix(guest, guestArtifacts+"/")
+		if !ok {
+			continue
+		}
+		if err := copyFile(filepath.Join(mount.Location, filepath.FromSlash(rel)), filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to collect artifact %s: %v\n", rel, err)
+			continue
+		}
+		collected = append(collected, rel)
+	}
+	sort.Strings(collected)
+	return collected
+}
+
+// copyFile copies a regular file, creating dst's directory
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// lockedWriter serializes writes to a WriteCloser shared by the agent's
+// stdout and stderr, which runtimes may copy concurrently
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+func (lw *lockedWriter) Close() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Close()
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/runtime"
+)
+
+// newRuntime returns the runtime selected by the project's config
+func newRuntime(cfg *config.Config) (runtime.Runtime, error) {
+	rt, err := runtime.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up runtime: %w", err)
+	}
+	return rt, nil
+}
+
+// projectRuntime is newRuntime for a project that may not have a config;
+// it falls back to the defaults
+func projectRuntime(name string) (runtime.Runtime, error) {
+	if !config.Exists(name) {
+		return newRuntime(config.DefaultConfig())
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return newRuntime(cfg)
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanFormat    string
+	scanGitignore bool
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <name>",
+	Short: "Scan workspace and artifacts for secrets",
+	Long: `Scan an AgentBox project's workspace/ and artifacts/ for secrets.
+
+Anything in these directories is visible to the agent. This command
+walks both with the built-in secret detectors (see secrets.detectors)
+and also looks for the exact values of your configured host secrets.
+
+Findings are reported with file, line, detector and a redacted preview.
+Exits with status 1 if anything is found.
+
+Example:
+  agentbox scan myproject
+  agentbox scan myproject --gitignore      # Skip files ignored by .gitignore
+  agentbox scan myproject --format sarif > results.sarif`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScan,
+}
+
+func init() {
+	scanCmd.Flags().StringVar(&scanFormat, "format", "text", "output format: text, json, or sarif")
+	scanCmd.Flags().BoolVar(&scanGitignore, "gitignore", false, "skip files ignored by .gitignore")
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	switch scanFormat {
+	case "text", "json", "sarif":
+	default:
+		return fmt.Errorf("unknown format %q (use text, json, or sarif)", scanFormat)
+	}
+
+	findings, err := scanProject(name, cfg, scanGitignore)
+	if err != nil {
+		return err
+	}
+
+	switch scanFormat {
+	case "json":
+		err = writeFindingsJSON(os.Stdout, findings)
+	case "sarif":
+		err = writeFindingsSARIF(os.Stdout, findings)
+	default:
+		writeFindingsText(os.Stdout, findings)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d potential secret(s)", len(findings))
+	}
+	return nil
+}
+
+// scanProject scans a project's workspace and artifacts directories
+func scanProject(name string, cfg *config.Config, respectGitignore bool) ([]secrets.Finding, error) {
+	detectors, err := secrets.EnabledDetectors(cfg.Secrets.Detectors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets.detectors: %w", err)
+	}
+
+	resolver, err := secrets.NewResolver(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up secret store: %w", err)
+	}
+	hostValues, err := hostSecretValues(cfg, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := secrets.NewScanner(detectors)
+	scanner.RespectGitignore = respectGitignore
+	scanner.AddLiterals(hostValues)
+
+	findings, err := scanner.Scan(name,
+		filepath.Join(name, "workspace"),
+		filepath.Join(name, "artifacts"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan project: %w", err)
+	}
+	return findings, nil
+}
+
+// preflightScan runs the configured secrets.preflight check before entering
+// Only high-confidence findings count; "warn" reports them, "refuse" aborts
+func preflightScan(name string, cfg *config.Config) error {
+	mode := cfg.Secrets.Preflight
+	if mode == "" || mode == "off" {
+		return nil
+	}
+	if mode != "warn" && mode != "refuse" {
+		return fmt.Errorf("invalid secrets.preflight %q (use off, warn, or refuse)", mode)
+	}
+
+	findings, err := scanProject(name, cfg, false)
+	if err != nil {
+		return err
+	}
+
+	var high []secrets.Finding
+	for _, f := range findings {
+		if f.Confidence == secrets.ConfidenceHigh {
+			high = append(high, f)
+		}
+	}
+	if len(high) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: workspace contains %d high-confidence secret(s) the agent can read:\n", len(high))
+	for i, f := range high {
+		if i == 5 {
+			fmt.Fprintf(os.Stderr, "  ... and %d more (run 'agentbox scan %s')\n", len(high)-5, name)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "  %s:%d  %s\n", f.Path, f.Line, f.Detector)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if mode == "refuse" {
+		return fmt.Errorf("refusing to enter: remove the secrets or set secrets.preflight to warn")
+	}
+	return nil
+}
+
+func writeFindingsText(w io.Writer, findings []secrets.Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No secrets found.")
+		return
+	}
+
+	high := 0
+	for _, f := range findings {
+		if f.Confidence == secrets.ConfidenceHigh {
+			high++
+		}
+		fmt.Fprintf(w, "%s:%d:%d  %s (%s)\n", f.Path, f.Line, f.Column, f.Detector, f.Confidence)
+		fmt.Fprintf(w, "    %s\n", f.Preview)
+	}
+	fmt.Fprintf(w, "\nFound %d potential secret(s), %d high confidence.\n", len(findings), high)
+}
+
+func writeFindingsJSON(w io.Writer, findings []secrets.Finding) error {
+	if findings == nil {
+		findings = []secrets.Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+// SARIF 2.1.0 types (only the fields we emit)
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+func writeFindingsSARIF(w io.Writer, findings []secrets.Finding) error {
+	rules := []sarifRule{}
+	seen := make(map[string]bool)
+	results := make([]sarifResult, 0, len(findings))
+
+	for _, f := range findings {
+		if !seen[f.Detector] {
+			seen[f.Detector] = true
+			rules = append(rules, sarifRule{
+				ID:               f.Detector,
+				ShortDescription: sarifMessage{Text: "Potential secret (" + f.Detector + ")"},
+			})
+		}
+
+		level := "warning"
+		if f.Confidence == secrets.ConfidenceHigh {
+			level = "error"
+		} else if f.Confidence == secrets.ConfidenceLow {
+			level = "note"
+		}
+
+		results = append(results, sarifResult{
+			RuleID:  f.Detector,
+			Level:   level,
+			Message: sarifMessage{Text: f.Preview},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: f.Path},
+					Region:           sarifRegion{StartLine: f.Line, StartColumn: f.Column},
+				},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "agentbox",
+				Version:        versionStr,
+				InformationURI: "https://github.com/davidsenack/agentbox",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/davidsenack/agentbox/internal/session"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	secretProject string
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the encrypted secret store",
+	Long: `Manage secrets in agentbox's encrypted store.
+
+Secrets live under the agentbox config directory (e.g., ~/.config/agentbox),
+in a global store shared by all projects or a per-project store selected
+with --project. Values are encrypted with a key derived from
+AGENTBOX_PASSPHRASE or, if it isn't set when a store is created, a random
+host keyfile.
+
+Reference a stored secret from agentbox.yaml with secret://name:
+  network.inject_auth[].env:   secret://openai
+  secrets.allowed_env_vars:    GH_TOKEN=secret://github
+
+Project secrets take precedence over global ones with the same name.
+Rotating a secret updates running 'agentbox enter' sessions in place.
+
+Example:
+  agentbox secret set openai                     # Prompts for the value
+  echo "$TOKEN" | agentbox secret set github --project myproject
+  agentbox secret list
+  agentbox secret rotate openai
+  agentbox secret rm openai`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret (value read from stdin)",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretSet,
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret's value",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretGet,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List stored secrets (names only)",
+	Args:  cobra.NoArgs,
+	RunE:  runSecretList,
+}
+
+var secretRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretRm,
+}
+
+var secretRotateCmd = &cobra.Command{
+	Use:   "rotate <name>",
+	Short: "Replace a secret's value and update running sessions",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSecretRotate,
+}
+
+func init() {
+	secretCmd.PersistentFlags().StringVar(&secretProject, "project", "", "use the project's store instead of the global one")
+
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretGetCmd)
+	secretCmd.AddCommand(secretListCmd)
+	secretCmd.AddCommand(secretRmCmd)
+	secretCmd.AddCommand(secretRotateCmd)
+}
+
+func runSecretSet(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+
+	value, err := readSecretValue(name)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Set(name, value); err != nil {
+		return err
+	}
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("failed to save secret store: %w", err)
+	}
+
+	fmt.Printf("Stored secret %q (%s)\n", name, secretScope())
+	notifySessions()
+	return nil
+}
+
+func runSecretGet(cmd *cobra.Command, args []string) error {
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+
+	value, err := store.Get(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func runSecretList(cmd *cobra.Command, args []string) error {
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+
+	infos := store.List()
+	if len(infos) == 0 {
+		fmt.Printf("No secrets in the %s store.\n", secretScope())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUPDATED\tROTATED")
+	for _, info := range infos {
+		rotated := "-"
+		if !info.RotatedAt.IsZero() {
+			rotated = info.RotatedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", info.Name, info.UpdatedAt.Local().Format("2006-01-02 15:04"), rotated)
+	}
+	return w.Flush()
+}
+
+func runSecretRm(cmd *cobra.Command, args []string) error {
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+
+	if !store.Delete(args[0]) {
+		return fmt.Errorf("%s: %w", args[0], secrets.ErrSecretNotFound)
+	}
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("failed to save secret store: %w", err)
+	}
+
+	fmt.Printf("Removed secret %q (%s)\n", args[0], secretScope())
+	notifySessions()
+	return nil
+}
+
+func runSecretRotate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	store, err := openSecretStore()
+	if err != nil {
+		return err
+	}
+	if _, err := store.Get(name); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	value, err := readSecretValue(name)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Rotate(name, value); err != nil {
+		return err
+	}
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("failed to save secret store: %w", err)
+	}
+
+	fmt.Printf("Rotated secret %q (%s)\n", name, secretScope())
+	notifySessions()
+	return nil
+}
+
+// openSecretStore opens the store selected by --project
+func openSecretStore() (*secrets.Store, error) {
+	var path string
+	var err error
+	if secretProject != "" {
+		if !config.Exists(secretProject) {
+			return nil, fmt.Errorf("project %q does not exist (no agentbox.yaml found)", secretProject)
+		}
+		path, err = secrets.ProjectStorePath(secretProject)
+	} else {
+		path, err = secrets.GlobalStorePath()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate secret store: %w", err)
+	}
+
+	store, err := secrets.OpenStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secret store: %w", err)
+	}
+	return store, nil
+}
+
+func secretScope() string {
+	if secretProject != "" {
+		return "project " + filepath.Base(secretProject)
+	}
+	return "global"
+}
+
+// readSecretValue reads a value from stdin, prompting without echo if it's
+// a terminal. Only the trailing newline is stripped
+func readSecretValue(name string) (string, error) {
+	var value string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value: %w", err)
+		}
+		value = string(data)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read value: %w", err)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	if value == "" {
+		return "", fmt.Errorf("empty value for %s", name)
+	}
+	return value, nil
+}
+
+// sessionRegistryDir holds a PID file for every running 'agentbox enter'
+// so secret changes can be pushed to their proxies
+func sessionRegistryDir() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "run", "sessions"), nil
+}
+
+// notifySessions asks running sessions to reload their secrets (SIGHUP)
+// Only registered PIDs still held by the process that registered them are
+// signalled, so a PID reused after a crash is left alone
+func notifySessions() {
+	dir, err := sessionRegistryDir()
+	if err != nil {
+		return
+	}
+	pids, err := session.Active(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to list running sessions: %v\n", err)
+		return
+	}
+
+	notified := 0
+	for _, pid := range pids {
+		proc, err := os.FindProcess(pid)
+		if err != nil {
+			continue
+		}
+		if err := proc.Signal(syscall.SIGHUP); err == nil {
+			notified++
+		}
+	}
+	if notified > 0 {
+		fmt.Printf("Updated %d running session(s)\n", notified)
+	}
+}
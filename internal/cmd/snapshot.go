@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotNote string
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore a box's VM state",
+	Long: `Save and restore named snapshots of a box's VM.
+
+A snapshot captures the guest state (the VM's disk, including installed
+tools and the agent's home) while the VM is stopped. workspace/ and
+artifacts/ live on the host and are not part of it. Snapshots are stored
+under .agentbox/snapshots in the project, with the time they were taken,
+an optional note and their size.
+
+Restoring puts the VM back exactly as it was saved. 'agentbox reset
+<name> --to <snapshot>' recreates the VM and then restores a snapshot.
+
+Example:
+  agentbox stop myproject
+  agentbox snapshot save myproject tooled --note "node + python set up"
+  agentbox snapshot list myproject
+  agentbox snapshot restore myproject tooled
+  agentbox snapshot rm myproject tooled`,
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save <name> <snapshot>",
+	Short: "Save the stopped VM's state",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSnapshotSave,
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <name> <snapshot>",
+	Short: "Restore the stopped VM to a saved state",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSnapshotRestore,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list <name>",
+	Short: "List a project's snapshots",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotList,
+}
+
+var snapshotRmCmd = &cobra.Command{
+	Use:   "rm <name> <snapshot>",
+	Short: "Delete a snapshot",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSnapshotRm,
+}
+
+func init() {
+	snapshotSaveCmd.Flags().StringVar(&snapshotNote, "note", "", "note to store with the snapshot")
+
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRmCmd)
+}
+
+// snapshotDir returns a project's snapshots directory
+func snapshotDir(name string) (string, error) {
+	if !config.Exists(name) {
+		return "", fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return snapshot.Dir(filepath.Join(absPath, ".agentbox")), nil
+}
+
+// requireStopped fails unless the project's VM exists and is stopped
+func requireStopped(ctx context.Context, rt runtime.Runtime, name string) error {
+	status, err := rt.Status(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check VM status: %w", err)
+	}
+	switch status {
+	case runtime.StatusNotCreated:
+		return fmt.Errorf("VM %s does not exist", rt.InstanceName(name))
+	case runtime.StatusRunning:
+		return fmt.Errorf("VM %s is running; run 'agentbox stop %s' first", rt.InstanceName(name), name)
+	}
+	return nil
+}
+
+func runSnapshotSave(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	name, snapName := args[0], args[1]
+
+	dir, err := snapshotDir(name)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.ValidateName(snapName); err != nil {
+		return err
+	}
+	if _, err := snapshot.Get(dir, snapName); err == nil {
+		return fmt.Errorf("snapshot %q already exists (remove it first with 'agentbox snapshot rm %s %s')", snapName, name, snapName)
+	}
+
+	rt, err := projectRuntime(name)
+	if err != nil {
+		return err
+	}
+	if err := requireStopped(ctx, rt, name); err != nil {
+		return err
+	}
+
+	path := snapshot.Path(dir, snapName)
+	os.RemoveAll(path) // Leftovers of an interrupted save
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+
+	fmt.Printf("Saving snapshot %s of %s...\n", snapName, rt.InstanceName(name))
+	if err := rt.SaveSnapshot(ctx, name, path); err != nil {
+		os.RemoveAll(path)
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	snap := &snapshot.Snapshot{
+		Name:    snapName,
+		Created: time.Now().UTC(),
+		Note:    snapshotNote,
+		Runtime: rt.Name(),
+	}
+	if err := snapshot.Write(dir, snap); err != nil {
+		os.RemoveAll(path)
+		return err
+	}
+
+	fmt.Printf("Saved snapshot %s (%s)\n", snapName, formatBytes(snap.Size))
+	return nil
+}
+
+func runSnapshotRestore(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	name, snapName := args[0], args[1]
+
+	dir, err := snapshotDir(name)
+	if err != nil {
+		return err
+	}
+	snap, err := snapshot.Get(dir, snapName)
+	if err != nil {
+		return err
+	}
+
+	rt, err := projectRuntime(name)
+	if err != nil {
+		return err
+	}
+	if err := checkSnapshotRuntime(rt, snap); err != nil {
+		return err
+	}
+	if err := requireStopped(ctx, rt, name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring %s to snapshot %s...\n", rt.InstanceName(name), snapName)
+	if err := rt.RestoreSnapshot(ctx, name, snapshot.Path(dir, snapName)); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	fmt.Printf("Restored snapshot %s\n", snapName)
+	fmt.Printf("\nRun 'agentbox enter %s' to continue.\n", name)
+	return nil
+}
+
+// checkSnapshotRuntime refuses snapshots saved by a different runtime
+func checkSnapshotRuntime(rt runtime.Runtime, snap *snapshot.Snapshot) error {
+	if snap.Runtime != rt.Name() {
+		return fmt.Errorf("snapshot %s was saved by the %s runtime, but this project uses %s", snap.Name, snap.Runtime, rt.Name())
+	}
+	return nil
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) error {
+	dir, err := snapshotDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshot.List(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("No snapshots for %s.\n", args[0])
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tSIZE\tNOTE")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, s.Created.Local().Format("2006-01-02 15:04"), formatBytes(s.Size), s.Note)
+	}
+	return w.Flush()
+}
+
+func runSnapshotRm(cmd *cobra.Command, args []string) error {
+	name, snapName := args[0], args[1]
+
+	dir, err := snapshotDir(name)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Remove(dir, snapName); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed snapshot %s\n", snapName)
+	return nil
+}
+
+// formatBytes renders a size like "1.5 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,519 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"github.com/davidsenack/agentbox/internal/session"
+	"github.com/davidsenack/agentbox/internal/snapshot"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	statusJSON   bool
+	statusOutput string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Short: "Show a box's status, resources and provisioning results",
+	Long: `Show everything about a box in one place:
+
+  - VM state, uptime, CPUs, memory and disk usage
+  - image and guest OS
+  - how the last provisioning run went (failed steps with their stderr)
+  - proxy state and port, and active sessions
+  - which secrets agentbox would inject resolve on this host (never values)
+  - last network activity and workspace size
+
+When the VM is running the provisioning log is refreshed from the guest;
+it's kept in .agentbox/provision-steps.jsonl, next to the full output in
+.agentbox/provision.log. Use --json or --output yaml for scripts.
+
+Example:
+  agentbox status myproject
+  agentbox status myproject --json
+  agentbox status myproject --output yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStatus,
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print JSON (same as --output json)")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "text", "output format: text, json or yaml")
+}
+
+// statusReport is what 'agentbox status' prints; the JSON and YAML field
+// names are stable for scripts
+type statusReport struct {
+	Project      string              `json:"project" yaml:"project"`
+	Runtime      string              `json:"runtime" yaml:"runtime"`
+	VM           vmStatus            `json:"vm" yaml:"vm"`
+	Provisioning *provisioningStatus `json:"provisioning" yaml:"provisioning"`
+	Proxy        proxyStatus         `json:"proxy" yaml:"proxy"`
+	Sessions     []int               `json:"sessions" yaml:"sessions"`
+	Secrets      []secretStatus      `json:"secrets" yaml:"secrets"`
+	Network      networkStatus       `json:"network" yaml:"network"`
+	Workspace    workspaceStatus     `json:"workspace" yaml:"workspace"`
+}
+
+type vmStatus struct {
+	Name          string `json:"name" yaml:"name"`
+	State         string `json:"state" yaml:"state"`
+	UptimeSeconds int64  `json:"uptime_seconds,omitempty" yaml:"uptime_seconds,omitempty"`
+	CPUs          int    `json:"cpus" yaml:"cpus"`
+	Memory        string `json:"memory" yaml:"memory"`
+	Disk          string `json:"disk" yaml:"disk"`
+	DiskUsed      int64  `json:"disk_used_bytes,omitempty" yaml:"disk_used_bytes,omitempty"`
+	DiskSize      int64  `json:"disk_size_bytes,omitempty" yaml:"disk_size_bytes,omitempty"`
+	Image         string `json:"image,omitempty" yaml:"image,omitempty"`
+	OS            string `json:"os,omitempty" yaml:"os,omitempty"`
+}
+
+type provisioningStatus struct {
+	Steps   int         `json:"steps" yaml:"steps"`
+	Changed int         `json:"changed" yaml:"changed"`
+	OK      int         `json:"ok" yaml:"ok"`
+	Failed  int         `json:"failed" yaml:"failed"`
+	Failing []lima.Step `json:"failed_steps,omitempty" yaml:"failed_steps,omitempty"`
+	Log     string      `json:"log" yaml:"log"`
+}
+
+type proxyStatus struct {
+	Port  int    `json:"port" yaml:"port"`
+	State string `json:"state" yaml:"state"` // running, stopped or "port in use"
+}
+
+type secretStatus struct {
+	Name   string `json:"name" yaml:"name"`
+	Ref    string `json:"ref" yaml:"ref"`
+	Status string `json:"status" yaml:"status"` // resolved, missing or error
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type networkStatus struct {
+	LastActivity string `json:"last_activity,omitempty" yaml:"last_activity,omitempty"`
+	Log          string `json:"log" yaml:"log"`
+}
+
+type workspaceStatus struct {
+	Path  string `json:"path" yaml:"path"`
+	Bytes int64  `json:"bytes" yaml:"bytes"`
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	output := statusOutput
+	if statusJSON {
+		output = "json"
+	}
+	switch output {
+	case "text", "json", "yaml":
+	default:
+		return fmt.Errorf("invalid --output %q (use text, json or yaml)", output)
+	}
+
+	if !config.Exists(name) {
+		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	absPath, err := filepath.Abs(name)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rt, err := newRuntime(cfg)
+	if err != nil {
+		return err
+	}
+
+	report, err := buildStatus(cmd.Context(), rt, name, absPath, cfg)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+	default:
+		printStatus(os.Stdout, report)
+	}
+	return nil
+}
+
+// buildStatus gathers a box's status from the runtime, the guest and the
+// project's .agentbox dir
+func buildStatus(ctx context.Context, rt runtime.Runtime, name, absPath string, cfg *config.Config) (*statusReport, error) {
+	stateDir := filepath.Join(absPath, ".agentbox")
+
+	state, err := rt.Status(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check VM status: %w", err)
+	}
+
+	report := &statusReport{
+		Project: name,
+		Runtime: rt.Name(),
+		VM: vmStatus{
+			Name:   rt.InstanceName(name),
+			State:  string(state),
+			CPUs:   cfg.VM.CPUs,
+			Memory: cfg.VM.Memory,
+			Disk:   cfg.VM.Disk,
+		},
+		Sessions: []int{},
+		Secrets:  []secretStatus{},
+	}
+
+	// The template holds what the VM was created (or resized) with
+	if data, err := os.ReadFile(filepath.Join(stateDir, "lima.yaml")); err == nil {
+		if vm, err := lima.ReadTemplateVM(data); err == nil {
+			report.VM.CPUs, report.VM.Memory, report.VM.Disk = vm.CPUs, vm.Memory, vm.Disk
+		}
+	}
+
+	if state == runtime.StatusRunning {
+		guestStatus(ctx, rt, name, &report.VM)
+		fetchProvisionLogs(ctx, rt, name, stateDir)
+	}
+
+	steps, err := readStepLog(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	if steps != nil {
+		p := &provisioningStatus{
+			Steps:   len(steps),
+			Failing: lima.FailedSteps(steps),
+			Log:     filepath.Join(name, ".agentbox", lima.ProvisionLogFile),
+		}
+		for _, step := range steps {
+			switch step.Status {
+			case lima.StepChanged:
+				p.Changed++
+			case lima.StepOK:
+				p.OK++
+			case lima.StepFailed:
+				p.Failed++
+			}
+		}
+		report.Provisioning = p
+	}
+
+	if pids, err := session.Active(session.Dir(stateDir)); err == nil && pids != nil {
+		report.Sessions = pids
+	}
+
+	report.Proxy = proxyStatus{Port: cfg.Network.ProxyPort, State: "stopped"}
+	if conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", cfg.Network.ProxyPort), 200*time.Millisecond); err == nil {
+		conn.Close()
+		// enter runs the proxy, so without a session the port is someone else's
+		report.Proxy.State = "port in use"
+		if len(report.Sessions) > 0 {
+			report.Proxy.State = "running"
+		}
+	}
+
+	resolver, err := secrets.NewResolver(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up secret store: %w", err)
+	}
+	refs := secretRefs(cfg)
+	labels := make([]string, 0, len(refs))
+	for label := range refs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		s := secretStatus{Name: label, Ref: refs[label], Status: "resolved"}
+		val, err := resolver.Lookup(s.Ref)
+		switch {
+		case err != nil:
+			s.Status, s.Error = "error", err.Error()
+		case val == "":
+			s.Status = "missing"
+		}
+		report.Secrets = append(report.Secrets, s)
+	}
+
+	networkLog := filepath.Join(stateDir, "network.log")
+	report.Network = networkStatus{
+		LastActivity: lastLine(networkLog),
+		Log:          filepath.Join(name, ".agentbox", "network.log"),
+	}
+
+	workspace := filepath.Join(absPath, "workspace")
+	report.Workspace.Path = filepath.Join(name, "workspace")
+	if size, err := snapshot.DiskUsage(workspace); err == nil {
+		report.Workspace.Bytes = size
+	}
+
+	return report, nil
+}
+
+// guestStatusScript prints key=value lines about the running guest
+const guestStatusScript = `echo "uptime=$(cut -d' ' -f1 /proc/uptime)"
+echo "disk=$(df -B1 --output=used,size / | tail -1)"
+echo "os=$(. /etc/os-release && echo "$PRETTY_NAME")"
+if [ -f /etc/agentbox-image ]; then echo "image=$(head -2 /etc/agentbox-image | tr '\n' ' ')"; fi`
+
+// guestStatus fills in uptime, disk usage and image from inside the guest
+// Anything the guest can't report is left empty
+func guestStatus(ctx context.Context, rt runtime.Runtime, name string, vm *vmStatus) {
+	var out bytes.Buffer
+	if err := rt.Exec(ctx, name, []string{"sh", "-c", guestStatusScript}, runtime.ExecOptions{Stdout: &out, Stderr: io.Discard}); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "uptime":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil {
+				vm.UptimeSeconds = int64(secs)
+			}
+		case "disk":
+			if fields := strings.Fields(value); len(fields) == 2 {
+				vm.DiskUsed, _ = strconv.ParseInt(fields[0], 10, 64)
+				vm.DiskSize, _ = strconv.ParseInt(fields[1], 10, 64)
+			}
+		case "os":
+			vm.OS = value
+		case "image":
+			vm.Image = value
+		}
+	}
+	if vm.Image == "" && vm.OS != "" {
+		vm.Image = "stock " + vm.OS
+	}
+}
+
+// printStatus renders a report for people
+func printStatus(w io.Writer, r *statusReport) {
+	fmt.Fprintf(w, "Project:   %s\n", r.Project)
+	fmt.Fprintf(w, "VM:        %s (%s)\n", r.VM.Name, r.Runtime)
+	state := r.VM.State
+	if r.VM.UptimeSeconds > 0 {
+		state += ", up " + (time.Duration(r.VM.UptimeSeconds) * time.Second).String()
+	}
+	fmt.Fprintf(w, "Status:    %s\n", state)
+	fmt.Fprintf(w, "Resources: %d CPUs, %s memory, %s disk", r.VM.CPUs, r.VM.Memory, r.VM.Disk)
+	if r.VM.DiskSize > 0 {
+		fmt.Fprintf(w, " (%s of %s used)", formatBytes(r.VM.DiskUsed), formatBytes(r.VM.DiskSize))
+	}
+	fmt.Fprintln(w)
+	if r.VM.Image != "" {
+		fmt.Fprintf(w, "Image:     %s\n", r.VM.Image)
+	}
+
+	fmt.Fprintf(w, "Proxy:     %s on port %d\n", r.Proxy.State, r.Proxy.Port)
+	fmt.Fprintf(w, "Sessions:  %d active\n", len(r.Sessions))
+	fmt.Fprintf(w, "Workspace: %s (%s)\n", r.Workspace.Path, formatBytes(r.Workspace.Bytes))
+
+	if len(r.Secrets) > 0 {
+		fmt.Fprintln(w, "\nSecrets:")
+		for _, s := range r.Secrets {
+			line := fmt.Sprintf("  %-8s  %s", s.Status, s.Name)
+			if s.Ref != s.Name {
+				line += " (" + s.Ref + ")"
+			}
+			if s.Error != "" {
+				line += ": " + s.Error
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	fmt.Fprintln(w)
+	if r.Network.LastActivity != "" {
+		fmt.Fprintf(w, "Last network activity: %s\n", r.Network.LastActivity)
+	} else {
+		fmt.Fprintln(w, "No network activity logged")
+	}
+
+	if r.Provisioning == nil {
+		fmt.Fprintln(w, "\nNo provisioning log yet")
+		return
+	}
+	p := r.Provisioning
+	fmt.Fprintf(w, "\nProvisioning: %d steps (%d changed, %d ok, %d failed)\n", p.Steps, p.Changed, p.OK, p.Failed)
+	printFailedSteps(w, p.Failing)
+	fmt.Fprintf(w, "Log: %s\n", p.Log)
+}
+
+// lastLine returns the last non-empty line of a file, or "" if there's none
+func lastLine(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	return lines[len(lines)-1]
+}
+
+// fetchProvisionLogs copies the guest's provisioning logs into stateDir,
+// redacted like the log 'agentbox provision' writes
+// Runtimes without guest logs (or a guest that hasn't provisioned yet)
+// leave the previous copies alone
+func fetchProvisionLogs(ctx context.Context, rt runtime.Runtime, name, stateDir string) {
+	redactor, err := projectRedactor(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not fetching provisioning logs: %v\n", err)
+		return
+	}
+	_ = fetchRedacted(ctx, rt, name, lima.GuestStepLog, filepath.Join(stateDir, lima.StepLogFile), redactor)
+	_ = fetchRedacted(ctx, rt, name, lima.GuestProvisionLog, filepath.Join(stateDir, lima.ProvisionLogFile), redactor)
+}
+
+// fetchRedacted copies a guest log next to dst, redacts it and renames it
+// into place, so dst never holds the raw guest copy
+// Step logs are redacted per field, keeping every line valid JSON
+func fetchRedacted(ctx context.Context, rt runtime.Runtime, name, guestPath, dst string, redactor *secrets.Redactor) error {
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dst), ".fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	raw := filepath.Join(tmpDir, "raw")
+	if err := rt.CopyFrom(ctx, name, guestPath, raw); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(raw)
+	if err != nil {
+		return err
+	}
+
+	var redacted []byte
+	if guestPath == lima.GuestStepLog {
+		steps, err := lima.ParseStepLog(data)
+		if err != nil {
+			return err
+		}
+		for _, step := range steps {
+			for i, line := range step.StderrTail {
+				step.StderrTail[i] = redactor.Redact(line)
+			}
+			step.Step = redactor.Redact(step.Step)
+			line, err := json.Marshal(step)
+			if err != nil {
+				return err
+			}
+			redacted = append(append(redacted, line...), '\n')
+		}
+	} else {
+		redacted = []byte(redactor.Redact(string(data)))
+	}
+
+	out := filepath.Join(tmpDir, "redacted")
+	if err := os.WriteFile(out, redacted, 0600); err != nil {
+		return err
+	}
+	return os.Rename(out, dst)
+}
+
+// projectRedactor builds the redactor for a project's secrets
+func projectRedactor(name string) (*secrets.Redactor, error) {
+	cfg, err := config.Load(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	resolver, err := secrets.NewResolver(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up secret store: %w", err)
+	}
+	return newRedactor(cfg, resolver)
+}
+
+// readStepLog parses the step log kept in stateDir; it's nil if there's none
+func readStepLog(stateDir string) ([]lima.Step, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, lima.StepLogFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provisioning step log: %w", err)
+	}
+	steps, err := lima.ParseStepLog(data)
+	if err != nil {
+		return nil, err
+	}
+	if steps == nil {
+		steps = []lima.Step{}
+	}
+	return steps, nil
+}
+
+// printFailedSteps lists the failed steps with the tail of their stderr
+func printFailedSteps(w io.Writer, steps []lima.Step) {
+	for _, step := range lima.FailedSteps(steps) {
+		fmt.Fprintf(w, "  FAILED  %s/%s (exit %d, %ds)\n", step.Section, step.Step, step.ExitCode, step.Duration)
+		for _, line := range step.StderrTail {
+			fmt.Fprintf(w, "          | %s\n", line)
+		}
+	}
+}
+
+// reportProvisioning fetches the guest logs after a boot and points out
+// failed steps; a failed boot also points to the full log
+func reportProvisioning(ctx context.Context, rt runtime.Runtime, name, stateDir string, bootErr error) {
+	fetchProvisionLogs(ctx, rt, name, stateDir)
+	steps, err := readStepLog(stateDir)
+	if err != nil || len(lima.FailedSteps(steps)) == 0 {
+		return
+	}
+
+	if bootErr != nil {
+		fmt.Fprintln(os.Stderr, "Provisioning failed:")
+	} else {
+		fmt.Fprintln(os.Stderr, "Warning: some provisioning steps failed:")
+	}
+	printFailedSteps(os.Stderr, steps)
+	fmt.Fprintf(os.Stderr, "Full log: %s (see also 'agentbox status %s')\n",
+		filepath.Join(name, ".agentbox", lima.ProvisionLogFile), name)
+}
+
+// clearProvisionLogs drops the provisioning logs of a VM that's been replaced
+func clearProvisionLogs(stateDir string) {
+	for _, file := range []string{lima.StepLogFile, lima.ProvisionLogFile} {
+		if err := os.Remove(filepath.Join(stateDir, file)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: failed to clear %s: %v\n", file, err)
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 
 	"github.com/davidsenack/agentbox/internal/config"
-	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -24,31 +24,41 @@ Example:
 
 func runStop(cmd *cobra.Command, args []string) error {
 	name := args[0]
+	ctx := cmd.Context()
 
 	// Check if project exists
 	if !config.Exists(name) {
 		return fmt.Errorf("project %q does not exist (no agentbox.yaml found)", name)
 	}
 
-	mgr := lima.NewManager()
-	vmName := lima.VMName(name)
-
-	if !mgr.Exists(vmName) {
-		return fmt.Errorf("Lima VM %q does not exist", vmName)
+	rt, err := projectRuntime(name)
+	if err != nil {
+		return err
 	}
+	vmName := rt.InstanceName(name)
 
-	running, err := mgr.IsRunning(vmName)
+	status, err := rt.Status(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to check VM status: %w", err)
 	}
 
-	if !running {
+	if status == runtime.StatusNotCreated {
+		return fmt.Errorf("VM %q does not exist", vmName)
+	}
+
+	if status != runtime.StatusRunning {
 		fmt.Printf("VM %s is already stopped\n", vmName)
 		return nil
 	}
 
+	// Secrets live on a tmpfs and vanish with the VM, but wipe explicitly
+	// in case the box was provisioned before that
+	if err := rt.WipeSecrets(ctx, name); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	fmt.Printf("Stopping VM: %s\n", vmName)
-	if err := mgr.Stop(vmName); err != nil {
+	if err := rt.Stop(ctx, name); err != nil {
 		return fmt.Errorf("failed to stop VM: %w", err)
 	}
 
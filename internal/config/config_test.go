@@ -117,3 +117,47 @@ func TestAuthInjection(t *testing.T) {
 		t.Error("expected api.anthropic.com in auth injection config")
 	}
 }
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range ProfileNames() {
+		cfg := DefaultConfig()
+		profile, err := LookupProfile(name)
+		if err != nil {
+			t.Fatalf("profile %s: %v", name, err)
+		}
+		profile.Apply(cfg)
+		if cfg.Toolchains == DefaultConfig().Toolchains && len(cfg.Provision.Npm)+len(cfg.Provision.Pip)+len(cfg.Provision.Go) == 0 {
+			t.Errorf("profile %s changed nothing", name)
+		}
+	}
+
+	cfg := DefaultConfig()
+	profile, _ := LookupProfile("python-ml")
+	profile.Apply(cfg)
+	if cfg.Toolchains.Python != "3.12" || cfg.Toolchains.Go != DefaultConfig().Toolchains.Go {
+		t.Errorf("python-ml should add Python and keep the other defaults, got %+v", cfg.Toolchains)
+	}
+
+	if _, err := LookupProfile("cobol"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestLoadKeepsDefaultToolchains(t *testing.T) {
+	tmpDir := t.TempDir()
+	data := []byte("toolchains:\n  node: \"20\"\n")
+	if err := os.WriteFile(filepath.Join(tmpDir, ConfigFileName), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Toolchains.Node != "20" || cfg.Toolchains.Go != DefaultConfig().Toolchains.Go {
+		t.Errorf("unexpected toolchains: %+v", cfg.Toolchains)
+	}
+	if cfg.Agents != DefaultConfig().Agents {
+		t.Errorf("agent pins should default, got %+v", cfg.Agents)
+	}
+}
@@ -42,3 +42,16 @@ func Exists(projectDir string) bool {
 	_, err := os.Stat(configPath)
 	return err == nil
 }
+
+// UserDir returns the agentbox config directory for the current user
+// AGENTBOX_CONFIG_DIR overrides the platform default (e.g., ~/.config/agentbox)
+func UserDir() (string, error) {
+	if dir := os.Getenv("AGENTBOX_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "agentbox"), nil
+}
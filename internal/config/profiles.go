@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile is a built-in starting point for a project's agentbox.yaml
+type Profile struct {
+	Name        string
+	Description string
+	apply       func(*Config)
+}
+
+// profiles are the built-in profiles, selectable with 'agentbox create --profile'
+// Package versions are pinned so every box built from a profile matches
+var profiles = []Profile{
+	{
+		Name:        "web",
+		Description: "Node.js 22 with TypeScript and pnpm",
+		apply: func(cfg *Config) {
+			cfg.Toolchains.Node = "22"
+			cfg.Provision.Npm = append(cfg.Provision.Npm, "typescript@5.6.3", "pnpm@9.12.3")
+		},
+	},
+	{
+		Name:        "python-ml",
+		Description: "Python 3.12 with numpy, pandas, scikit-learn and JupyterLab; 8GiB RAM",
+		apply: func(cfg *Config) {
+			cfg.Toolchains.Python = "3.12"
+			cfg.VM.Memory = "8GiB"
+			cfg.VM.Disk = "50GiB"
+			cfg.Provision.Pip = append(cfg.Provision.Pip,
+				"numpy==2.1.3", "pandas==2.2.3", "scikit-learn==1.5.2", "matplotlib==3.9.2", "jupyterlab==4.3.0")
+		},
+	},
+	{
+		Name:        "go-backend",
+		Description: "Go 1.23 with gopls, golangci-lint and Postgres/Redis clients",
+		apply: func(cfg *Config) {
+			cfg.Toolchains.Go = "1.23.4"
+			cfg.Provision.Apt = append(cfg.Provision.Apt, "postgresql-client", "redis-tools")
+			cfg.Provision.Go = append(cfg.Provision.Go,
+				"golang.org/x/tools/gopls@v0.16.2", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.62.2")
+		},
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles
+func ProfileNames() []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// LookupProfile returns the named built-in profile
+func LookupProfile(name string) (*Profile, error) {
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(ProfileNames(), ", "))
+}
+
+// Apply layers the profile onto cfg
+func (p *Profile) Apply(cfg *Config) {
+	p.apply(cfg)
+}
@@ -2,24 +2,42 @@ package config
 
 // Config represents the agentbox.yaml configuration
 type Config struct {
-	Runtime string        `yaml:"runtime"`
-	VM      VMConfig      `yaml:"vm"`
-	Network NetworkConfig `yaml:"network"`
-	Secrets SecretsConfig `yaml:"secrets"`
-	Mounts  []MountConfig `yaml:"mounts"`
+	Runtime    string           `yaml:"runtime"`
+	VM         VMConfig         `yaml:"vm"`
+	Network    NetworkConfig    `yaml:"network"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+	Mounts     []MountConfig    `yaml:"mounts"`
+	Toolchains ToolchainsConfig `yaml:"toolchains"`
+	Agents     AgentsConfig     `yaml:"agents"`
+	Provision  ProvisionConfig  `yaml:"provision,omitempty"`
+	Lima       LimaConfig       `yaml:"lima,omitempty"`
 }
 
 // VMConfig defines virtual machine settings
 type VMConfig struct {
-	CPUs   int    `yaml:"cpus"`
-	Memory string `yaml:"memory"`
-	Disk   string `yaml:"disk"`
+	CPUs    int    `yaml:"cpus"`
+	Memory  string `yaml:"memory"`
+	Disk    string `yaml:"disk"`
+	Type    string `yaml:"type,omitempty"`    // vz, qemu, or auto (default: vz on macOS, qemu elsewhere)
+	Arch    string `yaml:"arch,omitempty"`    // aarch64, x86_64, or auto (default: host arch)
+	Rosetta *bool  `yaml:"rosetta,omitempty"` // Rosetta for x86_64 binaries (default: on where vz supports it)
+
+	Images      []ImageConfig `yaml:"images,omitempty"`       // Boot images; empty uses the built-in AgentBox and Ubuntu images
+	ImagePolicy string        `yaml:"image_policy,omitempty"` // verified (default with images) or fallback
+}
+
+// ImageConfig is a VM image for one architecture
+type ImageConfig struct {
+	Location string `yaml:"location"` // URL, or a local path relative to the project
+	Arch     string `yaml:"arch"`     // aarch64 or x86_64
+	Digest   string `yaml:"digest"`   // "sha256:<hex>" or "sha512:<hex>"
 }
 
 // NetworkConfig defines network settings
 type NetworkConfig struct {
-	ProxyPort  int          `yaml:"proxy_port"`
-	InjectAuth []AuthConfig `yaml:"inject_auth"`
+	ProxyPort  int               `yaml:"proxy_port"`
+	InjectAuth []AuthConfig      `yaml:"inject_auth"`
+	AWSSigning *AWSSigningConfig `yaml:"aws_signing,omitempty"`
 }
 
 // AuthConfig defines proxy-injected authentication
@@ -27,20 +45,104 @@ type NetworkConfig struct {
 type AuthConfig struct {
 	Host   string `yaml:"host"`   // Target host (e.g., api.anthropic.com)
 	Header string `yaml:"header"` // Header name (e.g., x-api-key)
-	Env    string `yaml:"env"`    // Host env var to read (e.g., ANTHROPIC_API_KEY)
+	Env    string `yaml:"env"`    // Host env var or secret://name (e.g., ANTHROPIC_API_KEY)
+}
+
+// AWSSigningConfig defines proxy-side SigV4 signing for AWS APIs
+// The guest SDKs use dummy credentials - the proxy strips their signature
+// and re-signs allowlisted requests with host credentials
+type AWSSigningConfig struct {
+	Services        []string `yaml:"services"`          // Signing names to allow (e.g., s3, sts, dynamodb)
+	Regions         []string `yaml:"regions"`           // Regions to allow (empty allows all)
+	Actions         []string `yaml:"actions"`           // service:Action globs (e.g., s3:GetObject, dynamodb:Get*); empty allows all
+	AccessKeyEnv    string   `yaml:"access_key_env"`    // Host env var with the access key ID
+	SecretKeyEnv    string   `yaml:"secret_key_env"`    // Host env var with the secret access key
+	SessionTokenEnv string   `yaml:"session_token_env"` // Host env var with the session token (optional)
 }
 
 // SecretsConfig defines secret handling settings
 type SecretsConfig struct {
-	RedactPatterns []string `yaml:"redact_patterns"`
-	AllowedEnvVars []string `yaml:"allowed_env_vars"` // Env vars to pass to VM (e.g., ANTHROPIC_API_KEY)
+	RedactPatterns []string            `yaml:"redact_patterns"`
+	AllowedEnvVars []string            `yaml:"allowed_env_vars"` // Env vars to pass to VM (VAR, secret://name, or VAR=secret://name)
+	Surrogates     map[string][]string `yaml:"surrogates"`       // Allowed env var -> hosts where its placeholder is swapped for the real value
+	Detectors      map[string]bool     `yaml:"detectors"`        // Built-in detector toggles (e.g., jwt: false); all enabled by default
+	Preflight      string              `yaml:"preflight"`        // Workspace scan before enter: off, warn, or refuse
+	Env            EnvFilterConfig     `yaml:"env,omitempty"`    // Host env vars propagated to limactl
+}
+
+// EnvFilterConfig controls which host env vars reach Lima (and so the VM)
+// Patterns are globs (DATABASE_*, *_URL) or regexps prefixed with "re:"
+type EnvFilterConfig struct {
+	Mode  string   `yaml:"mode,omitempty"`  // denylist (default) or allowlist
+	Allow []string `yaml:"allow,omitempty"` // Always pass; in allowlist mode, the vars that pass
+	Block []string `yaml:"block,omitempty"` // Always block, on top of the built-in patterns
+}
+
+// LimaConfig controls how the lima runtime drives limactl
+type LimaConfig struct {
+	Limactl  string             `yaml:"limactl,omitempty"`  // limactl binary (default: limactl on PATH); AGENTBOX_LIMACTL overrides it
+	Timeouts LimaTimeoutsConfig `yaml:"timeouts,omitempty"` // Go durations (e.g., 20m); "0" waits forever
+}
+
+// LimaTimeoutsConfig limits how long each kind of limactl call may run
+// Interactive shells and exec'd commands aren't limited
+type LimaTimeoutsConfig struct {
+	Create    string `yaml:"create,omitempty"`    // limactl create (default: 10m)
+	Start     string `yaml:"start,omitempty"`     // limactl start, including the first boot's provisioning (default: 30m)
+	Stop      string `yaml:"stop,omitempty"`      // limactl stop (default: 3m)
+	Delete    string `yaml:"delete,omitempty"`    // limactl delete (default: 3m)
+	Provision string `yaml:"provision,omitempty"` // agentbox provision (default: 30m)
+	Command   string `yaml:"command,omitempty"`   // list, copy, edit and secret delivery (default: 2m)
+}
+
+// ToolchainsConfig pins the language toolchains installed in the VM
+// An empty version leaves that language as the image provides it
+type ToolchainsConfig struct {
+	Go     string `yaml:"go,omitempty"`     // Exact release (e.g., 1.23.4), installed to /usr/local/go
+	Node   string `yaml:"node,omitempty"`   // Major (20) or exact (20.11.1) release, installed to /usr/local
+	Python string `yaml:"python,omitempty"` // e.g., 3.12, installed with mise for the agent user
+	Rust   string `yaml:"rust,omitempty"`   // stable, beta, nightly or 1.x.y, installed with mise for the agent user
+}
+
+// AgentsConfig pins the agent CLI versions installed in the VM
+// An empty version skips that CLI
+type AgentsConfig struct {
+	ClaudeCode string `yaml:"claude_code,omitempty"` // npm version of @anthropic-ai/claude-code
+	Opencode   string `yaml:"opencode,omitempty"`    // Module version of github.com/opencode-ai/opencode
+	Gastown    string `yaml:"gastown,omitempty"`     // Module version of github.com/steveyegge/gastown (gt)
+	Beads      string `yaml:"beads,omitempty"`       // Module version of github.com/steveyegge/beads (bd)
+}
+
+// ProvisionConfig is extra software installed in the VM on every provision
+// Each entry is installed only if missing, so re-running is safe
+type ProvisionConfig struct {
+	Apt   []string        `yaml:"apt,omitempty"`   // apt packages
+	Npm   []string        `yaml:"npm,omitempty"`   // Global npm packages (name or name@version)
+	Pip   []string        `yaml:"pip,omitempty"`   // pip packages for the agent user (name or name==version)
+	Go    []string        `yaml:"go,omitempty"`    // go install targets (module/path@version)
+	Steps []ProvisionStep `yaml:"steps,omitempty"` // Shell steps, run in order after the packages
+}
+
+// ProvisionStep is a shell snippet run during provisioning
+// A step runs once per distinct script; edit it to make it run again
+type ProvisionStep struct {
+	Name string `yaml:"name"`
+	User string `yaml:"user,omitempty"` // system (root, default) or agent
+	Run  string `yaml:"run"`
 }
 
 // MountConfig defines a host-to-guest mount
+// Relative host paths are resolved against the project directory
 type MountConfig struct {
-	Host     string `yaml:"host"`
-	Guest    string `yaml:"guest"`
-	Writable bool   `yaml:"writable"`
+	Host           string `yaml:"host"`
+	Guest          string `yaml:"guest"`
+	Writable       *bool  `yaml:"writable,omitempty"`        // Read-only when omitted
+	AllowSensitive bool   `yaml:"allow_sensitive,omitempty"` // Permit mounting ~/.ssh, ~/.aws, $HOME, / and the like
+}
+
+// IsWritable reports whether the guest may write to the mount
+func (m MountConfig) IsWritable() bool {
+	return m.Writable != nil && *m.Writable
 }
 
 // DefaultConfig returns the default configuration
@@ -70,10 +172,25 @@ func DefaultConfig() *Config {
 			AllowedEnvVars: []string{
 				"ANTHROPIC_API_KEY", // Required for claude-code
 			},
+			Preflight: "warn",
 		},
 		Mounts: []MountConfig{
-			{Host: "./workspace", Guest: "/workspace", Writable: true},
-			{Host: "./artifacts", Guest: "/artifacts", Writable: true},
+			{Host: "./workspace", Guest: "/workspace", Writable: boolPtr(true)},
+			{Host: "./artifacts", Guest: "/artifacts", Writable: boolPtr(true)},
+		},
+		Toolchains: ToolchainsConfig{
+			Go:   "1.23.4",
+			Node: "22",
+		},
+		Agents: AgentsConfig{
+			ClaudeCode: "2.0.14",
+			Opencode:   "v0.0.55",
+			Gastown:    "v0.2.0",
+			Beads:      "v0.20.1",
 		},
 	}
 }
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,163 @@
+package lima
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// Image policies for vm.image_policy
+const (
+	// ImagePolicyFallback tries the configured images first, then the
+	// built-in images, which are not pinned to a digest. It's the default
+	// only without vm.images
+	ImagePolicyFallback = "fallback"
+	// ImagePolicyVerified only boots images with a digest. It's the default
+	// once vm.images is set
+	ImagePolicyVerified = "verified"
+)
+
+// Image is a boot image entry in the Lima template
+type Image struct {
+	Location string
+	Arch     string
+	Digest   string // Empty for unverified images
+}
+
+// defaultImages are used when vm.images is empty, and after the configured
+// images under the fallback policy. Release URLs change over time, so they
+// carry no digest.
+var defaultImages = []Image{
+	// Pre-built AgentBox images with all tools installed
+	{Location: "https://github.com/davidsenack/agentbox/releases/download/image-v1.0.0/agentbox-ubuntu-24.04-arm64.qcow2", Arch: ArchAArch64},
+	{Location: "https://github.com/davidsenack/agentbox/releases/download/image-v1.0.0/agentbox-ubuntu-24.04-amd64.qcow2", Arch: ArchX8664},
+	// Fallback to stock Ubuntu if pre-built image unavailable
+	{Location: "https://cloud-images.ubuntu.com/releases/24.04/release/ubuntu-24.04-server-cloudimg-arm64.img", Arch: ArchAArch64},
+	{Location: "https://cloud-images.ubuntu.com/releases/24.04/release/ubuntu-24.04-server-cloudimg-amd64.img", Arch: ArchX8664},
+}
+
+// digestPattern matches the digests Lima can verify
+var digestPattern = regexp.MustCompile(`^(sha256:[0-9a-f]{64}|sha512:[0-9a-f]{128})$`)
+
+// ImagePolicy returns the effective vm.image_policy
+// Pinned images are only worth something if they're required, so fallback
+// must be asked for once vm.images is set
+func ImagePolicy(vm config.VMConfig) (string, error) {
+	switch vm.ImagePolicy {
+	case "":
+		if len(vm.Images) > 0 {
+			return ImagePolicyVerified, nil
+		}
+		return ImagePolicyFallback, nil
+	case ImagePolicyFallback, ImagePolicyVerified:
+		return vm.ImagePolicy, nil
+	}
+	return "", fmt.Errorf("invalid vm.image_policy %q (use fallback or verified)", vm.ImagePolicy)
+}
+
+// UsesFallback reports whether an unpinned built-in image can boot in place
+// of the configured ones
+func UsesFallback(vm config.VMConfig) bool {
+	policy, err := ImagePolicy(vm)
+	return err == nil && policy == ImagePolicyFallback && len(vm.Images) > 0
+}
+
+// ResolveImages returns the images to list in the template for a guest arch
+// Configured images must carry a digest; local paths are resolved against
+// the project. Under the verified policy every image must be pinned, so the
+// built-in images are dropped and an empty vm.images is an error.
+func ResolveImages(vm config.VMConfig, projectDir, arch string) ([]Image, error) {
+	policy, err := ImagePolicy(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]Image, 0, len(vm.Images)+len(defaultImages))
+	for _, img := range vm.Images {
+		if img.Location == "" {
+			return nil, fmt.Errorf("vm.images entry needs a location")
+		}
+		imgArch := normalizeArch(img.Arch)
+		if imgArch != ArchAArch64 && imgArch != ArchX8664 {
+			return nil, fmt.Errorf("vm.images %s: invalid arch %q (use aarch64 or x86_64)", img.Location, img.Arch)
+		}
+		digest := strings.ToLower(img.Digest)
+		if digest == "" {
+			return nil, fmt.Errorf("vm.images %s: digest is required (e.g., sha256:<hex>)", img.Location)
+		}
+		if !digestPattern.MatchString(digest) {
+			return nil, fmt.Errorf("vm.images %s: invalid digest %q (use sha256:<hex> or sha512:<hex>)", img.Location, img.Digest)
+		}
+
+		location := img.Location
+		if !strings.Contains(location, "://") && !filepath.IsAbs(location) {
+			location = filepath.Join(projectDir, location)
+		}
+		images = append(images, Image{Location: location, Arch: imgArch, Digest: digest})
+	}
+
+	if policy == ImagePolicyFallback {
+		images = append(images, defaultImages...)
+	}
+
+	for _, img := range images {
+		if img.Arch == arch {
+			return images, nil
+		}
+	}
+	if policy == ImagePolicyVerified {
+		return nil, fmt.Errorf("vm.image_policy is verified but vm.images has no %s image with a digest", arch)
+	}
+	return nil, fmt.Errorf("vm.images has no %s image", arch)
+}
+
+// VerifyImages checks local image files against their digests
+// Remote images are verified by Lima as they download
+func VerifyImages(images []Image) error {
+	for _, img := range images {
+		if img.Digest == "" || strings.Contains(img.Location, "://") {
+			continue
+		}
+		if err := verifyFile(img.Location, img.Digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyFile hashes path and compares it with a "sha256:..." digest
+func verifyFile(path, digest string) error {
+	algo, want, _ := strings.Cut(digest, ":")
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported digest algorithm %q for %s", algo, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to hash image %s: %w", path, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("image %s does not match its digest (expected %s:%s, got %s:%s)", path, algo, want, algo, got)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package lima
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestResolveImages(t *testing.T) {
+	hardened := config.ImageConfig{Location: "https://example.com/hardened-arm64.qcow2", Arch: "arm64", Digest: testDigest}
+
+	tests := []struct {
+		name      string
+		vm        config.VMConfig
+		arch      string
+		wantFirst Image
+		wantCount int
+		wantErr   string
+	}{
+		{
+			name:      "built-in images by default",
+			arch:      ArchAArch64,
+			wantFirst: defaultImages[0],
+			wantCount: len(defaultImages),
+		},
+		{
+			name:      "configured images come before the fallbacks",
+			vm:        config.VMConfig{Images: []config.ImageConfig{hardened}, ImagePolicy: "fallback"},
+			arch:      ArchAArch64,
+			wantFirst: Image{Location: hardened.Location, Arch: ArchAArch64, Digest: testDigest},
+			wantCount: 1 + len(defaultImages),
+		},
+		{
+			name:      "configured images are verified by default",
+			vm:        config.VMConfig{Images: []config.ImageConfig{hardened}},
+			arch:      ArchAArch64,
+			wantFirst: Image{Location: hardened.Location, Arch: ArchAArch64, Digest: testDigest},
+			wantCount: 1,
+		},
+		{
+			name:      "verified drops the unpinned built-ins",
+			vm:        config.VMConfig{Images: []config.ImageConfig{hardened}, ImagePolicy: "verified"},
+			arch:      ArchAArch64,
+			wantFirst: Image{Location: hardened.Location, Arch: ArchAArch64, Digest: testDigest},
+			wantCount: 1,
+		},
+		{
+			name:    "digest needs an algorithm",
+			vm:      config.VMConfig{Images: []config.ImageConfig{{Location: "images/base.qcow2", Arch: "x86_64", Digest: strings.ToUpper(testDigest[7:])}}},
+			arch:    ArchX8664,
+			wantErr: "invalid digest",
+		},
+		{
+			name:    "verified with no images",
+			vm:      config.VMConfig{ImagePolicy: "verified"},
+			arch:    ArchAArch64,
+			wantErr: "no aarch64 image",
+		},
+		{
+			name:    "verified with no image for the guest arch",
+			vm:      config.VMConfig{Images: []config.ImageConfig{hardened}, ImagePolicy: "verified"},
+			arch:    ArchX8664,
+			wantErr: "no x86_64 image",
+		},
+		{
+			name:    "digest is required",
+			vm:      config.VMConfig{Images: []config.ImageConfig{{Location: "https://example.com/a.img", Arch: "aarch64"}}},
+			arch:    ArchAArch64,
+			wantErr: "digest is required",
+		},
+		{
+			name:    "bad arch",
+			vm:      config.VMConfig{Images: []config.ImageConfig{{Location: "https://example.com/a.img", Arch: "riscv64", Digest: testDigest}}},
+			arch:    ArchAArch64,
+			wantErr: "invalid arch",
+		},
+		{
+			name:    "bad policy",
+			vm:      config.VMConfig{ImagePolicy: "yolo"},
+			arch:    ArchAArch64,
+			wantErr: "invalid vm.image_policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := ResolveImages(tt.vm, "/tmp/testproject", tt.arch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(images) != tt.wantCount || images[0] != tt.wantFirst {
+				t.Errorf("got %d images starting with %+v, want %d starting with %+v", len(images), images[0], tt.wantCount, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestResolveImagesLocalPath(t *testing.T) {
+	vm := config.VMConfig{Images: []config.ImageConfig{{Location: "images/base.qcow2", Arch: "x86_64", Digest: strings.ToUpper(testDigest)}}}
+
+	images, err := ResolveImages(vm, "/tmp/testproject", ArchX8664)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Image{Location: "/tmp/testproject/images/base.qcow2", Arch: ArchX8664, Digest: testDigest}
+	if images[0] != want {
+		t.Errorf("got %+v, want %+v", images[0], want)
+	}
+}
+
+func TestVerifyImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "base.qcow2")
+	if err := os.WriteFile(path, []byte("disk image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("disk image"))
+	good := "sha256:" + hex.EncodeToString(sum[:])
+
+	if err := VerifyImages([]Image{{Location: path, Arch: ArchX8664, Digest: good}}); err != nil {
+		t.Errorf("matching digest rejected: %v", err)
+	}
+
+	err := VerifyImages([]Image{{Location: path, Arch: ArchX8664, Digest: testDigest}})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected digest mismatch, got %v", err)
+	}
+
+	// Remote and unpinned images are left to Lima
+	remote := []Image{{Location: "https://example.com/a.img", Digest: testDigest}, defaultImages[0]}
+	if err := VerifyImages(remote); err != nil {
+		t.Errorf("remote images should not be hashed: %v", err)
+	}
+}
+
+func TestGenerateTemplateImages(t *testing.T) {
+	setHost(t, "darwin", "arm64")
+
+	cfg := config.DefaultConfig()
+	cfg.VM.Images = []config.ImageConfig{{Location: "https://example.com/hardened-arm64.qcow2", Arch: "aarch64", Digest: testDigest}}
+	cfg.VM.ImagePolicy = "verified"
+
+	tmpl, err := GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+	want := "  - location: \"https://example.com/hardened-arm64.qcow2\"\n    arch: \"aarch64\"\n    digest: \"" + testDigest + "\""
+	if !strings.Contains(tmpl, want) {
+		t.Errorf("template missing pinned image:\n%s", tmpl)
+	}
+	if strings.Contains(tmpl, "cloud-images.ubuntu.com") {
+		t.Error("verified policy must not fall back to unpinned images")
+	}
+}
+
+func TestUsesFallback(t *testing.T) {
+	pinned := []config.ImageConfig{{Location: "https://example.com/a.img", Arch: "aarch64", Digest: testDigest}}
+	tests := []struct {
+		vm   config.VMConfig
+		want bool
+	}{
+		{config.VMConfig{}, false},
+		{config.VMConfig{Images: pinned}, false},
+		{config.VMConfig{Images: pinned, ImagePolicy: "verified"}, false},
+		{config.VMConfig{Images: pinned, ImagePolicy: "fallback"}, true},
+	}
+	for _, tt := range tests {
+		if got := UsesFallback(tt.vm); got != tt.want {
+			t.Errorf("UsesFallback(%+v) = %v, want %v", tt.vm, got, tt.want)
+		}
+	}
+}
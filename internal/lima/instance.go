@@ -0,0 +1,79 @@
+package lima
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Instance statuses reported by limactl
+const (
+	InstanceRunning = "Running"
+	InstanceStopped = "Stopped"
+	InstanceBroken  = "Broken"
+)
+
+// Instance is a Lima VM as reported by `limactl list --json`
+type Instance struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Dir     string `json:"dir"` // Instance directory (disks, lima.yaml, logs)
+	VMType  string `json:"vmType"`
+	Arch    string `json:"arch"`
+	CPUs    int    `json:"cpus"`
+	Memory  int64  `json:"memory"` // Bytes
+	Disk    int64  `json:"disk"`   // Bytes
+	SSHPort int    `json:"sshLocalPort"`
+}
+
+// Running reports whether the instance is running
+func (i *Instance) Running() bool {
+	return i.Status == InstanceRunning
+}
+
+// List returns every Lima instance from a single `limactl list --json`
+func (m *Manager) List(ctx context.Context) ([]Instance, error) {
+	output, err := m.output(ctx, call{args: []string{"list", "--json"}, kind: "command"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Lima instances: %w", err)
+	}
+	return ParseInstances(output), nil
+}
+
+// Inspect returns the named instance, or nil if it doesn't exist
+func (m *Manager) Inspect(ctx context.Context, name string) (*Instance, error) {
+	instances, err := m.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return FindInstance(instances, name), nil
+}
+
+// FindInstance returns the named instance in a listing, or nil
+func FindInstance(instances []Instance, name string) *Instance {
+	for i := range instances {
+		if instances[i].Name == name {
+			return &instances[i]
+		}
+	}
+	return nil
+}
+
+// ParseInstances parses `limactl list --json` output (one object per line)
+// Lines that aren't instances are skipped
+func ParseInstances(data []byte) []Instance {
+	var instances []Instance
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var inst Instance
+		if err := json.Unmarshal(line, &inst); err != nil || inst.Name == "" {
+			continue
+		}
+		instances = append(instances, inst)
+	}
+	return instances
+}
@@ -0,0 +1,46 @@
+package lima
+
+import "testing"
+
+func TestParseInstances(t *testing.T) {
+	data := []byte(`{"name":"agentbox-demo","status":"Running","dir":"/Users/me/.lima/agentbox-demo","vmType":"vz","arch":"aarch64","cpus":4,"memory":4294967296,"disk":53687091200,"sshLocalPort":60022,"config":{"cpus":4}}
+time="2025-01-01T00:00:00Z" level=warning msg="unrelated"
+
+{"name":"agentbox-other","status":"Stopped","dir":"/Users/me/.lima/agentbox-other","vmType":"qemu","arch":"x86_64","cpus":2,"memory":2147483648,"disk":10737418240,"sshLocalPort":0}
+`)
+
+	instances := ParseInstances(data)
+	if len(instances) != 2 {
+		t.Fatalf("got %d instances, want 2: %+v", len(instances), instances)
+	}
+
+	demo := FindInstance(instances, "agentbox-demo")
+	if demo == nil {
+		t.Fatal("agentbox-demo not found")
+	}
+	want := Instance{
+		Name:    "agentbox-demo",
+		Status:  InstanceRunning,
+		Dir:     "/Users/me/.lima/agentbox-demo",
+		VMType:  "vz",
+		Arch:    "aarch64",
+		CPUs:    4,
+		Memory:  4 << 30,
+		Disk:    50 << 30,
+		SSHPort: 60022,
+	}
+	if *demo != want {
+		t.Errorf("agentbox-demo = %+v, want %+v", *demo, want)
+	}
+	if !demo.Running() {
+		t.Error("agentbox-demo should be running")
+	}
+
+	other := FindInstance(instances, "agentbox-other")
+	if other == nil || other.Running() {
+		t.Errorf("agentbox-other = %+v, want a stopped instance", other)
+	}
+	if FindInstance(instances, "agentbox-missing") != nil {
+		t.Error("a missing instance should not be found")
+	}
+}
@@ -1,27 +1,58 @@
 package lima
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
+
+	"github.com/davidsenack/agentbox/internal/secrets"
 )
 
 // Manager handles Lima VM lifecycle operations
 type Manager struct {
-	limaHome string
+	limactl   string
+	limaHome  string
+	timeouts  Timeouts
+	envFilter *secrets.EnvFilter
 }
 
-// NewManager creates a new Lima manager
+// NewManager creates a new Lima manager for the Lima home in LIMA_HOME
+// (default ~/.lima), running limactl from PATH with the default timeouts
+// Host env vars reach limactl through the built-in denylist until
+// SetEnvFilter is called
 func NewManager() *Manager {
-	home, _ := os.UserHomeDir()
 	return &Manager{
-		limaHome: filepath.Join(home, ".lima"),
+		limactl:   "limactl",
+		limaHome:  LimaHome(),
+		timeouts:  DefaultTimeouts(),
+		envFilter: secrets.DefaultEnvFilter(),
 	}
 }
 
+// SetEnvFilter sets the filter applied to the environment of every limactl call
+func (m *Manager) SetEnvFilter(f *secrets.EnvFilter) {
+	m.envFilter = f
+}
+
+// SetLimactl sets the limactl binary ("" keeps limactl from PATH)
+func (m *Manager) SetLimactl(path string) {
+	if path != "" {
+		m.limactl = path
+	}
+}
+
+// SetTimeouts sets how long each kind of limactl call may run
+func (m *Manager) SetTimeouts(t Timeouts) {
+	m.timeouts = t
+}
+
+// env returns the host environment limactl (and Lima's propagation) may see
+// LIMA_HOME is always passed, so limactl uses the same home as agentbox
+// even if the filter drops it
+func (m *Manager) env() []string {
+	return append(m.envFilter.Filter(os.Environ()), "LIMA_HOME="+m.limaHome)
+}
+
 // VMName returns the Lima VM name for a project
 func VMName(projectName string) string {
 	// Sanitize: replace spaces and special chars
@@ -31,72 +62,60 @@ func VMName(projectName string) string {
 }
 
 // Create creates a new Lima VM from the given template
-func (m *Manager) Create(name string, templatePath string) error {
-	cmd := exec.Command("limactl", "create", "--name", name, templatePath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (m *Manager) Create(ctx context.Context, name string, templatePath string) error {
+	return m.run(ctx, call{
+		args:   []string{"create", "--name", name, templatePath},
+		kind:   "create",
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	})
 }
 
 // Start starts a Lima VM
-func (m *Manager) Start(name string) error {
-	cmd := exec.Command("limactl", "start", name)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (m *Manager) Start(ctx context.Context, name string) error {
+	return m.run(ctx, call{
+		args:   []string{"start", name},
+		kind:   "start",
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	})
 }
 
 // Stop stops a Lima VM
-func (m *Manager) Stop(name string) error {
-	cmd := exec.Command("limactl", "stop", name)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (m *Manager) Stop(ctx context.Context, name string) error {
+	return m.run(ctx, call{
+		args:   []string{"stop", name},
+		kind:   "stop",
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	})
 }
 
 // Delete deletes a Lima VM
-func (m *Manager) Delete(name string) error {
-	cmd := exec.Command("limactl", "delete", "--force", name)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+func (m *Manager) Delete(ctx context.Context, name string) error {
+	return m.run(ctx, call{
+		args:   []string{"delete", "--force", name},
+		kind:   "delete",
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	})
 }
 
 // Exists checks if a Lima VM exists
-func (m *Manager) Exists(name string) bool {
-	instanceDir := filepath.Join(m.limaHome, name)
-	_, err := os.Stat(instanceDir)
-	return err == nil
-}
-
-// limaInstance represents a Lima instance in JSON output
-type limaInstance struct {
-	Name   string `json:"name"`
-	Status string `json:"status"`
+// An error means limactl couldn't tell, not that the VM is missing
+func (m *Manager) Exists(ctx context.Context, name string) (bool, error) {
+	inst, err := m.Inspect(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return inst != nil, nil
 }
 
 // IsRunning checks if a Lima VM is running
-func (m *Manager) IsRunning(name string) (bool, error) {
-	cmd := exec.Command("limactl", "list", "--json")
-	output, err := cmd.Output()
+func (m *Manager) IsRunning(ctx context.Context, name string) (bool, error) {
+	inst, err := m.Inspect(ctx, name)
 	if err != nil {
-		return false, fmt.Errorf("failed to list Lima instances: %w", err)
-	}
-
-	// Parse JSON lines (one per instance)
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		var instance limaInstance
-		if err := json.Unmarshal([]byte(line), &instance); err != nil {
-			continue
-		}
-		if instance.Name == name {
-			return instance.Status == "Running", nil
-		}
+		return false, err
 	}
-
-	return false, nil
+	return inst != nil && inst.Running(), nil
 }
@@ -0,0 +1,198 @@
+package lima
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// Timeouts limits how long each kind of limactl call may run (0 waits forever)
+type Timeouts struct {
+	Create    time.Duration
+	Start     time.Duration
+	Stop      time.Duration
+	Delete    time.Duration
+	Provision time.Duration
+	Command   time.Duration // list, copy, edit and secret delivery
+}
+
+// DefaultTimeouts are generous enough for an image download and a first
+// boot that provisions everything
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Create:    10 * time.Minute,
+		Start:     30 * time.Minute,
+		Stop:      3 * time.Minute,
+		Delete:    3 * time.Minute,
+		Provision: 30 * time.Minute,
+		Command:   2 * time.Minute,
+	}
+}
+
+// ParseTimeouts applies lima.timeouts over the defaults
+func ParseTimeouts(cfg config.LimaTimeoutsConfig) (Timeouts, error) {
+	t := DefaultTimeouts()
+	for _, f := range []struct {
+		key   string
+		value string
+		dst   *time.Duration
+	}{
+		{"create", cfg.Create, &t.Create},
+		{"start", cfg.Start, &t.Start},
+		{"stop", cfg.Stop, &t.Stop},
+		{"delete", cfg.Delete, &t.Delete},
+		{"provision", cfg.Provision, &t.Provision},
+		{"command", cfg.Command, &t.Command},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil || d < 0 {
+			return Timeouts{}, fmt.Errorf("invalid lima.timeouts.%s %q (use a duration like 20m, or 0 for none)", f.key, f.value)
+		}
+		*f.dst = d
+	}
+	return t, nil
+}
+
+// get returns the timeout for a kind of call ("" has none)
+func (t Timeouts) get(kind string) time.Duration {
+	switch kind {
+	case "create":
+		return t.Create
+	case "start":
+		return t.Start
+	case "stop":
+		return t.Stop
+	case "delete":
+		return t.Delete
+	case "provision":
+		return t.Provision
+	case "command":
+		return t.Command
+	}
+	return 0
+}
+
+// LimaHome returns where Lima keeps its instances: $LIMA_HOME or ~/.lima
+func LimaHome() string {
+	if dir := os.Getenv("LIMA_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".lima"
+	}
+	return filepath.Join(home, ".lima")
+}
+
+// call is one limactl invocation
+type call struct {
+	args   []string
+	kind   string // Timeouts key; "" runs until ctx is done
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer // Also captured for the error, unless passthrough
+	// passthrough marks a user's shell or command, whose stderr they have
+	// already seen; its errors don't repeat it
+	passthrough bool
+}
+
+// cancelGrace is how long limactl gets to clean up after an interrupt
+const cancelGrace = 10 * time.Second
+
+// stderrTailLines is how much of limactl's stderr errors include
+const stderrTailLines = 10
+
+// run runs limactl, interrupting it when ctx is done or the call's timeout
+// passes. A non-zero exit wraps the *exec.ExitError.
+func (m *Manager) run(ctx context.Context, c call) error {
+	timeout := m.timeouts.get(c.kind)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, m.limactl, c.args...)
+	cmd.Env = m.env()
+	cmd.Stdin = c.stdin
+	cmd.Stdout = c.stdout
+
+	var tail bytes.Buffer
+	switch {
+	case c.passthrough:
+		cmd.Stderr = c.stderr
+	case c.stderr != nil:
+		cmd.Stderr = io.MultiWriter(c.stderr, &tail)
+	default:
+		cmd.Stderr = &tail
+	}
+
+	// Let limactl stop its host agent and release the instance lock
+	// before it's killed
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = cancelGrace
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	name := c.subcommand()
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("limactl not found at %q; install Lima or set lima.limactl: %w", m.limactl, err)
+	case timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("limactl %s timed out after %s (set lima.timeouts.%s to change it): %w", name, timeout, c.kind, ctx.Err())
+	case ctx.Err() != nil:
+		err = fmt.Errorf("limactl %s: %w", name, ctx.Err())
+	}
+	if msg := lastLines(tail.String(), stderrTailLines); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// subcommand returns the limactl command being run (e.g., start)
+func (c call) subcommand() string {
+	for _, arg := range c.args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+// output runs limactl and returns its stdout
+func (m *Manager) output(ctx context.Context, c call) ([]byte, error) {
+	var out bytes.Buffer
+	c.stdout = &out
+	err := m.run(ctx, c)
+	return out.Bytes(), err
+}
+
+// lastLines returns the last n non-empty lines of s
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
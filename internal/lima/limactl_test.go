@@ -0,0 +1,126 @@
+package lima
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// fakeLimactl returns a manager running script as limactl
+func fakeLimactl(t *testing.T, script string) *Manager {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "limactl")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager()
+	m.SetLimactl(path)
+	return m
+}
+
+func TestManagerLimaHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("LIMA_HOME", home)
+
+	m := fakeLimactl(t, `printf '{"name":"agentbox-demo","status":"Stopped","dir":"%s/agentbox-demo"}\n' "$LIMA_HOME"`)
+	inst, err := m.Inspect(t.Context(), "agentbox-demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst == nil || inst.Dir != filepath.Join(home, "agentbox-demo") {
+		t.Errorf("limactl did not see LIMA_HOME=%s: %+v", home, inst)
+	}
+	demo, err := m.Exists(t.Context(), "agentbox-demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.Exists(t.Context(), "agentbox-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !demo || other {
+		t.Error("Exists should follow the listing")
+	}
+
+	broken := fakeLimactl(t, "echo 'level=fatal msg=\"lima broke\"' >&2; exit 1")
+	if exists, err := broken.Exists(t.Context(), "agentbox-demo"); err == nil || exists {
+		t.Errorf("a failing limactl should be an error, not a missing VM (exists=%v, err=%v)", exists, err)
+	}
+}
+
+func TestManagerErrorsIncludeStderr(t *testing.T) {
+	m := fakeLimactl(t, `echo 'level=info msg="starting"' >&2; echo 'level=fatal msg="disk locked"' >&2; exit 1`)
+
+	err := m.Start(t.Context(), "agentbox-demo")
+	var exitErr interface{ ExitCode() int }
+	if err == nil || !errors.As(err, &exitErr) {
+		t.Fatalf("expected an exit error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `msg="disk locked"`) {
+		t.Errorf("error should include limactl's stderr: %v", err)
+	}
+
+	if err := m.Copy(t.Context(), "a", "b"); err == nil || !strings.Contains(err.Error(), "disk locked") {
+		t.Errorf("copy error should include limactl's stderr: %v", err)
+	}
+}
+
+func TestManagerTimeout(t *testing.T) {
+	m := fakeLimactl(t, "exec sleep 10\n")
+	timeouts := DefaultTimeouts()
+	timeouts.Command = 100 * time.Millisecond
+	m.SetTimeouts(timeouts)
+
+	start := time.Now()
+	_, err := m.List(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("limactl was not interrupted (took %s)", elapsed)
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	m := fakeLimactl(t, "exec sleep 10\n")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := m.Stop(ctx, "agentbox-demo"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled stop, got %v", err)
+	}
+}
+
+func TestManagerMissingLimactl(t *testing.T) {
+	m := NewManager()
+	m.SetLimactl(filepath.Join(t.TempDir(), "missing"))
+	if _, err := m.List(t.Context()); err == nil || !strings.Contains(err.Error(), "set lima.limactl") {
+		t.Errorf("expected a missing limactl error, got %v", err)
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	got, err := ParseTimeouts(config.LimaTimeoutsConfig{Start: "45m", Stop: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultTimeouts()
+	want.Start = 45 * time.Minute
+	want.Stop = 0
+	if got != want {
+		t.Errorf("ParseTimeouts = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []config.LimaTimeoutsConfig{{Create: "soon"}, {Command: "-1m"}} {
+		if _, err := ParseTimeouts(bad); err == nil {
+			t.Errorf("ParseTimeouts(%+v) should fail", bad)
+		}
+	}
+}
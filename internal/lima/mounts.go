@@ -0,0 +1,147 @@
+package lima
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// Mount is a resolved host-to-guest mount
+type Mount struct {
+	Location   string // Absolute host path
+	MountPoint string // Absolute guest path
+	Writable   bool
+}
+
+// sensitivePath is a host location that must not be exposed to the guest
+type sensitivePath struct {
+	path        string
+	description string
+	// contents marks paths whose subdirectories are sensitive too
+	// (a project under $HOME is fine; a key under ~/.ssh is not)
+	contents bool
+}
+
+// ResolveMounts resolves configured mounts against the project directory
+// and refuses any that expose host credentials or VM state: ~/.ssh, ~/.aws,
+// ~/.config/gh, the Lima home, agentbox's secret store, the project's
+// agentbox.yaml and .agentbox dir, the home directory itself or / (including
+// their parents). A mount's allow_sensitive overrides the check.
+func ResolveMounts(mounts []config.MountConfig, projectDir string) ([]Mount, error) {
+	sensitive := sensitivePaths(projectDir)
+	seen := make(map[string]bool)
+	resolved := make([]Mount, 0, len(mounts))
+
+	for _, m := range mounts {
+		if m.Host == "" || m.Guest == "" {
+			return nil, fmt.Errorf("mount needs both host and guest paths (got host %q, guest %q)", m.Host, m.Guest)
+		}
+		if !path.IsAbs(m.Guest) {
+			return nil, fmt.Errorf("mount guest path %q must be absolute", m.Guest)
+		}
+		guest := path.Clean(m.Guest)
+		if seen[guest] {
+			return nil, fmt.Errorf("guest path %s is mounted more than once", guest)
+		}
+		seen[guest] = true
+
+		host, err := resolveHostPath(m.Host, projectDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.AllowSensitive {
+			// Check the symlink target too, so a link to ~/.ssh is caught
+			candidates := []string{host}
+			if real, err := filepath.EvalSymlinks(host); err == nil && real != host {
+				candidates = append(candidates, real)
+			}
+			for _, c := range candidates {
+				if s, ok := exposes(c, sensitive); ok {
+					return nil, fmt.Errorf("refusing to mount %s: it exposes %s (%s); set allow_sensitive: true on the mount to override", m.Host, s.path, s.description)
+				}
+			}
+		}
+
+		resolved = append(resolved, Mount{
+			Location:   host,
+			MountPoint: guest,
+			Writable:   m.IsWritable(),
+		})
+	}
+
+	return resolved, nil
+}
+
+// resolveHostPath expands ~ and makes relative paths absolute under projectDir
+func resolveHostPath(p, projectDir string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to expand %s: %w", p, err)
+		}
+		p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(projectDir, p)
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve mount path %s: %w", p, err)
+	}
+	return abs, nil
+}
+
+// sensitivePaths lists the locations ResolveMounts refuses for this host
+// and project
+func sensitivePaths(projectDir string) []sensitivePath {
+	paths := []sensitivePath{{path: "/", description: "the host root"}}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths,
+			sensitivePath{path: home, description: "your home directory"},
+			sensitivePath{path: filepath.Join(home, ".ssh"), description: "SSH keys", contents: true},
+			sensitivePath{path: filepath.Join(home, ".aws"), description: "AWS credentials", contents: true},
+			sensitivePath{path: filepath.Join(home, ".config", "gh"), description: "GitHub CLI tokens", contents: true},
+		)
+	}
+	paths = append(paths, sensitivePath{path: LimaHome(), description: "Lima VM disks and keys", contents: true})
+
+	if dir, err := config.UserDir(); err == nil {
+		if abs, err := filepath.Abs(dir); err == nil {
+			paths = append(paths, sensitivePath{path: abs, description: "the agentbox secret store", contents: true})
+		}
+	}
+	// The proxy CA key and logs, and the config the next boot is built from
+	if abs, err := filepath.Abs(projectDir); err == nil {
+		paths = append(paths,
+			sensitivePath{path: filepath.Join(abs, ".agentbox"), description: "the box's state (proxy CA key, logs)", contents: true},
+			sensitivePath{path: filepath.Join(abs, config.ConfigFileName), description: "the box's " + config.ConfigFileName, contents: true},
+		)
+	}
+	return paths
+}
+
+// exposes returns the sensitive path a host path would make visible: the
+// path itself, one of its parents, or (for contents paths) a child
+func exposes(host string, sensitive []sensitivePath) (sensitivePath, bool) {
+	for _, s := range sensitive {
+		if host == s.path || isWithin(s.path, host) {
+			return s, true
+		}
+		if s.contents && isWithin(host, s.path) {
+			return s, true
+		}
+	}
+	return sensitivePath{}, false
+}
+
+// isWithin reports whether p is strictly inside dir
+func isWithin(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,195 @@
+package lima
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestResolveMounts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("LIMA_HOME", "")
+	t.Setenv("AGENTBOX_CONFIG_DIR", filepath.Join(home, "agentbox-config"))
+	projectDir := filepath.Join(home, "projects", "demo")
+
+	tests := []struct {
+		name    string
+		mount   config.MountConfig
+		want    Mount
+		wantErr string
+	}{
+		{
+			name:  "relative path resolves against the project",
+			mount: config.MountConfig{Host: "./workspace", Guest: "/workspace", Writable: boolPtr(true)},
+			want:  Mount{Location: filepath.Join(projectDir, "workspace"), MountPoint: "/workspace", Writable: true},
+		},
+		{
+			name:  "read-only when writable is omitted",
+			mount: config.MountConfig{Host: "/srv/datasets", Guest: "/data"},
+			want:  Mount{Location: "/srv/datasets", MountPoint: "/data"},
+		},
+		{
+			name:  "tilde expands to home",
+			mount: config.MountConfig{Host: "~/datasets", Guest: "/data/"},
+			want:  Mount{Location: filepath.Join(home, "datasets"), MountPoint: "/data"},
+		},
+		{
+			name:    "ssh keys",
+			mount:   config.MountConfig{Host: "~/.ssh", Guest: "/ssh"},
+			wantErr: "SSH keys",
+		},
+		{
+			name:    "inside aws credentials",
+			mount:   config.MountConfig{Host: filepath.Join(home, ".aws", "sso"), Guest: "/aws"},
+			wantErr: "AWS credentials",
+		},
+		{
+			name:    "gh tokens via a parent",
+			mount:   config.MountConfig{Host: "~/.config", Guest: "/config"},
+			wantErr: "GitHub CLI tokens",
+		},
+		{
+			name:    "home root",
+			mount:   config.MountConfig{Host: "~", Guest: "/home-host"},
+			wantErr: "home directory",
+		},
+		{
+			name:    "relative escape to home",
+			mount:   config.MountConfig{Host: "../..", Guest: "/up"},
+			wantErr: "home directory",
+		},
+		{
+			name:    "host root",
+			mount:   config.MountConfig{Host: "/", Guest: "/host"},
+			wantErr: "exposes /",
+		},
+		{
+			name:    "lima home",
+			mount:   config.MountConfig{Host: "~/.lima/default", Guest: "/lima"},
+			wantErr: "Lima",
+		},
+		{
+			name:    "secret store",
+			mount:   config.MountConfig{Host: filepath.Join(home, "agentbox-config"), Guest: "/cfg"},
+			wantErr: "secret store",
+		},
+		{
+			name:    "project dir holds the proxy CA key",
+			mount:   config.MountConfig{Host: ".", Guest: "/project", Writable: boolPtr(true)},
+			wantErr: "proxy CA key",
+		},
+		{
+			name:    "box state",
+			mount:   config.MountConfig{Host: "./.agentbox/proxy-ca", Guest: "/ca"},
+			wantErr: "proxy CA key",
+		},
+		{
+			name:    "project config",
+			mount:   config.MountConfig{Host: "./agentbox.yaml", Guest: "/etc/agentbox.yaml"},
+			wantErr: "agentbox.yaml",
+		},
+		{
+			name:  "override",
+			mount: config.MountConfig{Host: "~/.aws", Guest: "/aws", AllowSensitive: true},
+			want:  Mount{Location: filepath.Join(home, ".aws"), MountPoint: "/aws"},
+		},
+		{
+			name:    "relative guest path",
+			mount:   config.MountConfig{Host: "./workspace", Guest: "workspace"},
+			wantErr: "must be absolute",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveMounts([]config.MountConfig{tt.mount}, projectDir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveMountsSymlink(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0700)
+
+	projectDir := t.TempDir()
+	os.Symlink(filepath.Join(home, ".ssh"), filepath.Join(projectDir, "keys"))
+
+	_, err := ResolveMounts([]config.MountConfig{{Host: "./keys", Guest: "/keys"}}, projectDir)
+	if err == nil || !strings.Contains(err.Error(), "SSH keys") {
+		t.Errorf("a symlink to ~/.ssh should be refused, got %v", err)
+	}
+}
+
+func TestResolveMountsDuplicateGuest(t *testing.T) {
+	_, err := ResolveMounts([]config.MountConfig{
+		{Host: "./a", Guest: "/data"},
+		{Host: "./b", Guest: "/data/"},
+	}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("expected duplicate guest error, got %v", err)
+	}
+}
+
+func TestGenerateTemplateMounts(t *testing.T) {
+	setHost(t, "darwin", "arm64")
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := config.DefaultConfig()
+	cfg.Mounts = append(cfg.Mounts, config.MountConfig{Host: "/srv/datasets", Guest: "/data"})
+
+	tmpl, err := GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+	for _, want := range []string{
+		"location: \"/tmp/testproject/workspace\"\n    mountPoint: \"/workspace\"\n    writable: true",
+		"location: \"/srv/datasets\"\n    mountPoint: \"/data\"\n    writable: false",
+	} {
+		if !strings.Contains(tmpl, want) {
+			t.Errorf("template missing %q", want)
+		}
+	}
+
+	cfg.Mounts = nil
+	tmpl, err = GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+	if !strings.Contains(tmpl, "mounts: []") {
+		t.Error("no configured mounts should render an empty list, not Lima's defaults")
+	}
+
+	cfg.Mounts = []config.MountConfig{{Host: "/srv/a\"b\nmountPoint: /etc", Guest: "/data"}}
+	tmpl, err = GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+	if !strings.Contains(tmpl, `location: "/srv/a\"b\nmountPoint: /etc"`) {
+		t.Error("mount paths should be quoted so they can't inject YAML")
+	}
+	if _, err := ReadTemplateVM([]byte(tmpl)); err != nil {
+		t.Errorf("quoted template should parse: %v", err)
+	}
+
+	cfg.Mounts = []config.MountConfig{{Host: "~/.ssh", Guest: "/ssh"}}
+	if _, err := GenerateTemplate(cfg, "/tmp/testproject"); err == nil {
+		t.Error("template generation should refuse sensitive mounts")
+	}
+}
@@ -0,0 +1,284 @@
+package lima
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Provision step users
+const (
+	ProvisionUserSystem = "system"
+	ProvisionUserAgent  = "agent"
+)
+
+// Provision script sections, in the order they run
+const (
+	SectionBase       = "base"       // Agent user, proxy config, shell and secure wrappers
+	SectionToolchains = "toolchains" // toolchains in agentbox.yaml
+	SectionAgents     = "agents"     // agents in agentbox.yaml
+	SectionPackages   = "packages"   // provision.apt, npm, pip and go
+	SectionSteps      = "steps"      // provision.steps
+)
+
+// ProvisionSections lists every section of the provision script
+var ProvisionSections = []string{SectionBase, SectionToolchains, SectionAgents, SectionPackages, SectionSteps}
+
+// ProvisionMarker prefixes the progress lines the provision script prints:
+// "section <name>", "changed <what>" and "failed <what>"
+const ProvisionMarker = "::agentbox:: "
+
+// ProvisionStateDir is where the guest records provisioning progress
+const ProvisionStateDir = "/var/lib/agentbox/provision"
+
+// ProvisionHashFile is the project file (under .agentbox) and guest file
+// (under /etc/agentbox) holding the hash of the provision section the VM
+// was built with
+const ProvisionHashFile = "provision.sha256"
+
+// packagePattern rejects package specs that could smuggle shell syntax;
+// entries are quoted anyway, but a bad spec is better caught on the host
+var packagePattern = regexp.MustCompile(`^[A-Za-z0-9@][A-Za-z0-9@._+:/=<>~^*-]*$`)
+
+// ValidateProvision checks a provision section before it's rendered
+func ValidateProvision(p config.ProvisionConfig) error {
+	lists := []struct {
+		key      string
+		packages []string
+	}{
+		{"apt", p.Apt},
+		{"npm", p.Npm},
+		{"pip", p.Pip},
+		{"go", p.Go},
+	}
+	for _, l := range lists {
+		for _, pkg := range l.packages {
+			if !packagePattern.MatchString(pkg) {
+				return fmt.Errorf("invalid provision.%s entry %q", l.key, pkg)
+			}
+		}
+	}
+
+	for i, step := range p.Steps {
+		if strings.TrimSpace(step.Run) == "" {
+			return fmt.Errorf("provision.steps[%d] (%s) has nothing to run", i, step.Name)
+		}
+		switch step.User {
+		case "", ProvisionUserSystem, "root", ProvisionUserAgent:
+		default:
+			return fmt.Errorf("provision.steps[%d] (%s): invalid user %q (use system or agent)", i, step.Name, step.User)
+		}
+	}
+	return nil
+}
+
+// ProvisionHash returns a hash of the provision section, or "" if it's empty
+// Comparing it with the hash stored at create tells whether the VM was
+// built from the current config
+func ProvisionHash(p config.ProvisionConfig) string {
+	if provisionEmpty(p) {
+		return ""
+	}
+	data, _ := yaml.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteProvisionHash records the provision section a VM was built from in
+// the project's .agentbox dir
+func WriteProvisionHash(stateDir string, p config.ProvisionConfig) error {
+	path := filepath.Join(stateDir, ProvisionHashFile)
+	if err := os.WriteFile(path, []byte(ProvisionHash(p)+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to record provision hash: %w", err)
+	}
+	return nil
+}
+
+// ProvisionChanged reports whether the provision section differs from the
+// one the VM was built from; it's false if no hash was recorded
+func ProvisionChanged(stateDir string, p config.ProvisionConfig) bool {
+	data, err := os.ReadFile(filepath.Join(stateDir, ProvisionHashFile))
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) != ProvisionHash(p)
+}
+
+func provisionEmpty(p config.ProvisionConfig) bool {
+	return len(p.Apt) == 0 && len(p.Npm) == 0 && len(p.Pip) == 0 && len(p.Go) == 0 && len(p.Steps) == 0
+}
+
+// ProvisionScript renders the provision script for a running guest, limited
+// to sections (all of them when empty); it's the script create runs, minus
+// the one-time Gas Town rig setup
+func ProvisionScript(cfg *config.Config, sections []string) (string, error) {
+	if err := ValidateSections(sections); err != nil {
+		return "", err
+	}
+	if err := ValidateToolchains(cfg); err != nil {
+		return "", err
+	}
+	if err := ValidateProvision(cfg.Provision); err != nil {
+		return "", err
+	}
+	if len(sections) == 0 {
+		sections = ProvisionSections
+	}
+	return provisionScript(cfg, sections), nil
+}
+
+// ValidateSections checks section names against ProvisionSections
+func ValidateSections(sections []string) error {
+	for _, section := range sections {
+		known := false
+		for _, s := range ProvisionSections {
+			if section == s {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown provision section %q (available: %s)", section, strings.Join(ProvisionSections, ", "))
+		}
+	}
+	return nil
+}
+
+// generatePackagesScript installs the provision section's packages
+// Packages are installed only when missing, so it can be re-run
+func generatePackagesScript(p config.ProvisionConfig) string {
+	if len(p.Apt) == 0 && len(p.Npm) == 0 && len(p.Pip) == 0 && len(p.Go) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n# --- User Packages (agentbox.yaml) ---\nagentbox_section packages\n")
+
+	if len(p.Apt) > 0 {
+		fmt.Fprintf(&b, `
+# apt packages
+agentbox_step "apt packages"
+APT_MISSING=""
+for pkg in %s; do
+    dpkg-query -W -f='${Status}' "$pkg" 2>/dev/null | grep -q "install ok installed" || APT_MISSING="$APT_MISSING $pkg"
+done
+if [ -n "$APT_MISSING" ]; then
+    echo "Installing apt packages:$APT_MISSING"
+    apt-get update -qq
+    apt-get install -y $APT_MISSING
+    agentbox_changed "apt:$APT_MISSING"
+fi
+`, shellQuoteAll(p.Apt))
+	}
+
+	if len(p.Npm) > 0 {
+		fmt.Fprintf(&b, `
+# npm global packages
+agentbox_step "npm packages"
+for pkg in %s; do
+    if ! npm ls -g --depth=0 "$pkg" >/dev/null 2>&1; then
+        echo "Installing npm package: $pkg"
+        npm install -g "$pkg"
+        agentbox_changed "npm: $pkg"
+    fi
+done
+`, shellQuoteAll(p.Npm))
+	}
+
+	if len(p.Pip) > 0 {
+		// pip skips requirements that are already satisfied; a mise-managed
+		// Python (toolchains.python) takes precedence over the system one
+		fmt.Fprintf(&b, `
+# pip packages (agent user)
+agentbox_step "pip packages"
+echo "Installing pip packages"
+sudo -u agent -H bash -c 'if command -v mise >/dev/null; then eval "$(mise env -s bash)"; fi; python3 -m pip install --user --break-system-packages --quiet "$@"' _ %s
+`, shellQuoteAll(p.Pip))
+	}
+
+	if len(p.Go) > 0 {
+		// go install reuses its build cache, so unchanged tools are quick
+		fmt.Fprintf(&b, `
+# go tools (agent user)
+agentbox_step "go tools"
+for pkg in %s; do
+    echo "Installing go tool: $pkg"
+    sudo -u agent -H bash -c 'export PATH="/usr/local/go/bin:$PATH" GOPATH="$HOME/go"; go install "$1"' _ "$pkg"
+done
+`, shellQuoteAll(p.Go))
+	}
+
+	return b.String()
+}
+
+// generateStepsScript runs the provision section's shell steps
+// Each step runs once per distinct script (tracked in ProvisionStateDir),
+// so the section can be re-run on an existing VM. A failing step is
+// recorded in the step log and stops provisioning.
+func generateStepsScript(p config.ProvisionConfig) string {
+	if len(p.Steps) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n# --- User Steps (agentbox.yaml) ---\nagentbox_section steps\n")
+	fmt.Fprintf(&b, "mkdir -p %s/steps\n", ProvisionStateDir)
+
+	for i, step := range p.Steps {
+		sum := sha256.Sum256([]byte(step.User + "\x00" + step.Run))
+		id := hex.EncodeToString(sum[:8])
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		runner := "bash"
+		if step.User == ProvisionUserAgent {
+			runner = "sudo -u agent -H bash"
+		}
+
+		fmt.Fprintf(&b, `
+# step: %s
+agentbox_step "step: "%[4]s
+if [ ! -f %[2]s/steps/%[3]s.done ]; then
+    echo "Running provision step: "%[4]s
+    cat > %[2]s/steps/%[3]s.sh << 'AGENTBOX_STEP_%[3]s'
+set -euo pipefail
+%[5]s
+AGENTBOX_STEP_%[3]s
+    chmod 755 %[2]s/steps/%[3]s.sh
+    (cd /workspace 2>/dev/null || cd /; %[6]s %[2]s/steps/%[3]s.sh)
+    touch %[2]s/steps/%[3]s.done
+    agentbox_changed "step: "%[4]s
+fi
+`, oneLine(name), ProvisionStateDir, id, shellQuote(name), strings.TrimRight(step.Run, "\n"), runner)
+	}
+
+	return b.String()
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellQuoteAll quotes each word and joins them with spaces
+func shellQuoteAll(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// oneLine flattens s for use in a shell comment
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
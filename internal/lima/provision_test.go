@@ -0,0 +1,187 @@
+package lima
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestValidateProvision(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       config.ProvisionConfig
+		wantErr string
+	}{
+		{
+			name: "valid",
+			p: config.ProvisionConfig{
+				Apt:   []string{"postgresql-client", "libpq-dev"},
+				Npm:   []string{"@anthropic-ai/sdk@1.2.3", "typescript"},
+				Pip:   []string{"requests==2.32.3", "ruff>=0.5"},
+				Go:    []string{"golang.org/x/tools/gopls@latest"},
+				Steps: []config.ProvisionStep{{Name: "seed", User: "agent", Run: "make seed"}},
+			},
+		},
+		{
+			name:    "shell in a package",
+			p:       config.ProvisionConfig{Apt: []string{"curl; rm -rf /"}},
+			wantErr: "invalid provision.apt entry",
+		},
+		{
+			name:    "option as a package",
+			p:       config.ProvisionConfig{Pip: []string{"--index-url=https://evil.example"}},
+			wantErr: "invalid provision.pip entry",
+		},
+		{
+			name:    "empty step",
+			p:       config.ProvisionConfig{Steps: []config.ProvisionStep{{Name: "noop", Run: "  "}}},
+			wantErr: "has nothing to run",
+		},
+		{
+			name:    "unknown user",
+			p:       config.ProvisionConfig{Steps: []config.ProvisionStep{{Name: "x", User: "nobody", Run: "true"}}},
+			wantErr: "invalid user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProvision(tt.p)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGenerateTemplateProvision(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Provision = config.ProvisionConfig{
+		Apt: []string{"postgresql-client"},
+		Npm: []string{"typescript"},
+		Steps: []config.ProvisionStep{
+			{Name: "install deps", User: "agent", Run: "npm ci"},
+			{Name: "system tweak", Run: "echo hi > /etc/motd"},
+		},
+	}
+
+	tmpl, err := GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+
+	packages := strings.Index(tmpl, "# --- User Packages (agentbox.yaml) ---")
+	steps := strings.Index(tmpl, "# --- User Steps (agentbox.yaml) ---")
+	ready := strings.Index(tmpl, "# --- Mark Ready ---")
+	if packages < 0 || steps < packages || ready < steps {
+		t.Fatalf("user packages, then steps, must come before Mark Ready:\n%s", tmpl)
+	}
+
+	for _, want := range []string{
+		"for pkg in 'postgresql-client'; do",
+		"npm install -g \"$pkg\"",
+		"sudo -u agent -H bash " + ProvisionStateDir + "/steps/",
+		"npm ci",
+		"echo hi > /etc/motd",
+		"/etc/agentbox/" + ProvisionHashFile,
+	} {
+		if !strings.Contains(tmpl, want) {
+			t.Errorf("template missing %q", want)
+		}
+	}
+
+	// Each step is skipped once its marker exists, so re-runs are no-ops
+	if n := strings.Count(tmpl, ".done ]; then"); n != 2 {
+		t.Errorf("expected 2 guarded steps, got %d", n)
+	}
+}
+
+func TestGenerateTemplateNoProvision(t *testing.T) {
+	tmpl, err := GenerateTemplate(config.DefaultConfig(), "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+	if strings.Contains(tmpl, "User Packages") || strings.Contains(tmpl, "User Steps") {
+		t.Error("empty provision section should render nothing")
+	}
+}
+
+func TestGenerateTemplateInvalidProvision(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Provision.Npm = []string{"$(curl evil)"}
+
+	if _, err := GenerateTemplate(cfg, "/tmp/testproject"); err == nil {
+		t.Error("expected invalid provision to be rejected")
+	}
+}
+
+func TestProvisionHash(t *testing.T) {
+	if h := ProvisionHash(config.ProvisionConfig{}); h != "" {
+		t.Errorf("empty provision should hash to \"\", got %q", h)
+	}
+
+	a := config.ProvisionConfig{Apt: []string{"jq"}}
+	b := config.ProvisionConfig{Apt: []string{"jq", "ripgrep"}}
+	if ProvisionHash(a) != ProvisionHash(a) {
+		t.Error("hash should be stable")
+	}
+	if ProvisionHash(a) == ProvisionHash(b) {
+		t.Error("different sections should hash differently")
+	}
+}
+
+func TestProvisionChanged(t *testing.T) {
+	dir := t.TempDir()
+	a := config.ProvisionConfig{Apt: []string{"jq"}}
+	b := config.ProvisionConfig{Apt: []string{"jq", "ripgrep"}}
+
+	if ProvisionChanged(dir, b) {
+		t.Error("no recorded hash should not count as changed")
+	}
+	if err := WriteProvisionHash(dir, a); err != nil {
+		t.Fatal(err)
+	}
+	if ProvisionChanged(dir, a) {
+		t.Error("same section reported as changed")
+	}
+	if !ProvisionChanged(dir, b) {
+		t.Error("edited section not reported as changed")
+	}
+}
+
+func TestProvisionScriptSections(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Provision.Steps = []config.ProvisionStep{{Name: "seed", Run: "make seed"}}
+
+	script, err := ProvisionScript(cfg, []string{SectionToolchains})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(script, "agentbox_section toolchains") || !strings.Contains(script, "# --- Mark Ready ---") {
+		t.Errorf("script missing the toolchains section or footer:\n%s", script)
+	}
+	for _, unwanted := range []string{"agentbox_section base", "agentbox_section agents", "make seed", ProvisionHashFile} {
+		if strings.Contains(script, unwanted) {
+			t.Errorf("--only toolchains should not include %q", unwanted)
+		}
+	}
+
+	all, err := ProvisionScript(cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if all != generateProvisionScript(cfg) {
+		t.Error("no sections should render the full create-time script")
+	}
+
+	if _, err := ProvisionScript(cfg, []string{"kernel"}); err == nil {
+		t.Error("expected an unknown section to be rejected")
+	}
+}
@@ -0,0 +1,176 @@
+package lima
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateVM is the VM part of a generated Lima template
+type TemplateVM struct {
+	Type    string
+	Arch    string
+	Rosetta bool
+	CPUs    int
+	Memory  string
+	Disk    string
+	Images  []string // Image locations, in the order they're tried
+	Mounts  []string // "location -> mountPoint (ro|rw)"
+}
+
+// ReadTemplateVM reads the VM settings from a Lima template
+func ReadTemplateVM(data []byte) (TemplateVM, error) {
+	var raw struct {
+		VMType string `yaml:"vmType"`
+		Arch   string `yaml:"arch"`
+		VMOpts struct {
+			VZ struct {
+				Rosetta struct {
+					Enabled bool `yaml:"enabled"`
+				} `yaml:"rosetta"`
+			} `yaml:"vz"`
+		} `yaml:"vmOpts"`
+		CPUs   int    `yaml:"cpus"`
+		Memory string `yaml:"memory"`
+		Disk   string `yaml:"disk"`
+		Images []struct {
+			Location string `yaml:"location"`
+		} `yaml:"images"`
+		Mounts []struct {
+			Location   string `yaml:"location"`
+			MountPoint string `yaml:"mountPoint"`
+			Writable   bool   `yaml:"writable"`
+		} `yaml:"mounts"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return TemplateVM{}, fmt.Errorf("failed to parse Lima template: %w", err)
+	}
+
+	vm := TemplateVM{
+		Type:    raw.VMType,
+		Arch:    raw.Arch,
+		Rosetta: raw.VMOpts.VZ.Rosetta.Enabled,
+		CPUs:    raw.CPUs,
+		Memory:  raw.Memory,
+		Disk:    raw.Disk,
+	}
+	for _, img := range raw.Images {
+		vm.Images = append(vm.Images, img.Location)
+	}
+	for _, m := range raw.Mounts {
+		mode := "ro"
+		if m.Writable {
+			mode = "rw"
+		}
+		vm.Mounts = append(vm.Mounts, fmt.Sprintf("%s -> %s (%s)", m.Location, m.MountPoint, mode))
+	}
+	return vm, nil
+}
+
+// DesiredTemplateVM returns the VM settings a template generated from cfg
+// would have
+func DesiredTemplateVM(cfg *config.Config, projectDir string) (TemplateVM, error) {
+	tmpl, err := GenerateTemplate(cfg, projectDir)
+	if err != nil {
+		return TemplateVM{}, err
+	}
+	return ReadTemplateVM([]byte(tmpl))
+}
+
+// Template lines Resize rewrites; the generator puts each on its own line
+var (
+	cpusLine   = regexp.MustCompile(`(?m)^cpus: .*$`)
+	memoryLine = regexp.MustCompile(`(?m)^memory: .*$`)
+	diskLine   = regexp.MustCompile(`(?m)^disk: .*$`)
+)
+
+// SetTemplateResources updates the CPUs, memory and disk in a template
+// file so it keeps describing the VM; zero values are left alone
+func SetTemplateResources(path string, cpus int, memory, disk string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read Lima template: %w", err)
+	}
+	tmpl := string(data)
+	if cpus > 0 {
+		tmpl = cpusLine.ReplaceAllLiteralString(tmpl, fmt.Sprintf("cpus: %d", cpus))
+	}
+	if memory != "" {
+		tmpl = memoryLine.ReplaceAllLiteralString(tmpl, fmt.Sprintf("memory: %q", memory))
+	}
+	if disk != "" {
+		tmpl = diskLine.ReplaceAllLiteralString(tmpl, fmt.Sprintf("disk: %q", disk))
+	}
+	if err := os.WriteFile(path, []byte(tmpl), 0600); err != nil {
+		return fmt.Errorf("failed to write Lima template: %w", err)
+	}
+	return nil
+}
+
+// Resize changes a stopped VM's CPUs, memory and disk with limactl edit;
+// zero values are left alone. Lima grows the disk on the next start.
+func (m *Manager) Resize(ctx context.Context, name string, cpus int, memory, disk string) error {
+	if _, err := m.requireStopped(ctx, name); err != nil {
+		return err
+	}
+
+	var set []string
+	if cpus > 0 {
+		set = append(set, fmt.Sprintf(".cpus = %d", cpus))
+	}
+	if memory != "" {
+		set = append(set, fmt.Sprintf(".memory = %q", memory))
+	}
+	if disk != "" {
+		set = append(set, fmt.Sprintf(".disk = %q", disk))
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	err := m.run(ctx, call{
+		args:   []string{"--tty=false", "edit", "--set", strings.Join(set, " | "), name},
+		kind:   "command",
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to edit VM %s: %w", name, err)
+	}
+	return nil
+}
+
+// sizeUnits are the suffixes ParseSize accepts; like Lima, decimal and
+// binary suffixes both mean powers of 1024
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+var sizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)$`)
+
+// ParseSize converts a size like "50GiB" to bytes
+func ParseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 8GiB)", s)
+	}
+	unit, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, m[2])
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return int64(n * float64(unit)), nil
+}
@@ -0,0 +1,81 @@
+package lima
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestReadTemplateVM(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.VM.CPUs = 6
+	cfg.VM.Memory = "12GiB"
+
+	vm, err := DesiredTemplateVM(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vm.CPUs != 6 || vm.Memory != "12GiB" || vm.Disk != cfg.VM.Disk {
+		t.Errorf("unexpected resources: %+v", vm)
+	}
+	if vm.Type == "" || vm.Arch == "" || len(vm.Images) == 0 {
+		t.Errorf("type, arch and images should be read: %+v", vm)
+	}
+	if len(vm.Mounts) != 2 || !strings.HasSuffix(vm.Mounts[0], "(rw)") {
+		t.Errorf("unexpected mounts: %v", vm.Mounts)
+	}
+}
+
+func TestSetTemplateResources(t *testing.T) {
+	tmpl, err := GenerateTemplate(config.DefaultConfig(), "/tmp/testproject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "lima.yaml")
+	if err := os.WriteFile(path, []byte(tmpl), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetTemplateResources(path, 8, "", "200GiB"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	vm, err := ReadTemplateVM(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.CPUs != 8 || vm.Disk != "200GiB" || vm.Memory != config.DefaultConfig().VM.Memory {
+		t.Errorf("unexpected resources after update: %+v", vm)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "50GiB", want: 50 << 30},
+		{in: "50G", want: 50 << 30},
+		{in: "1.5TiB", want: 3 << 39},
+		{in: "512MiB", want: 512 << 20},
+		{in: "4096", want: 4096},
+		{in: "lots", err: true},
+		{in: "10PB", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseSize(%q) should fail", tt.in)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -1,112 +1,212 @@
 package lima
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"sort"
 	"strings"
+
+	"github.com/davidsenack/agentbox/internal/secrets"
 )
 
+// GuestSecretsDir is where injected secrets live inside the VM
+// It is a root-only tmpfs, so stopping the VM wipes it
+const GuestSecretsDir = "/etc/agentbox/secrets"
+
+// deliverSecretsScript reads "NAME SHA256 BASE64VALUE" lines from stdin and
+// writes each secret atomically as a root-owned 0600 file. Unchanged secrets
+// are skipped and secrets that are no longer delivered are removed. Values
+// never appear in argv or the script itself.
+const deliverSecretsScript = `set -eu
+umask 077
+dir=` + GuestSecretsDir + `
+mkdir -p "$dir"
+chown root:root "$dir"
+chmod 700 "$dir"
+keep=" "
+while read -r name sum value; do
+    keep="$keep$name "
+    file="$dir/$name"
+    if [ -f "$file" ] && [ "$(sha256sum "$file" | cut -d' ' -f1)" = "$sum" ]; then
+        continue
+    fi
+    tmp=$(mktemp "$dir/.tmp.XXXXXX")
+    printf '%s' "$value" | base64 -d > "$tmp"
+    chown root:root "$tmp"
+    chmod 600 "$tmp"
+    mv -f "$tmp" "$file"
+done
+for file in "$dir"/*; do
+    [ -f "$file" ] || continue
+    case "$keep" in
+        *" ${file##*/} "*) ;;
+        *) rm -f "$file" ;;
+    esac
+done
+`
+
+// GuestCACert is where the proxy's CA certificate is installed in the VM
+const GuestCACert = "/usr/local/share/ca-certificates/agentbox-proxy.crt"
+
+// installCAScript reads the proxy's CA certificate from stdin and adds it to
+// the system trust store. Node, Python requests and the AWS CLI bring their
+// own CA bundles, so the agent's shell is pointed at the system one too.
+const installCAScript = `set -eu
+crt=` + GuestCACert + `
+tmp=$(mktemp)
+cat > "$tmp"
+if ! cmp -s "$tmp" "$crt"; then
+    install -m 644 "$tmp" "$crt"
+    update-ca-certificates > /dev/null
+fi
+rm -f "$tmp"
+conf=/etc/agentbox/proxy.conf
+mkdir -p /etc/agentbox
+touch "$conf"
+if ! grep -q '^NODE_EXTRA_CA_CERTS=' "$conf"; then
+    cat >> "$conf" << EOF
+NODE_EXTRA_CA_CERTS="$crt"
+REQUESTS_CA_BUNDLE="/etc/ssl/certs/ca-certificates.crt"
+AWS_CA_BUNDLE="/etc/ssl/certs/ca-certificates.crt"
+EOF
+fi
+`
+
+// wipeSecretsScript removes every injected secret from the VM
+const wipeSecretsScript = `rm -f ` + GuestSecretsDir + `/* ` + GuestSecretsDir + `/.tmp.*`
+
 // Shell opens an interactive shell in the Lima VM as the 'agent' user
-// allowedEnvVars specifies which env vars to inject securely (stored in root-only files)
-func (m *Manager) Shell(name string, allowedEnvVars []string) error {
-	// Inject secrets securely - write to root-only files, not env vars
-	// This way `echo $ANTHROPIC_API_KEY` shows nothing
-	if err := injectSecrets(name, allowedEnvVars); err != nil {
-		return fmt.Errorf("failed to inject secrets: %w", err)
+// Secrets must be delivered beforehand with InjectSecrets
+func (m *Manager) Shell(ctx context.Context, name string) error {
+	// Start the shell without any secrets in environment; m.env() blocks
+	// dangerous environment variables from leaking via Lima's propagation
+	return m.run(ctx, call{
+		args:        []string{"shell", name, "--", "sudo", "-i", "-u", "agent"},
+		stdin:       os.Stdin,
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
+		passthrough: true,
+	})
+}
+
+// Exec runs a command in the VM as the 'agent' user
+// limactl allocates a TTY only when stdout is a terminal
+// workdir is the guest directory to run in ("" uses the home directory)
+func (m *Manager) Exec(ctx context.Context, name, workdir string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	args := []string{"shell"}
+	if workdir != "" {
+		args = append(args, "--workdir", workdir)
 	}
+	args = append(args, name, "--", "sudo", "-u", "agent", "-H", "--")
+	args = append(args, command...)
 
-	// Start the shell without any secrets in environment
-	args := []string{"shell", name, "--", "sudo", "-i", "-u", "agent"}
+	return m.run(ctx, call{
+		args:        args,
+		stdin:       stdin,
+		stdout:      stdout,
+		stderr:      stderr,
+		passthrough: true,
+	})
+}
 
-	cmd := exec.Command("limactl", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// RunScript runs a script in the VM as root without a TTY
+// The script is streamed over stdin into a temp file, so its own commands
+// don't read it; stdout and stderr both go to out
+func (m *Manager) RunScript(ctx context.Context, name, script string, out io.Writer) error {
+	const runner = `f=$(mktemp); cat > "$f"; bash "$f" < /dev/null; rc=$?; rm -f "$f"; exit $rc`
 
-	// Block dangerous environment variables from leaking via Lima's propagation
-	blockedPatterns := getBlockedEnvPatterns()
-	cmd.Env = filterEnv(os.Environ(), blockedPatterns)
+	return m.run(ctx, call{
+		args:        []string{"shell", name, "--", "sudo", "sh", "-c", runner},
+		kind:        "provision",
+		stdin:       strings.NewReader(script),
+		stdout:      out,
+		stderr:      out,
+		passthrough: true,
+	})
+}
 
-	return cmd.Run()
+// Copy copies files between the host and a VM with limactl copy
+// Guest paths are written as <instance>:<path>
+func (m *Manager) Copy(ctx context.Context, src, dst string) error {
+	err := m.run(ctx, call{args: []string{"copy", "--recursive", src, dst}, kind: "command"})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return nil
 }
 
-// injectSecrets writes allowed env vars to secure root-only files in the VM
-// The claude wrapper script reads from these files
-func injectSecrets(vmName string, allowedEnvVars []string) error {
-	for _, varName := range allowedEnvVars {
-		val := os.Getenv(varName)
-		if val == "" {
-			continue
-		}
+// InjectSecrets writes secret env values to root-only files in the VM
+// All secrets are streamed over stdin in a single call; the claude wrapper
+// script reads them from GuestSecretsDir. This way `echo $ANTHROPIC_API_KEY`
+// shows nothing and the values never appear in a process's argv.
+func (m *Manager) InjectSecrets(ctx context.Context, name string, secretEnv map[string]string) error {
+	payload, err := encodeSecrets(secretEnv)
+	if err != nil {
+		return err
+	}
 
-		// Write to /etc/agentbox/secrets/<varname> with mode 600, owned by root
-		// The wrapper scripts read from here using sudo
-		script := fmt.Sprintf(`
-mkdir -p /etc/agentbox/secrets
-echo -n %q > /etc/agentbox/secrets/%s
-chmod 600 /etc/agentbox/secrets/%s
-chown root:root /etc/agentbox/secrets/%s
-`, val, varName, varName, varName)
-
-		cmd := exec.Command("limactl", "shell", vmName, "--", "sudo", "sh", "-c", script)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to inject %s: %w", varName, err)
-		}
+	err = m.run(ctx, call{
+		args:  []string{"shell", name, "--", "sudo", "sh", "-c", deliverSecretsScript},
+		kind:  "command",
+		stdin: bytes.NewReader(payload),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to inject secrets: %w", err)
 	}
 	return nil
 }
 
-// getBlockedEnvPatterns returns patterns of env vars to block from propagation
-func getBlockedEnvPatterns() []string {
-	return []string{
-		"AWS_",
-		"GOOGLE_",
-		"AZURE_",
-		"SSH_",
-		"GPG_",
-		"HOMEBREW_",
-		"ANTHROPIC_",
-		"OPENAI_",
-		"_TOKEN",
-		"_SECRET",
-		"_KEY",
-		"_PASSWORD",
-		"_CREDENTIALS",
-		"_API_KEY",
+// InstallCA adds the proxy's CA certificate to the VM's trust store
+func (m *Manager) InstallCA(ctx context.Context, name string, certPEM []byte) error {
+	err := m.run(ctx, call{
+		args:  []string{"shell", name, "--", "sudo", "sh", "-c", installCAScript},
+		kind:  "command",
+		stdin: bytes.NewReader(certPEM),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install the proxy CA: %w", err)
 	}
+	return nil
 }
 
-// filterEnv removes environment variables matching blocked patterns
-func filterEnv(env []string, blocked []string) []string {
-	result := make([]string, 0, len(env))
-	for _, e := range env {
-		parts := strings.SplitN(e, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key := parts[0]
-
-		shouldBlock := false
-		for _, pattern := range blocked {
-			if strings.HasPrefix(pattern, "_") {
-				// Suffix pattern
-				if strings.HasSuffix(key, pattern) {
-					shouldBlock = true
-					break
-				}
-			} else {
-				// Prefix pattern
-				if strings.HasPrefix(key, pattern) {
-					shouldBlock = true
-					break
-				}
-			}
+// WipeSecrets removes all injected secrets from the VM
+func (m *Manager) WipeSecrets(ctx context.Context, name string) error {
+	err := m.run(ctx, call{
+		args: []string{"shell", name, "--", "sudo", "sh", "-c", wipeSecretsScript},
+		kind: "command",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wipe secrets: %w", err)
+	}
+	return nil
+}
+
+// encodeSecrets renders secrets as "NAME SHA256 BASE64VALUE" lines
+// Empty values are skipped; names must be valid env var names
+func encodeSecrets(secretEnv map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(secretEnv))
+	for name := range secretEnv {
+		if !secrets.ValidName(name) {
+			return nil, fmt.Errorf("invalid secret name %q", name)
 		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		if !shouldBlock {
-			result = append(result, e)
+	var buf bytes.Buffer
+	for _, name := range names {
+		val := secretEnv[name]
+		if val == "" {
+			continue
 		}
+		sum := sha256.Sum256([]byte(val))
+		fmt.Fprintf(&buf, "%s %s %s\n", name, hex.EncodeToString(sum[:]), base64.StdEncoding.EncodeToString([]byte(val)))
 	}
-	return result
+	return buf.Bytes(), nil
 }
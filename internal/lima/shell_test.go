@@ -0,0 +1,74 @@
+package lima
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeSecrets(t *testing.T) {
+	secretValue := "p$ss`whoami`'\"\n$(rm -rf /)"
+	payload, err := encodeSecrets(map[string]string{
+		"OPENAI_API_KEY":    secretValue,
+		"ANTHROPIC_API_KEY": "sk-ant-test",
+		"EMPTY":             "",
+	})
+	if err != nil {
+		t.Fatalf("encodeSecrets failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(payload)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines (empty values skipped), got %d: %q", len(lines), payload)
+	}
+
+	// Sorted by name, one "NAME SHA256 BASE64" record per line
+	fields := strings.Fields(lines[1])
+	if len(fields) != 3 || fields[0] != "OPENAI_API_KEY" || len(fields[1]) != 64 {
+		t.Fatalf("unexpected record: %q", lines[1])
+	}
+	decoded, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil || string(decoded) != secretValue {
+		t.Errorf("value did not round-trip: %q (%v)", decoded, err)
+	}
+	if strings.Contains(string(payload), "whoami") {
+		t.Error("raw value must not appear in the payload")
+	}
+}
+
+func TestEncodeSecretsRejectsBadNames(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "A B", "1ABC", "X;rm"} {
+		if _, err := encodeSecrets(map[string]string{name: "value"}); err == nil {
+			t.Errorf("expected error for name %q", name)
+		}
+	}
+}
+
+func TestDeliverScriptHasNoSecrets(t *testing.T) {
+	// The script is passed as argv; values must only arrive on stdin
+	if !strings.Contains(deliverSecretsScript, "read -r name sum value") {
+		t.Error("delivery script should read secrets from stdin")
+	}
+	if !strings.Contains(deliverSecretsScript, "mv -f") {
+		t.Error("delivery script should write atomically")
+	}
+}
+
+func TestInstallCA(t *testing.T) {
+	m := fakeLimactl(t, `dir=$(dirname "$0"); cat > "$dir/stdin"; echo "$@" > "$dir/args"`)
+	cert := []byte("-----BEGIN CERTIFICATE-----\nproxy\n-----END CERTIFICATE-----\n")
+
+	if err := m.InstallCA(t.Context(), "agentbox-demo", cert); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Dir(m.limactl)
+	if data, _ := os.ReadFile(filepath.Join(dir, "stdin")); string(data) != string(cert) {
+		t.Errorf("certificate should arrive on stdin, got %q", data)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if !strings.HasPrefix(string(args), "shell agentbox-demo -- sudo sh -c") || !strings.Contains(string(args), "update-ca-certificates") {
+		t.Errorf("args = %s", args)
+	}
+}
@@ -0,0 +1,139 @@
+package lima
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// snapshotDisks are the files in a Lima instance dir that hold guest state
+// diffdisk is the VM's writable disk; basedisk is the image it was created
+// from, which a qcow2 diffdisk depends on
+var snapshotDisks = []string{"basedisk", "diffdisk"}
+
+// sparseChunk is the block size sparse copies compare against zeros
+const sparseChunk = 1 << 20
+
+// SaveDisks copies a stopped VM's disks into dir
+func (m *Manager) SaveDisks(ctx context.Context, name, dir string) error {
+	inst, err := m.requireStopped(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	instDir := inst.Dir
+	if _, err := os.Stat(filepath.Join(instDir, "diffdisk")); err != nil {
+		return fmt.Errorf("VM %s has no disk yet; start it once before taking a snapshot", name)
+	}
+
+	for _, disk := range snapshotDisks {
+		src := filepath.Join(instDir, disk)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copySparse(src, filepath.Join(dir, disk)); err != nil {
+			return fmt.Errorf("failed to save %s: %w", disk, err)
+		}
+	}
+	return nil
+}
+
+// RestoreDisks replaces a stopped VM's disks with the ones saved in dir
+// Each disk is copied next to the original and renamed over it, so a
+// failed restore leaves the VM as it was
+func (m *Manager) RestoreDisks(ctx context.Context, name, dir string) error {
+	inst, err := m.requireStopped(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	instDir := inst.Dir
+	for _, disk := range snapshotDisks {
+		src := filepath.Join(dir, disk)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(instDir, disk)
+		tmp := dst + ".restore"
+		if err := copySparse(src, tmp); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to restore %s: %w", disk, err)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to restore %s: %w", disk, err)
+		}
+	}
+	return nil
+}
+
+// requireStopped returns the VM, failing unless it exists and is stopped
+func (m *Manager) requireStopped(ctx context.Context, name string) (*Instance, error) {
+	inst, err := m.Inspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if inst == nil {
+		return nil, fmt.Errorf("VM %s does not exist", name)
+	}
+	if inst.Running() {
+		return nil, fmt.Errorf("VM %s is running; stop it first", name)
+	}
+	return inst, nil
+}
+
+// copySparse copies a file, leaving holes where the source is all zeros
+// VM disks are mostly empty, so this keeps copies from taking their full
+// apparent size
+func copySparse(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	zeros := make([]byte, sparseChunk)
+	buf := make([]byte, sparseChunk)
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			if bytes.Equal(buf[:n], zeros[:n]) {
+				_, werr := out.Seek(int64(n), io.SeekCurrent)
+				if werr != nil {
+					out.Close()
+					return werr
+				}
+			} else if _, werr := out.Write(buf[:n]); werr != nil {
+				out.Close()
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+
+	// Seeking past the end doesn't extend the file; a trailing hole needs this
+	if err := out.Truncate(info.Size()); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
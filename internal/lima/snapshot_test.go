@@ -0,0 +1,37 @@
+package lima
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopySparse(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "diffdisk")
+
+	// Data, a hole, more data, then a trailing hole
+	content := make([]byte, 5*sparseChunk+123)
+	copy(content, "boot sector")
+	copy(content[3*sparseChunk:], "root fs")
+	if err := os.WriteFile(src, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "copy")
+	if err := copySparse(src, dst); err != nil {
+		t.Fatalf("copySparse failed: %v", err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("copy differs from the source")
+	}
+	if info, _ := os.Stat(dst); info.Mode().Perm() != 0600 {
+		t.Errorf("copy mode = %v, want 0600", info.Mode().Perm())
+	}
+}
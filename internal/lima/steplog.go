@@ -0,0 +1,71 @@
+package lima
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Guest provisioning logs, rewritten by every provision run
+const (
+	GuestLogDir       = "/var/log/agentbox"
+	GuestProvisionLog = GuestLogDir + "/provision.log" // All output
+	GuestStepLog      = GuestLogDir + "/steps.jsonl"   // One Step per line
+)
+
+// Project files (under .agentbox) the guest logs are copied to
+const (
+	ProvisionLogFile = "provision.log"
+	StepLogFile      = "provision-steps.jsonl"
+)
+
+// Step statuses
+const (
+	StepOK      = "ok"
+	StepChanged = "changed"
+	StepFailed  = "failed"
+)
+
+// Step is one entry of the step log
+type Step struct {
+	Time       string   `json:"time" yaml:"time"`
+	Section    string   `json:"section" yaml:"section"`
+	Step       string   `json:"step" yaml:"step"`
+	Status     string   `json:"status" yaml:"status"`
+	Duration   int      `json:"duration_s" yaml:"duration_s"`
+	ExitCode   int      `json:"exit_code" yaml:"exit_code"`
+	StderrTail []string `json:"stderr_tail,omitempty" yaml:"stderr_tail,omitempty"`
+}
+
+// ParseStepLog reads a step log
+// A last line cut short (the guest stopped mid-write) is ignored
+func ParseStepLog(data []byte) ([]Step, error) {
+	var steps []Step
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var step Step
+		if err := json.Unmarshal(line, &step); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("invalid step log line %d: %w", i+1, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// FailedSteps returns the steps that failed
+func FailedSteps(steps []Step) []Step {
+	var failed []Step
+	for _, step := range steps {
+		if step.Status == StepFailed {
+			failed = append(failed, step)
+		}
+	}
+	return failed
+}
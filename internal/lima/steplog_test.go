@@ -0,0 +1,61 @@
+package lima
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestParseStepLog(t *testing.T) {
+	data := []byte(`{"time":"2026-01-02T03:04:05Z","section":"base","step":"agent user","status":"ok","duration_s":0,"exit_code":0}
+
+{"section":"agents","step":"bd v0.20.1","status":"failed","duration_s":12,"exit_code":1,"stderr_tail":["go: boom"]}
+{"section":"pack`)
+
+	steps, err := ParseStepLog(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps (truncated line skipped), got %d", len(steps))
+	}
+	failed := FailedSteps(steps)
+	if len(failed) != 1 || failed[0].Step != "bd v0.20.1" || failed[0].StderrTail[0] != "go: boom" {
+		t.Errorf("unexpected failed steps: %+v", failed)
+	}
+
+	if _, err := ParseStepLog([]byte("not json\n{}\n")); err == nil {
+		t.Error("expected a corrupt line to be rejected")
+	}
+}
+
+func TestProvisionScriptSteps(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Provision = config.ProvisionConfig{
+		Apt:   []string{"jq"},
+		Steps: []config.ProvisionStep{{Name: "seed", Run: "make seed"}},
+	}
+	script := generateProvisionScript(cfg)
+
+	for _, want := range []string{
+		"trap agentbox_exit EXIT",
+		GuestLogDir,
+		`chmod 0700 "$AGENTBOX_LOG_DIR"`,
+		`agentbox_step "system packages"`,
+		`agentbox_step "go ${GO_VERSION}"`,
+		`agentbox_step "claude-code ${CLAUDE_VERSION}"`,
+		`agentbox_step "$1 $3"`,
+		`agentbox_step "apt packages"`,
+		`agentbox_step "step: "'seed'`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q", want)
+		}
+	}
+
+	// The step log ends before the guest is marked ready
+	if strings.Index(script, "agentbox_step_end\ntouch /etc/agentbox-ready") < 0 {
+		t.Error("last step should be closed before Mark Ready")
+	}
+}
@@ -3,7 +3,7 @@ package lima
 import (
 	"bytes"
 	"fmt"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -13,37 +13,41 @@ import (
 const limaTemplateContent = `# AgentBox Lima VM Configuration
 # Generated by agentbox - do not edit manually
 
-vmType: "vz"
+vmType: {{ .VM.Type | quote }}
+arch: {{ .VM.Arch | quote }}
+{{- if .VM.Rosetta }}
 vmOpts:
   vz:
     rosetta:
       enabled: true
       binfmt: true
+{{- end }}
 
 cpus: {{ .Config.VM.CPUs }}
-memory: "{{ .Config.VM.Memory }}"
-disk: "{{ .Config.VM.Disk }}"
+memory: {{ .Config.VM.Memory | quote }}
+disk: {{ .Config.VM.Disk | quote }}
 
+# Tried in order; Lima checks each digest after download
 images:
-  # Pre-built AgentBox images with all tools installed
-  - location: "https://github.com/davidsenack/agentbox/releases/download/image-v1.0.0/agentbox-ubuntu-24.04-arm64.qcow2"
-    arch: "aarch64"
-  - location: "https://github.com/davidsenack/agentbox/releases/download/image-v1.0.0/agentbox-ubuntu-24.04-amd64.qcow2"
-    arch: "x86_64"
-  # Fallback to stock Ubuntu if pre-built image unavailable
-  - location: "https://cloud-images.ubuntu.com/releases/24.04/release/ubuntu-24.04-server-cloudimg-arm64.img"
-    arch: "aarch64"
-  - location: "https://cloud-images.ubuntu.com/releases/24.04/release/ubuntu-24.04-server-cloudimg-amd64.img"
-    arch: "x86_64"
-
-# CRITICAL: Only mount workspace and artifacts - nothing else
+{{- range .Images }}
+  - location: {{ .Location | quote }}
+    arch: {{ .Arch | quote }}
+{{- if .Digest }}
+    digest: {{ .Digest | quote }}
+{{- end }}
+{{- end }}
+
+# CRITICAL: Only the mounts in agentbox.yaml (workspace and artifacts by default)
+{{- if .Mounts }}
 mounts:
-  - location: "{{ .WorkspacePath }}"
-    mountPoint: "/workspace"
-    writable: true
-  - location: "{{ .ArtifactsPath }}"
-    mountPoint: "/artifacts"
-    writable: true
+{{- range .Mounts }}
+  - location: {{ .Location | quote }}
+    mountPoint: {{ .MountPoint | quote }}
+    writable: {{ .Writable }}
+{{- end }}
+{{- else }}
+mounts: []
+{{- end }}
 
 # Network: use Lima's default networking (user-v2)
 # This provides outbound internet access and host connectivity via socket forwarding
@@ -67,7 +71,7 @@ probes:
     script: |
       #!/bin/bash
       test -f /etc/agentbox-ready
-    hint: "Run 'agentbox reset <name>' if this fails"
+    hint: "Run 'agentbox status <name>' to see which provisioning step failed"
 
 # Environment propagation is disabled - secrets injected explicitly
 propagateProxyEnv: false
@@ -98,10 +102,25 @@ func indent(spaces int, v string) string {
 
 // GenerateTemplate creates a Lima YAML template for the given configuration
 func GenerateTemplate(cfg *config.Config, projectDir string) (string, error) {
+	return renderTemplate(cfg, projectDir, generateProvisionScript(cfg))
+}
+
+// GenerateTemplateGasTown creates a Lima YAML template for Gas Town rigs
+// The rig is built inside the VM on first boot using the provided repo URL
+func GenerateTemplateGasTown(cfg *config.Config, projectDir string, rigName string, repoURL string) (string, error) {
+	// Use Gas Town specific provision script with repo URL
+	return renderTemplate(cfg, projectDir, generateProvisionScriptGasTown(cfg, rigName, repoURL))
+}
+
+// renderTemplate fills limaTemplateContent for a project
+func renderTemplate(cfg *config.Config, projectDir, provisionScript string) (string, error) {
 	funcMap := template.FuncMap{
 		"indent": func(spaces int, v string) string {
 			return indent(spaces, v)
 		},
+		// Go's quoting is valid YAML double-quoted style, so paths and
+		// sizes from agentbox.yaml can't break out of their value
+		"quote": strconv.Quote,
 	}
 
 	tmpl, err := template.New("lima").Funcs(funcMap).Parse(limaTemplateContent)
@@ -109,54 +128,39 @@ func GenerateTemplate(cfg *config.Config, projectDir string) (string, error) {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	provisionScript := generateProvisionScript(cfg)
-
-	data := struct {
-		Config          *config.Config
-		WorkspacePath   string
-		ArtifactsPath   string
-		ProvisionScript string
-	}{
-		Config:          cfg,
-		WorkspacePath:   filepath.Join(projectDir, "workspace"),
-		ArtifactsPath:   filepath.Join(projectDir, "artifacts"),
-		ProvisionScript: provisionScript,
+	vm, err := ResolveVMSettings(cfg.VM)
+	if err != nil {
+		return "", err
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	if err := ValidateToolchains(cfg); err != nil {
+		return "", err
 	}
-
-	return buf.String(), nil
-}
-
-// GenerateTemplateGasTown creates a Lima YAML template for Gas Town rigs
-// The rig is built inside the VM on first boot using the provided repo URL
-func GenerateTemplateGasTown(cfg *config.Config, projectDir string, rigName string, repoURL string) (string, error) {
-	funcMap := template.FuncMap{
-		"indent": func(spaces int, v string) string {
-			return indent(spaces, v)
-		},
+	if err := ValidateProvision(cfg.Provision); err != nil {
+		return "", err
 	}
 
-	tmpl, err := template.New("lima").Funcs(funcMap).Parse(limaTemplateContent)
+	images, err := ResolveImages(cfg.VM, projectDir, vm.Arch)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		return "", err
 	}
 
-	// Use Gas Town specific provision script with repo URL
-	provisionScript := generateProvisionScriptGasTown(cfg, rigName, repoURL)
+	mounts, err := ResolveMounts(cfg.Mounts, projectDir)
+	if err != nil {
+		return "", err
+	}
 
 	data := struct {
 		Config          *config.Config
-		WorkspacePath   string
-		ArtifactsPath   string
+		VM              VMSettings
+		Images          []Image
+		Mounts          []Mount
 		ProvisionScript string
 	}{
 		Config:          cfg,
-		WorkspacePath:   filepath.Join(projectDir, "workspace"),
-		ArtifactsPath:   filepath.Join(projectDir, "artifacts"),
+		VM:              vm,
+		Images:          images,
+		Mounts:          mounts,
 		ProvisionScript: provisionScript,
 	}
 
@@ -168,8 +172,49 @@ func GenerateTemplateGasTown(cfg *config.Config, projectDir string, rigName stri
 	return buf.String(), nil
 }
 
+// generateProvisionScript renders the provision script with every section
 func generateProvisionScript(cfg *config.Config) string {
-	return fmt.Sprintf(`#!/bin/bash
+	return provisionScript(cfg, ProvisionSections)
+}
+
+// provisionScript renders the provision script for the given sections
+// The header and the Mark Ready footer are always included
+func provisionScript(cfg *config.Config, sections []string) string {
+	want := make(map[string]bool, len(sections))
+	for _, section := range sections {
+		want[section] = true
+	}
+
+	var b strings.Builder
+	b.WriteString(provisionHeader)
+	if want[SectionBase] {
+		fmt.Fprintf(&b, provisionBaseSetup, cfg.Network.ProxyPort)
+	}
+	if want[SectionToolchains] {
+		b.WriteString(generateToolchainScript(cfg.Toolchains))
+	}
+	if want[SectionAgents] {
+		b.WriteString(generateAgentsScript(cfg.Agents))
+	}
+	if want[SectionBase] {
+		b.WriteString("\n" + provisionBaseShell)
+		b.WriteString(generateAWSCredentialsScript(cfg))
+	}
+	if want[SectionPackages] {
+		b.WriteString(generatePackagesScript(cfg.Provision))
+	}
+	if want[SectionSteps] {
+		b.WriteString(generateStepsScript(cfg.Provision))
+	}
+	if want[SectionPackages] && want[SectionSteps] && !provisionEmpty(cfg.Provision) {
+		fmt.Fprintf(&b, "\necho %s > /etc/agentbox/%s\n", ProvisionHash(cfg.Provision), ProvisionHashFile)
+	}
+	b.WriteString("\n" + provisionFooter)
+	return b.String()
+}
+
+// provisionHeader starts every provision script
+const provisionHeader = `#!/bin/bash
 set -euo pipefail
 
 # =============================================================================
@@ -179,8 +224,84 @@ set -euo pipefail
 
 export DEBIAN_FRONTEND=noninteractive
 
+# --- Logs (read by 'agentbox status'; cover the latest run only) ---
+# provision.log has all output, stderr.log just stderr, and steps.jsonl one
+# JSON line per step with its status, duration and stderr tail
+# Output can include secrets, so only the lima user (which copies the logs to
+# the host) can read them, not the agent
+AGENTBOX_LOG_DIR=/var/log/agentbox
+AGENTBOX_LOG_OWNER="${LIMA_CIDATA_USER:-${SUDO_USER:-root}}"
+[ "$AGENTBOX_LOG_OWNER" != agent ] || AGENTBOX_LOG_OWNER=root
+mkdir -p "$AGENTBOX_LOG_DIR"
+chown "$AGENTBOX_LOG_OWNER" "$AGENTBOX_LOG_DIR" 2>/dev/null || true
+chmod 0700 "$AGENTBOX_LOG_DIR"
+: > "$AGENTBOX_LOG_DIR/provision.log"
+: > "$AGENTBOX_LOG_DIR/stderr.log"
+: > "$AGENTBOX_LOG_DIR/steps.jsonl"
+exec > >(tee -a "$AGENTBOX_LOG_DIR/provision.log") 2>&1
+exec 2> >(tee -a "$AGENTBOX_LOG_DIR/stderr.log" >&2)
+
 echo "Starting AgentBox provisioning..."
 
+# Progress markers, read by 'agentbox provision'
+AGENTBOX_SECTION=""
+AGENTBOX_STEP=""
+AGENTBOX_STEP_STATUS=ok
+AGENTBOX_STEP_CODE=0
+agentbox_section() { AGENTBOX_SECTION="$1"; echo "::agentbox:: section $*"; }
+agentbox_changed() {
+    [ "$AGENTBOX_STEP_STATUS" = failed ] || AGENTBOX_STEP_STATUS=changed
+    echo "::agentbox:: changed $*"
+}
+agentbox_failed() {
+    local rc=$?
+    AGENTBOX_STEP_STATUS=failed
+    [ "$AGENTBOX_STEP_CODE" -ne 0 ] || AGENTBOX_STEP_CODE=$(( rc ? rc : 1 ))
+    echo "::agentbox:: failed $*"
+}
+
+# agentbox_step <name> ends the current step and starts the next
+agentbox_step() {
+    agentbox_step_end
+    AGENTBOX_STEP="$1"
+    AGENTBOX_STEP_SECTION="$AGENTBOX_SECTION"
+    AGENTBOX_STEP_STATUS=ok
+    AGENTBOX_STEP_CODE=0
+    AGENTBOX_STEP_START=$(date +%s)
+    AGENTBOX_STEP_ERR=$(stat -c %s "$AGENTBOX_LOG_DIR/stderr.log")
+}
+agentbox_step_end() {
+    [ -n "$AGENTBOX_STEP" ] || return 0
+    # Give tee a moment to write out the stderr of a failure
+    [ "$AGENTBOX_STEP_STATUS" != failed ] || sleep 0.2
+    tail -c +$((AGENTBOX_STEP_ERR + 1)) "$AGENTBOX_LOG_DIR/stderr.log" | tail -n 20 | python3 -c '
+import json, sys, time
+section, step, status, start, code = sys.argv[1:]
+entry = {
+    "time": time.strftime("%Y-%m-%dT%H:%M:%SZ", time.gmtime()),
+    "section": section,
+    "step": step,
+    "status": status,
+    "duration_s": int(time.time()) - int(start),
+    "exit_code": int(code),
+}
+if status == "failed":
+    entry["stderr_tail"] = sys.stdin.read().splitlines()
+print(json.dumps(entry))
+' "$AGENTBOX_STEP_SECTION" "$AGENTBOX_STEP" "$AGENTBOX_STEP_STATUS" "$AGENTBOX_STEP_START" "$AGENTBOX_STEP_CODE" >> "$AGENTBOX_LOG_DIR/steps.jsonl"
+    AGENTBOX_STEP=""
+}
+# A command failing under set -e fails the step it was part of
+agentbox_exit() {
+    local rc=$?
+    if [ "$rc" -ne 0 ] && [ -n "$AGENTBOX_STEP" ]; then
+        AGENTBOX_STEP_CODE=$rc
+        agentbox_failed "$AGENTBOX_STEP"
+    fi
+    agentbox_step_end
+}
+trap agentbox_exit EXIT
+
 # Check if running on pre-built AgentBox image
 PREBUILT=false
 if [ -f /etc/agentbox-image ]; then
@@ -188,7 +309,14 @@ if [ -f /etc/agentbox-image ]; then
     echo "Detected pre-built AgentBox image - fast provisioning mode"
 fi
 
+`
+
+// provisionBaseSetup creates the agent user, saves the proxy config and, on
+// stock Ubuntu, installs the system packages and shell (format arg: proxy port)
+const provisionBaseSetup = `agentbox_section base
+
 # --- User Creation (only if not pre-built) ---
+agentbox_step "agent user"
 if ! id -u agent >/dev/null 2>&1; then
     echo "Creating agent user..."
     useradd -m -s /bin/zsh -G sudo agent
@@ -197,6 +325,7 @@ if ! id -u agent >/dev/null 2>&1; then
 fi
 
 # --- Proxy Configuration ---
+agentbox_step "proxy config"
 HOST_GATEWAY=$(ip route | grep "default.*lima0" | head -1 | awk '{print $3}' || true)
 if [ -z "$HOST_GATEWAY" ]; then
     HOST_GATEWAY=$(ip route | grep default | head -1 | awk '{print $3}' || true)
@@ -224,33 +353,24 @@ unset HTTP_PROXY HTTPS_PROXY http_proxy https_proxy
 
 # --- Full provisioning only if not pre-built ---
 if [ "$PREBUILT" = false ]; then
+    agentbox_step "system packages"
     echo "Stock Ubuntu detected - installing all packages (this takes a while)..."
 
     # System Packages
     apt-get update
     apt-get install -y \
         zsh build-essential curl wget git jq ripgrep fzf tmux neovim \
-        unzip ca-certificates gnupg python3 python3-pip python3-venv
-
-    # Node.js
-    mkdir -p /etc/apt/keyrings
-    curl -fsSL https://deb.nodesource.com/gpgkey/nodesource-repo.gpg.key | gpg --dearmor -o /etc/apt/keyrings/nodesource.gpg
-    echo "deb [signed-by=/etc/apt/keyrings/nodesource.gpg] https://deb.nodesource.com/node_22.x nodistro main" > /etc/apt/sources.list.d/nodesource.list
-    apt-get update && apt-get install -y nodejs
-
-    # Go
-    GO_VERSION="1.22.0"
-    ARCH=$(dpkg --print-architecture)
-    curl -fsSL "https://go.dev/dl/go${GO_VERSION}.linux-${ARCH}.tar.gz" | tar -C /usr/local -xzf -
-    echo 'export PATH=$PATH:/usr/local/go/bin' > /etc/profile.d/go.sh
+        unzip xz-utils ca-certificates gnupg python3 python3-pip python3-venv
 
     # Starship
-    curl -fsSL https://starship.rs/install.sh | sh -s -- -y
+    command -v starship >/dev/null || curl -fsSL https://starship.rs/install.sh | sh -s -- -y
 
     # mise (needs HOME set)
     export HOME=/root
-    curl -fsSL https://mise.run | sh
-    mv /root/.local/bin/mise /usr/local/bin/mise 2>/dev/null || true
+    if ! command -v mise >/dev/null; then
+        curl -fsSL https://mise.run | sh
+        mv /root/.local/bin/mise /usr/local/bin/mise 2>/dev/null || true
+    fi
 
     # Set zsh as default
     chsh -s /bin/zsh agent
@@ -259,7 +379,9 @@ if [ "$PREBUILT" = false ]; then
     sudo -u agent mkdir -p /home/agent/.local/bin /home/agent/.config/nvim /home/agent/go/bin
 
     # Oh My Zsh
-    sudo -u agent sh -c 'RUNZSH=no CHSH=no sh -c "$(curl -fsSL https://raw.githubusercontent.com/ohmyzsh/ohmyzsh/master/tools/install.sh)"'
+    if [ ! -d /home/agent/.oh-my-zsh ]; then
+        sudo -u agent sh -c 'RUNZSH=no CHSH=no sh -c "$(curl -fsSL https://raw.githubusercontent.com/ohmyzsh/ohmyzsh/master/tools/install.sh)"'
+    fi
 
     # .zshrc
     cat > /home/agent/.zshrc << 'ZSHRC'
@@ -322,34 +444,15 @@ vim.opt.clipboard = "unnamedplus"
 vim.g.mapleader = " "
 NVIM
     chown -R agent:agent /home/agent/.config
-
-    # AI tools (install globally)
-    npm install -g @anthropic-ai/claude-code 2>/dev/null || true
-    sudo -u agent bash -c 'export PATH="/usr/local/go/bin:$PATH" GOPATH="$HOME/go"; go install github.com/opencode-ai/opencode@latest' 2>/dev/null || true
-
-    # Gas Town (gt and bd CLIs)
-    sudo -u agent bash -c 'export PATH="/usr/local/go/bin:$PATH"; export GOPATH="$HOME/go"; go install github.com/steveyegge/gastown/cmd/gt@latest' 2>/dev/null || true
-    sudo -u agent bash -c 'export PATH="/usr/local/go/bin:$PATH"; export GOPATH="$HOME/go"; go install github.com/steveyegge/beads/cmd/bd@latest' 2>/dev/null || true
 fi
+`
 
-# --- Gas Town installation (runs for both pre-built and stock) ---
-# Install gt and bd if not present (handles pre-built images that may be out of date)
-if ! sudo -u agent bash -c 'command -v gt' &>/dev/null || ! sudo -u agent bash -c 'command -v bd' &>/dev/null; then
-    echo "Installing Gas Town dependencies..."
-    # bd requires libicu-dev for go-icu-regex
-    apt-get update -qq && apt-get install -y -qq libicu-dev pkg-config
-
-    if ! sudo -u agent bash -c 'command -v gt' &>/dev/null; then
-        echo "Installing Gas Town (gt)..."
-        sudo -u agent bash -c 'export PATH="/usr/local/go/bin:$PATH"; export GOPATH="$HOME/go"; go install github.com/steveyegge/gastown/cmd/gt@latest' || echo "Warning: gt installation failed"
-    fi
-    if ! sudo -u agent bash -c 'command -v bd' &>/dev/null; then
-        echo "Installing beads (bd)..."
-        sudo -u agent bash -c 'export PATH="/usr/local/go/bin:$PATH"; export GOPATH="$HOME/go"; CGO_ENABLED=1 go install github.com/steveyegge/beads/cmd/bd@latest' || echo "Warning: bd installation failed"
-    fi
-fi
+// provisionBaseShell refreshes the prompt and shell config and sets up the
+// secure secrets wrapper
+const provisionBaseShell = `agentbox_section base
 
 # --- Prompt config (runs for both pre-built and stock) ---
+agentbox_step "shell config"
 # Always update Starship config to latest
 mkdir -p /home/agent/.config
 cat > /home/agent/.config/starship.toml << 'STARSHIP'
@@ -382,10 +485,10 @@ chown -R agent:agent /home/agent/.config
 
 # Install zsh plugins if missing
 if [ ! -d /home/agent/.oh-my-zsh/custom/plugins/zsh-autosuggestions ]; then
-    git clone https://github.com/zsh-users/zsh-autosuggestions /home/agent/.oh-my-zsh/custom/plugins/zsh-autosuggestions 2>/dev/null || true
+    git clone https://github.com/zsh-users/zsh-autosuggestions /home/agent/.oh-my-zsh/custom/plugins/zsh-autosuggestions || agentbox_failed "zsh-autosuggestions"
 fi
 if [ ! -d /home/agent/.oh-my-zsh/custom/plugins/zsh-syntax-highlighting ]; then
-    git clone https://github.com/zsh-users/zsh-syntax-highlighting /home/agent/.oh-my-zsh/custom/plugins/zsh-syntax-highlighting 2>/dev/null || true
+    git clone https://github.com/zsh-users/zsh-syntax-highlighting /home/agent/.oh-my-zsh/custom/plugins/zsh-syntax-highlighting || agentbox_failed "zsh-syntax-highlighting"
 fi
 chown -R agent:agent /home/agent/.oh-my-zsh 2>/dev/null || true
 
@@ -405,6 +508,7 @@ ZSHRC
 chown agent:agent /home/agent/.zshrc
 
 # --- Secure secrets setup (runs for both pre-built and stock) ---
+agentbox_step "secure secrets"
 # Create secure claude wrapper if not already present
 if [ ! -f /usr/bin/claude-real ]; then
     CLAUDE_PATH=$(which claude 2>/dev/null || true)
@@ -421,14 +525,23 @@ WRAPPER
         chmod +x /usr/local/bin/claude
     fi
 fi
+# Secrets live on a root-only tmpfs so they never touch disk and are gone when the VM stops
 mkdir -p /etc/agentbox/secrets
+if ! grep -q ' /etc/agentbox/secrets ' /etc/fstab; then
+    echo 'tmpfs /etc/agentbox/secrets tmpfs size=1m,mode=0700,uid=0,gid=0,nodev,nosuid,noexec 0 0' >> /etc/fstab
+fi
+mountpoint -q /etc/agentbox/secrets || mount /etc/agentbox/secrets
 chmod 700 /etc/agentbox/secrets
 cat > /etc/sudoers.d/agentbox-secrets << 'SUDOERS'
 agent ALL=(root) NOPASSWD: /bin/cat /etc/agentbox/secrets/*
 SUDOERS
 chmod 0440 /etc/sudoers.d/agentbox-secrets
 
-# --- Mark Ready ---
+`
+
+// provisionFooter marks the guest ready
+const provisionFooter = `# --- Mark Ready ---
+agentbox_step_end
 touch /etc/agentbox-ready
 echo ""
 echo "=========================================="
@@ -439,7 +552,38 @@ else
     echo "(Full install from stock Ubuntu)"
 fi
 echo "=========================================="
-`, cfg.Network.ProxyPort)
+`
+
+// generateAWSCredentialsScript configures dummy AWS credentials for the agent
+// The proxy strips the dummy signature and re-signs with host credentials
+func generateAWSCredentialsScript(cfg *config.Config) string {
+	signing := cfg.Network.AWSSigning
+	if signing == nil || len(signing.Services) == 0 {
+		return ""
+	}
+
+	region := "us-east-1"
+	if len(signing.Regions) > 0 {
+		region = signing.Regions[0]
+	}
+
+	return fmt.Sprintf(`
+# --- AWS (proxy-signed) ---
+agentbox_step "aws credentials"
+# Dummy credentials only - the proxy re-signs requests with host credentials
+mkdir -p /home/agent/.aws
+cat > /home/agent/.aws/credentials << 'AWSCREDS'
+[default]
+aws_access_key_id = AKIAAGENTBOXPROXY000
+aws_secret_access_key = agentbox-proxy-signed-dummy-secret
+AWSCREDS
+cat > /home/agent/.aws/config << 'AWSCONFIG'
+[default]
+region = %s
+AWSCONFIG
+chown -R agent:agent /home/agent/.aws
+chmod 600 /home/agent/.aws/credentials
+`, region)
 }
 
 // generateProvisionScriptGasTown creates a provision script for Gas Town rigs
@@ -451,6 +595,7 @@ func generateProvisionScriptGasTown(cfg *config.Config, rigName string, repoURL
 	// Add Gas Town rig creation during provisioning
 	gasTownSetup := fmt.Sprintf(`
 # --- Gas Town Rig Setup ---
+agentbox_step "gastown rig"
 echo "Setting up Gas Town rig..."
 RIG_NAME="%s"
 REPO_URL="%s"
@@ -468,6 +613,7 @@ fi
 GT_BIN="/home/agent/go/bin/gt"
 if [ ! -x "$GT_BIN" ]; then
     echo "ERROR: gt not found at $GT_BIN"
+    agentbox_failed "gt not installed"
     echo "Gas Town rig setup skipped - install gt and run: gt install ~/gt && gt rig add $RIG_NAME $REPO_URL"
 else
     # First: Initialize Gas Town HQ (workspace)
@@ -483,6 +629,7 @@ else
         echo "Gas Town HQ initialized!"
     } || {
         echo "Warning: gt install failed"
+        agentbox_failed "gt install"
     }
 
     # Second: Add the rig to the workspace
@@ -499,6 +646,7 @@ else
         echo "Gas Town rig '$RIG_NAME' created successfully!"
     } || {
         echo "Warning: gt rig add failed"
+        agentbox_failed "gt rig add"
         echo "You can manually run: cd ~/gt && gt rig add $RIG_NAME $REPO_URL"
     }
 fi
@@ -8,6 +8,7 @@ import (
 )
 
 func TestGenerateTemplate(t *testing.T) {
+	setHost(t, "darwin", "arm64")
 	cfg := config.DefaultConfig()
 	projectDir := "/tmp/testproject"
 
@@ -0,0 +1,201 @@
+package lima
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// Version formats accepted in toolchains and agents
+var (
+	goVersionPattern     = regexp.MustCompile(`^1\.[0-9]+\.[0-9]+$`)
+	nodeVersionPattern   = regexp.MustCompile(`^[0-9]+(\.[0-9]+\.[0-9]+)?$`)
+	pythonVersionPattern = regexp.MustCompile(`^3(\.[0-9]+){1,2}$`)
+	rustVersionPattern   = regexp.MustCompile(`^(stable|beta|nightly|1\.[0-9]+(\.[0-9]+)?)$`)
+	npmVersionPattern    = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+([-+][0-9A-Za-z.-]+)?$`)
+	moduleVersionPattern = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+([-+][0-9A-Za-z.-]+)?$`)
+)
+
+// ValidateToolchains checks the toolchains and agents sections
+// Versions are pinned, so ranges and "latest" are refused
+func ValidateToolchains(cfg *config.Config) error {
+	checks := []struct {
+		key     string
+		version string
+		pattern *regexp.Regexp
+		example string
+	}{
+		{"toolchains.go", cfg.Toolchains.Go, goVersionPattern, "1.23.4"},
+		{"toolchains.node", cfg.Toolchains.Node, nodeVersionPattern, "22 or 22.11.0"},
+		{"toolchains.python", cfg.Toolchains.Python, pythonVersionPattern, "3.12"},
+		{"toolchains.rust", cfg.Toolchains.Rust, rustVersionPattern, "stable or 1.82.0"},
+		{"agents.claude_code", cfg.Agents.ClaudeCode, npmVersionPattern, "2.0.14"},
+		{"agents.opencode", cfg.Agents.Opencode, moduleVersionPattern, "v0.0.55"},
+		{"agents.gastown", cfg.Agents.Gastown, moduleVersionPattern, "v0.2.0"},
+		{"agents.beads", cfg.Agents.Beads, moduleVersionPattern, "v0.20.1"},
+	}
+	for _, c := range checks {
+		if c.version != "" && !c.pattern.MatchString(c.version) {
+			return fmt.Errorf("invalid %s %q (expected a version like %s)", c.key, c.version, c.example)
+		}
+	}
+	return nil
+}
+
+// generateToolchainScript installs the pinned toolchains
+// Go and Node are system-wide so provisioning can use them; Python and Rust
+// go through mise for the agent user. Each is skipped if already at the
+// pinned version, which also upgrades pre-built images that are out of date.
+// Each toolchain is a step in the step log; a failed install stops provisioning.
+func generateToolchainScript(t config.ToolchainsConfig) string {
+	var b strings.Builder
+	b.WriteString(`
+# --- Toolchains (runs for both pre-built and stock) ---
+agentbox_section toolchains
+ARCH=$(dpkg --print-architecture)
+`)
+
+	if t.Go != "" {
+		fmt.Fprintf(&b, `
+GO_VERSION="%s"
+agentbox_step "go ${GO_VERSION}"
+if [ "$(/usr/local/go/bin/go env GOVERSION 2>/dev/null || true)" != "go${GO_VERSION}" ]; then
+    echo "Installing Go ${GO_VERSION}..."
+    GO_TMP=$(mktemp -d)
+    GO_FILE="go${GO_VERSION}.linux-${ARCH}.tar.gz"
+    curl -fsSL "https://go.dev/dl/${GO_FILE}" -o "$GO_TMP/$GO_FILE"
+    echo "$(curl -fsSL "https://go.dev/dl/${GO_FILE}.sha256")  $GO_TMP/$GO_FILE" | sha256sum -c -
+    rm -rf /usr/local/go
+    tar -C /usr/local -xzf "$GO_TMP/$GO_FILE"
+    rm -rf "$GO_TMP"
+    agentbox_changed "go ${GO_VERSION}"
+fi
+echo 'export PATH=$PATH:/usr/local/go/bin' > /etc/profile.d/go.sh
+`, t.Go)
+	}
+
+	if t.Node != "" {
+		// A bare major resolves to its newest release through latest-vN.x
+		fmt.Fprintf(&b, `
+NODE_VERSION="%s"
+agentbox_step "node ${NODE_VERSION}"
+NODE_CURRENT=$(/usr/local/bin/node --version 2>/dev/null || true)
+if [[ "$NODE_CURRENT" != "v${NODE_VERSION}" && "$NODE_CURRENT" != "v${NODE_VERSION}".* ]]; then
+    echo "Installing Node.js ${NODE_VERSION}..."
+    case "$ARCH" in
+        amd64) NODE_ARCH=x64 ;;
+        *) NODE_ARCH="$ARCH" ;;
+    esac
+    NODE_DIST="v${NODE_VERSION}"
+    [[ "$NODE_VERSION" == *.* ]] || NODE_DIST="latest-v${NODE_VERSION}.x"
+    NODE_TMP=$(mktemp -d)
+    curl -fsSL "https://nodejs.org/dist/${NODE_DIST}/SHASUMS256.txt" -o "$NODE_TMP/SHASUMS256.txt"
+    NODE_FILE=$(awk -v suffix="-linux-${NODE_ARCH}.tar.xz" 'substr($2, length($2) - length(suffix) + 1) == suffix {print $2; exit}' "$NODE_TMP/SHASUMS256.txt")
+    curl -fsSL "https://nodejs.org/dist/${NODE_DIST}/${NODE_FILE}" -o "$NODE_TMP/$NODE_FILE"
+    (cd "$NODE_TMP" && grep "  ${NODE_FILE}\$" SHASUMS256.txt | sha256sum -c -)
+    tar -C /usr/local --strip-components=1 -xJf "$NODE_TMP/$NODE_FILE"
+    rm -rf "$NODE_TMP" /usr/local/CHANGELOG.md /usr/local/README.md /usr/local/LICENSE
+    agentbox_changed "node $(/usr/local/bin/node --version)"
+fi
+`, t.Node)
+	}
+
+	var mise []string
+	if t.Python != "" {
+		mise = append(mise, "python@"+t.Python)
+	}
+	if t.Rust != "" {
+		mise = append(mise, "rust@"+t.Rust)
+	}
+	if len(mise) > 0 {
+		fmt.Fprintf(&b, `
+agentbox_step "mise %[1]s"
+if ! command -v mise >/dev/null 2>&1; then
+    curl -fsSL https://mise.run | HOME=/root sh
+    mv /root/.local/bin/mise /usr/local/bin/mise
+fi
+MISE_NEW=""
+for tool in %[1]s; do
+    sudo -u agent -H mise where "$tool" >/dev/null 2>&1 || MISE_NEW="$MISE_NEW $tool"
+done
+echo "Installing %[1]s with mise..."
+sudo -u agent -H mise use --global --yes %[1]s
+for tool in $MISE_NEW; do
+    agentbox_changed "$tool"
+done
+`, strings.Join(mise, " "))
+	}
+
+	return b.String()
+}
+
+// generateAgentsScript installs the pinned agent CLIs
+// claude-code goes under /usr so the secure wrapper in /usr/local/bin stays
+// in front of it; the Go CLIs are built into the agent's GOPATH. Each CLI is a
+// step in the step log, and a failed install is recorded without stopping.
+func generateAgentsScript(a config.AgentsConfig) string {
+	var b strings.Builder
+	b.WriteString(`
+# --- Agent CLIs (runs for both pre-built and stock) ---
+agentbox_section agents
+# go_tool_version prints the module version an agent's Go binary was built from
+go_tool_version() {
+    /usr/local/go/bin/go version -m "/home/agent/go/bin/$1" 2>/dev/null | awk '$1 == "mod" {print $3; exit}'
+}
+# install_go_tool <binary> <package> <version>
+install_go_tool() {
+    agentbox_step "$1 $3"
+    if [ "$(go_tool_version "$1")" != "$3" ]; then
+        echo "Installing $1 $3..."
+        if sudo -u agent -H bash -c 'export PATH="/usr/local/go/bin:$PATH" GOPATH="$HOME/go" CGO_ENABLED=1; go install "$1"' _ "$2@$3"; then
+            agentbox_changed "$1 $3"
+        else
+            echo "Warning: $1 installation failed"
+            agentbox_failed "$1 $3"
+        fi
+    fi
+}
+`)
+
+	if a.ClaudeCode != "" {
+		fmt.Fprintf(&b, `
+CLAUDE_VERSION="%s"
+agentbox_step "claude-code ${CLAUDE_VERSION}"
+if ! npm ls -g --prefix /usr --depth=0 2>/dev/null | grep -q "@anthropic-ai/claude-code@${CLAUDE_VERSION}$"; then
+    echo "Installing claude-code ${CLAUDE_VERSION}..."
+    if npm install -g --prefix /usr "@anthropic-ai/claude-code@${CLAUDE_VERSION}"; then
+        # An existing secure wrapper keeps fronting the new binary
+        if [ -e /usr/bin/claude-real ] && [ -e /usr/bin/claude ]; then
+            mv -f /usr/bin/claude /usr/bin/claude-real
+        fi
+        agentbox_changed "claude-code ${CLAUDE_VERSION}"
+    else
+        echo "Warning: claude-code installation failed"
+        agentbox_failed "claude-code ${CLAUDE_VERSION}"
+    fi
+fi
+`, a.ClaudeCode)
+	}
+
+	if a.Opencode != "" {
+		fmt.Fprintf(&b, "install_go_tool opencode github.com/opencode-ai/opencode %s\n", a.Opencode)
+	}
+	if a.Beads != "" {
+		// bd needs libicu-dev for go-icu-regex
+		b.WriteString(`agentbox_step "bd dependencies"
+if ! dpkg-query -W -f='${Status}' libicu-dev 2>/dev/null | grep -q "install ok installed"; then
+    apt-get update -qq && apt-get install -y -qq libicu-dev pkg-config || agentbox_failed "libicu-dev"
+fi
+`)
+	}
+	if a.Gastown != "" {
+		fmt.Fprintf(&b, "install_go_tool gt github.com/steveyegge/gastown/cmd/gt %s\n", a.Gastown)
+	}
+	if a.Beads != "" {
+		fmt.Fprintf(&b, "install_go_tool bd github.com/steveyegge/beads/cmd/bd %s\n", a.Beads)
+	}
+
+	return b.String()
+}
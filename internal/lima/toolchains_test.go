@@ -0,0 +1,124 @@
+package lima
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestValidateToolchains(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*config.Config)
+		wantErr string
+	}{
+		{name: "defaults", modify: func(*config.Config) {}},
+		{
+			name: "all toolchains",
+			modify: func(c *config.Config) {
+				c.Toolchains = config.ToolchainsConfig{Go: "1.22.10", Node: "20.11.1", Python: "3.12", Rust: "1.82.0"}
+			},
+		},
+		{
+			name:   "empty skips",
+			modify: func(c *config.Config) { c.Toolchains = config.ToolchainsConfig{}; c.Agents = config.AgentsConfig{} },
+		},
+		{
+			name:    "go needs a full release",
+			modify:  func(c *config.Config) { c.Toolchains.Go = "1.23" },
+			wantErr: "invalid toolchains.go",
+		},
+		{
+			name:    "node lts alias",
+			modify:  func(c *config.Config) { c.Toolchains.Node = "lts" },
+			wantErr: "invalid toolchains.node",
+		},
+		{
+			name:    "rust shell",
+			modify:  func(c *config.Config) { c.Toolchains.Rust = "stable; curl evil" },
+			wantErr: "invalid toolchains.rust",
+		},
+		{
+			name:    "unpinned agent",
+			modify:  func(c *config.Config) { c.Agents.ClaudeCode = "latest" },
+			wantErr: "invalid agents.claude_code",
+		},
+		{
+			name:    "go module version needs a v",
+			modify:  func(c *config.Config) { c.Agents.Beads = "0.20.1" },
+			wantErr: "invalid agents.beads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			tt.modify(cfg)
+			err := ValidateToolchains(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGenerateTemplateToolchains(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Toolchains = config.ToolchainsConfig{Go: "1.23.4", Node: "20", Python: "3.12", Rust: "stable"}
+	cfg.Agents.ClaudeCode = "2.0.14"
+	cfg.Agents.Gastown = "v0.2.0"
+
+	tmpl, err := GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+
+	for _, want := range []string{
+		`GO_VERSION="1.23.4"`,
+		`NODE_VERSION="20"`,
+		"mise use --global --yes python@3.12 rust@stable",
+		`CLAUDE_VERSION="2.0.14"`,
+		"install_go_tool gt github.com/steveyegge/gastown/cmd/gt v0.2.0",
+	} {
+		if !strings.Contains(tmpl, want) {
+			t.Errorf("template missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"@latest", "node_22.x", `GO_VERSION="1.22.0"`} {
+		if strings.Contains(tmpl, unwanted) {
+			t.Errorf("template should not contain %q", unwanted)
+		}
+	}
+}
+
+func TestGenerateTemplateSkipsUnsetToolchains(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Toolchains = config.ToolchainsConfig{}
+	cfg.Agents = config.AgentsConfig{}
+
+	tmpl, err := GenerateTemplate(cfg, "/tmp/testproject")
+	if err != nil {
+		t.Fatalf("failed to generate template: %v", err)
+	}
+	for _, unwanted := range []string{"GO_VERSION", "NODE_VERSION", "mise use", "CLAUDE_VERSION", "install_go_tool gt"} {
+		if strings.Contains(tmpl, unwanted) {
+			t.Errorf("template should not contain %q", unwanted)
+		}
+	}
+}
+
+func TestGenerateTemplateInvalidToolchain(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Toolchains.Node = "$(reboot)"
+
+	if _, err := GenerateTemplate(cfg, "/tmp/testproject"); err == nil {
+		t.Error("expected invalid toolchain to be rejected")
+	}
+}
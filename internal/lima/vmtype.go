@@ -0,0 +1,96 @@
+package lima
+
+import (
+	"fmt"
+	goruntime "runtime"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// Lima VM types
+const (
+	VMTypeVZ   = "vz"
+	VMTypeQEMU = "qemu"
+)
+
+// Lima architecture names
+const (
+	ArchAArch64 = "aarch64"
+	ArchX8664   = "x86_64"
+)
+
+// Host platform, overridden in tests
+var (
+	hostOS   = goruntime.GOOS
+	hostArch = goruntime.GOARCH
+)
+
+// VMSettings is the VM type, arch and Rosetta setting a template is built with
+type VMSettings struct {
+	Type    string
+	Arch    string
+	Rosetta bool
+}
+
+// ResolveVMSettings fills in auto values for this host and rejects
+// combinations Lima can't run: vz needs macOS and the host's arch, and
+// Rosetta needs vz on Apple Silicon with an aarch64 guest
+func ResolveVMSettings(vm config.VMConfig) (VMSettings, error) {
+	var s VMSettings
+
+	native := normalizeArch(hostArch)
+	switch vm.Arch {
+	case "", "auto":
+		s.Arch = native
+	default:
+		s.Arch = normalizeArch(vm.Arch)
+		if s.Arch != ArchAArch64 && s.Arch != ArchX8664 {
+			return s, fmt.Errorf("invalid vm.arch %q (use aarch64, x86_64, or auto)", vm.Arch)
+		}
+	}
+
+	switch vm.Type {
+	case "", "auto":
+		// vz is faster but only runs native guests on macOS
+		if hostOS == "darwin" && s.Arch == native {
+			s.Type = VMTypeVZ
+		} else {
+			s.Type = VMTypeQEMU
+		}
+	case VMTypeVZ:
+		if hostOS != "darwin" {
+			return s, fmt.Errorf("vm.type vz requires a macOS host (use qemu on %s)", hostOS)
+		}
+		if s.Arch != native {
+			return s, fmt.Errorf("vm.type vz cannot emulate %s on a %s host (use qemu)", s.Arch, native)
+		}
+		s.Type = VMTypeVZ
+	case VMTypeQEMU:
+		s.Type = VMTypeQEMU
+	default:
+		return s, fmt.Errorf("invalid vm.type %q (use vz, qemu, or auto)", vm.Type)
+	}
+
+	rosettaOK := s.Type == VMTypeVZ && native == ArchAArch64 && s.Arch == ArchAArch64
+	switch {
+	case vm.Rosetta == nil:
+		s.Rosetta = rosettaOK
+	case *vm.Rosetta && !rosettaOK:
+		return s, fmt.Errorf("vm.rosetta needs vm.type vz with an aarch64 guest on Apple Silicon")
+	default:
+		s.Rosetta = *vm.Rosetta
+	}
+
+	return s, nil
+}
+
+// normalizeArch maps Go and Docker arch names to Lima's
+func normalizeArch(arch string) string {
+	switch arch {
+	case "arm64", "aarch64":
+		return ArchAArch64
+	case "amd64", "x86_64":
+		return ArchX8664
+	}
+	return arch
+}
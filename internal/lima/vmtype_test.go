@@ -0,0 +1,137 @@
+package lima
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// setHost pretends agentbox runs on the given platform
+func setHost(t *testing.T, goos, goarch string) {
+	t.Helper()
+	origOS, origArch := hostOS, hostArch
+	hostOS, hostArch = goos, goarch
+	t.Cleanup(func() { hostOS, hostArch = origOS, origArch })
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestResolveVMSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		goos    string
+		goarch  string
+		vm      config.VMConfig
+		want    VMSettings
+		wantErr bool
+	}{
+		{"mac arm auto", "darwin", "arm64", config.VMConfig{}, VMSettings{VMTypeVZ, ArchAArch64, true}, false},
+		{"mac intel auto", "darwin", "amd64", config.VMConfig{}, VMSettings{VMTypeVZ, ArchX8664, false}, false},
+		{"mac arm rosetta off", "darwin", "arm64", config.VMConfig{Rosetta: boolPtr(false)}, VMSettings{VMTypeVZ, ArchAArch64, false}, false},
+		{"mac arm qemu", "darwin", "arm64", config.VMConfig{Type: "qemu"}, VMSettings{VMTypeQEMU, ArchAArch64, false}, false},
+		{"mac arm x86 guest", "darwin", "arm64", config.VMConfig{Arch: "x86_64"}, VMSettings{VMTypeQEMU, ArchX8664, false}, false},
+		{"linux auto", "linux", "amd64", config.VMConfig{}, VMSettings{VMTypeQEMU, ArchX8664, false}, false},
+		{"linux arm auto", "linux", "arm64", config.VMConfig{Type: "auto", Arch: "auto"}, VMSettings{VMTypeQEMU, ArchAArch64, false}, false},
+		{"linux arch alias", "linux", "amd64", config.VMConfig{Arch: "arm64"}, VMSettings{VMTypeQEMU, ArchAArch64, false}, false},
+		{"linux vz", "linux", "amd64", config.VMConfig{Type: "vz"}, VMSettings{}, true},
+		{"vz foreign arch", "darwin", "arm64", config.VMConfig{Type: "vz", Arch: "x86_64"}, VMSettings{}, true},
+		{"rosetta on qemu", "darwin", "arm64", config.VMConfig{Type: "qemu", Rosetta: boolPtr(true)}, VMSettings{}, true},
+		{"rosetta on intel", "darwin", "amd64", config.VMConfig{Rosetta: boolPtr(true)}, VMSettings{}, true},
+		{"bad type", "linux", "amd64", config.VMConfig{Type: "hyperv"}, VMSettings{}, true},
+		{"bad arch", "linux", "amd64", config.VMConfig{Arch: "riscv64"}, VMSettings{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setHost(t, tt.goos, tt.goarch)
+			got, err := ResolveVMSettings(tt.vm)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateTemplateVMType(t *testing.T) {
+	tests := []struct {
+		name    string
+		goos    string
+		goarch  string
+		vm      config.VMConfig
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "vz with rosetta",
+			goos:    "darwin",
+			goarch:  "arm64",
+			present: []string{`vmType: "vz"`, `arch: "aarch64"`, "rosetta:", "binfmt: true"},
+		},
+		{
+			name:    "vz without rosetta",
+			goos:    "darwin",
+			goarch:  "arm64",
+			vm:      config.VMConfig{Rosetta: boolPtr(false)},
+			present: []string{`vmType: "vz"`},
+			absent:  []string{"vmOpts:", "rosetta"},
+		},
+		{
+			name:    "qemu on linux",
+			goos:    "linux",
+			goarch:  "amd64",
+			present: []string{`vmType: "qemu"`, `arch: "x86_64"`},
+			absent:  []string{"vmOpts:", "rosetta", `"vz"`},
+		},
+		{
+			name:    "qemu emulating x86 on mac",
+			goos:    "darwin",
+			goarch:  "arm64",
+			vm:      config.VMConfig{Arch: "x86_64"},
+			present: []string{`vmType: "qemu"`, `arch: "x86_64"`},
+			absent:  []string{"rosetta"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setHost(t, tt.goos, tt.goarch)
+			cfg := config.DefaultConfig()
+			cfg.VM.Type, cfg.VM.Arch, cfg.VM.Rosetta = tt.vm.Type, tt.vm.Arch, tt.vm.Rosetta
+
+			tmpl, err := GenerateTemplate(cfg, "/tmp/testproject")
+			if err != nil {
+				t.Fatalf("failed to generate template: %v", err)
+			}
+			for _, want := range tt.present {
+				if !strings.Contains(tmpl, want) {
+					t.Errorf("template missing %q", want)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(tmpl, unwanted) {
+					t.Errorf("template should not contain %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateTemplateInvalidVMType(t *testing.T) {
+	setHost(t, "linux", "amd64")
+	cfg := config.DefaultConfig()
+	cfg.VM.Type = "vz"
+
+	if _, err := GenerateTemplate(cfg, "/tmp/testproject"); err == nil {
+		t.Error("expected an error for vz on a Linux host")
+	}
+}
@@ -13,6 +13,8 @@ import (
 type AuthInjector struct {
 	mu      sync.RWMutex
 	configs map[string]authEntry // hostname -> auth config
+	sources []config.AuthConfig
+	lookup  func(ref string) string
 }
 
 type authEntry struct {
@@ -21,23 +23,37 @@ type authEntry struct {
 }
 
 // NewAuthInjector creates a new auth injector from config
-func NewAuthInjector(configs []config.AuthConfig) *AuthInjector {
+// lookup resolves each Env (a var name or secret://name); nil reads the
+// host environment
+func NewAuthInjector(configs []config.AuthConfig, lookup func(ref string) string) *AuthInjector {
+	if lookup == nil {
+		lookup = os.Getenv
+	}
 	a := &AuthInjector{
-		configs: make(map[string]authEntry),
+		sources: configs,
+		lookup:  lookup,
 	}
+	a.Reload()
+	return a
+}
 
-	for _, cfg := range configs {
+// Reload looks up every configured value again (e.g., after a rotation)
+func (a *AuthInjector) Reload() {
+	configs := make(map[string]authEntry)
+	for _, cfg := range a.sources {
 		host := strings.ToLower(cfg.Host)
-		value := os.Getenv(cfg.Env)
+		value := a.lookup(cfg.Env)
 		if value != "" {
-			a.configs[host] = authEntry{
+			configs[host] = authEntry{
 				header: cfg.Header,
 				value:  value,
 			}
 		}
 	}
 
-	return a
+	a.mu.Lock()
+	a.configs = configs
+	a.mu.Unlock()
 }
 
 // NeedsInjection checks if a host requires auth injection
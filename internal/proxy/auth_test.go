@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestAuthInjectorLookupAndReload(t *testing.T) {
+	values := map[string]string{"secret://openai": "sk-first"}
+	lookup := func(ref string) string { return values[ref] }
+
+	auth := NewAuthInjector([]config.AuthConfig{
+		{Host: "API.OpenAI.com", Header: "Authorization", Env: "secret://openai"},
+		{Host: "api.github.com", Header: "Authorization", Env: "secret://github"},
+	}, lookup)
+
+	if !auth.NeedsInjection("api.openai.com") {
+		t.Error("expected injection for api.openai.com")
+	}
+	if auth.NeedsInjection("api.github.com") {
+		t.Error("unresolved secret should not be injected")
+	}
+
+	values["secret://openai"] = "sk-rotated"
+	values["secret://github"] = "ghp-token"
+	auth.Reload()
+
+	h := http.Header{}
+	if !auth.Inject("api.openai.com", h) || h.Get("Authorization") != "sk-rotated" {
+		t.Errorf("expected rotated value after reload, got %q", h.Get("Authorization"))
+	}
+	if !auth.NeedsInjection("api.github.com") {
+		t.Error("expected injection for api.github.com after reload")
+	}
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// Bridge accepts connections on ln and pipes each one to a connection from
+// dial until ctx is done. The bwrap runtime runs one on each side of the
+// sandbox's network namespace: in the sandbox a loopback port forwards to a
+// bind-mounted unix socket, and on the host that socket forwards to the proxy.
+func Bridge(ctx context.Context, ln net.Listener, dial func() (net.Conn, error)) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			upstream, err := dial()
+			if err != nil {
+				return
+			}
+			defer upstream.Close()
+			pipe(conn, upstream)
+		}()
+	}
+}
+
+// pipe copies between a and b in both directions until both sides are done
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// Pass the EOF on so request/response protocols can finish
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestBridge(t *testing.T) {
+	// Upstream echoes one message back
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		conn, err := upstream.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	sock := filepath.Join(t.TempDir(), "proxy.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Bridge(ctx, ln, func() (net.Conn, error) {
+			return net.Dial("tcp", upstream.Addr().String())
+		})
+	}()
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("CONNECT example.com:443")); err != nil {
+		t.Fatal(err)
+	}
+	conn.(*net.UnixConn).CloseWrite()
+
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "CONNECT example.com:443" {
+		t.Errorf("bridge returned %q", got)
+	}
+	conn.Close()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Bridge returned %v after cancel", err)
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCA(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ca")
+	ca, err := LoadCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, caKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("CA key should be 0600: %v %v", info, err)
+	}
+
+	again, err := LoadCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ca.CertPEM(), again.CertPEM()) {
+		t.Error("LoadCA should reuse the CA in dir")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(again.CertPEM())
+	for _, host := range []string{"api.anthropic.com", "127.0.0.1"} {
+		cert, err := again.Certificate(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("certificate for %s does not verify: %v", host, err)
+		}
+	}
+}
+
+func TestLoadCARejectsMismatchedKey(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	if _, err := LoadCA(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCA(b); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := os.ReadFile(filepath.Join(b, caKeyFile))
+	os.WriteFile(filepath.Join(a, caKeyFile), key, 0600)
+
+	if _, err := LoadCA(a); err == nil {
+		t.Error("expected an error for a key that doesn't match the certificate")
+	}
+}
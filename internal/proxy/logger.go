@@ -14,6 +14,14 @@ type Logger struct {
 	mu       sync.Mutex
 	file     *os.File
 	redactor *secrets.Redactor
+	actions  map[string]int
+	hosts    map[string]int
+}
+
+// Summary counts the entries a Logger wrote
+type Summary struct {
+	Actions map[string]int `json:"actions"` // By action (PASS, AUTH, DENY, ...)
+	Hosts   map[string]int `json:"hosts"`   // By host, redacted like the log
 }
 
 // NewLogger creates a new network logger
@@ -22,7 +30,30 @@ func NewLogger(path string, redactor *secrets.Redactor) (*Logger, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Logger{file: f, redactor: redactor}, nil
+	return &Logger{
+		file:     f,
+		redactor: redactor,
+		actions:  make(map[string]int),
+		hosts:    make(map[string]int),
+	}, nil
+}
+
+// Summary returns the counts of what was logged since the logger was created
+func (l *Logger) Summary() Summary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := Summary{
+		Actions: make(map[string]int, len(l.actions)),
+		Hosts:   make(map[string]int, len(l.hosts)),
+	}
+	for action, n := range l.actions {
+		s.Actions[action] = n
+	}
+	for host, n := range l.hosts {
+		s.Hosts[host] = n
+	}
+	return s
 }
 
 // Close closes the log file
@@ -40,6 +71,21 @@ func (l *Logger) LogAuthInjected(host, client string) {
 	l.log("AUTH", host, client, "credentials injected")
 }
 
+// LogSigned logs when an AWS request was re-signed with host credentials
+func (l *Logger) LogSigned(host, client string) {
+	l.log("SIGN", host, client, "request re-signed with host credentials")
+}
+
+// LogDenied logs when a request was refused by policy
+func (l *Logger) LogDenied(host, client, reason string) {
+	l.log("DENY", host, client, reason)
+}
+
+// LogExfiltration logs a placeholder secret sent to a host not allowed to receive it
+func (l *Logger) LogExfiltration(host, client, secretName string) {
+	l.log("EXFIL", host, client, "placeholder for "+secretName+" sent to unauthorized host")
+}
+
 // LogAuthSkipped logs when auth injection was skipped
 func (l *Logger) LogAuthSkipped(host, reason string) {
 	l.log("SKIP", host, "", reason)
@@ -54,6 +100,11 @@ func (l *Logger) log(action, host, client, detail string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.actions[action]++
+	if host != "" {
+		l.hosts[l.redactor.Redact(host)]++
+	}
+
 	timestamp := time.Now().UTC().Format(time.RFC3339)
 
 	var msg string
@@ -64,10 +115,9 @@ func (l *Logger) log(action, host, client, detail string) {
 	}
 
 	if detail != "" {
-		// Redact any secrets from messages
-		detail = l.redactor.Redact(detail)
 		msg += fmt.Sprintf(" detail=%q", detail)
 	}
 
-	fmt.Fprintln(l.file, msg)
+	// Redact any secrets from the whole line - hosts and URLs can carry them too
+	fmt.Fprintln(l.file, l.redactor.Redact(msg))
 }
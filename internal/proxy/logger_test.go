@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/secrets"
+)
+
+func TestLoggerSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "network.log")
+	l, err := NewLogger(path, secrets.NewRedactor([]string{`sk-[a-z0-9]+`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.LogPass("github.com:443", "127.0.0.1:5000")
+	l.LogPass("github.com:443", "127.0.0.1:5001")
+	l.LogAuthInjected("api.anthropic.com:443", "127.0.0.1:5002")
+	l.LogDenied("sk-abc123.example.com:443", "127.0.0.1:5003", "not allowed")
+
+	s := l.Summary()
+	if s.Actions["PASS"] != 2 || s.Actions["AUTH"] != 1 || s.Actions["DENY"] != 1 {
+		t.Errorf("actions = %v", s.Actions)
+	}
+	if s.Hosts["github.com:443"] != 2 || s.Hosts["api.anthropic.com:443"] != 1 {
+		t.Errorf("hosts = %v", s.Hosts)
+	}
+	for host := range s.Hosts {
+		if strings.Contains(host, "sk-abc123") {
+			t.Errorf("summary host %q is not redacted", host)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 4 {
+		t.Errorf("log has %d lines, want 4", n)
+	}
+}
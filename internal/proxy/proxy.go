@@ -2,34 +2,47 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/secrets"
 )
 
 // Proxy is an HTTP/HTTPS forward proxy with auth injection
 type Proxy struct {
 	authInjector *AuthInjector
+	awsSigner    *AWSSigner
+	surrogates   *SurrogateSwapper
 	logger       *Logger
+	ca           *CA
+	transport    http.RoundTripper
 	server       *http.Server
 	port         int
 }
 
-// New creates a new proxy server
-func New(port int, authConfigs []config.AuthConfig, logger *Logger) *Proxy {
+// New creates a new proxy server from the box's network config
+// surrogates are the session's placeholder secrets handed to the guest;
+// lookup resolves configured secrets (nil reads the host environment)
+func New(cfg config.NetworkConfig, surrogates []*secrets.Surrogate, lookup func(ref string) string, logger *Logger) *Proxy {
 	p := &Proxy{
-		authInjector: NewAuthInjector(authConfigs),
+		authInjector: NewAuthInjector(cfg.InjectAuth, lookup),
+		awsSigner:    NewAWSSigner(cfg.AWSSigning, lookup),
+		surrogates:   NewSurrogateSwapper(surrogates),
 		logger:       logger,
-		port:         port,
+		transport:    http.DefaultTransport,
+		port:         cfg.ProxyPort,
 	}
 
 	p.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         fmt.Sprintf(":%d", cfg.ProxyPort),
 		Handler:      p,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
@@ -38,6 +51,21 @@ func New(port int, authConfigs []config.AuthConfig, logger *Logger) *Proxy {
 	return p
 }
 
+// SetCA enables TLS interception for hosts the proxy injects auth into,
+// re-signs or swaps placeholders for; the guest must trust ca. Without a CA
+// those hosts are tunneled untouched.
+func (p *Proxy) SetCA(ca *CA) {
+	p.ca = ca
+}
+
+// Reload picks up rotated secrets without restarting the proxy
+// Injected headers are looked up again; surrogateValues maps surrogate
+// names to their new real values. AWS credentials are read per request.
+func (p *Proxy) Reload(surrogateValues map[string]string) {
+	p.authInjector.Reload()
+	p.surrogates.Update(surrogateValues)
+}
+
 // Start starts the proxy server
 func (p *Proxy) Start(ctx context.Context) error {
 	ln, err := net.Listen("tcp", p.server.Addr)
@@ -74,8 +102,8 @@ func (p *Proxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 		hostname = host
 	}
 
-	// Check if we need to inject auth for this host
-	if p.authInjector.NeedsInjection(hostname) {
+	// Check if we need to inject auth or re-sign requests for this host
+	if p.authInjector.NeedsInjection(hostname) || p.awsSigner.Matches(hostname) || p.surrogates.NeedsInjection(hostname) {
 		// MITM: terminate TLS and inject auth
 		p.handleConnectMITM(w, r, hostname)
 		return
@@ -123,13 +151,83 @@ func (p *Proxy) handleConnect(w http.ResponseWriter, r *http.Request) {
 	wg.Wait()
 }
 
-// handleConnectMITM handles HTTPS for hosts that need auth injection
-// Currently falls back to passthrough - HTTPS auth injection requires CA setup
+// handleConnectMITM terminates TLS for hosts that need auth injection,
+// re-signing or placeholder swaps, presenting a certificate from the box's
+// CA, and handles each request inside like a plain HTTP one
 func (p *Proxy) handleConnectMITM(w http.ResponseWriter, r *http.Request, hostname string) {
-	// Log that we can't inject auth for HTTPS without CA
-	p.logger.LogAuthSkipped(hostname, "HTTPS requires CA for auth injection - passing through")
+	if p.ca == nil {
+		// A tunneled placeholder would reach the host as-is and fail there
+		if p.surrogates.NeedsInjection(hostname) {
+			p.logger.LogDenied(r.Host, r.RemoteAddr, "placeholder keys need TLS interception (no proxy CA)")
+			http.Error(w, "agentbox: placeholder keys for "+hostname+" need TLS interception, but the proxy has no CA", http.StatusBadGateway)
+			return
+		}
+		p.logger.LogAuthSkipped(hostname, "HTTPS requires CA for auth injection - passing through")
+		p.tunnel(w, r)
+		return
+	}
+
+	cert, err := p.ca.Certificate(hostname)
+	if err != nil {
+		p.logger.LogError(r.Host, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	clientConn, _, err := hijacker.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := io.WriteString(clientConn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		clientConn.Close()
+		return
+	}
+
+	tlsConn := tls.Server(clientConn, &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   []string{"http/1.1"},
+		MinVersion:   tls.VersionTLS12,
+	})
+	tlsConn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := tlsConn.HandshakeContext(r.Context()); err != nil {
+		p.logger.LogError(r.Host, fmt.Errorf("TLS handshake with the guest failed (does it trust the agentbox CA?): %w", err))
+		clientConn.Close()
+		return
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	// Requests are sent where the guest connected, whatever their Host says
+	target := r.Host
+	host := strings.TrimSuffix(target, ":443")
+	inner := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.URL.Scheme = "https"
+			req.URL.Host = target
+			req.Host = host
+			req.RemoteAddr = r.RemoteAddr
+			p.handleHTTP(w, req)
+		}),
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       90 * time.Second,
+		ErrorLog:          log.New(io.Discard, "", 0),
+	}
+	ln := newConnListener(tlsConn)
+	inner.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed || state == http.StateHijacked {
+			ln.Close()
+		}
+	}
+	inner.Serve(ln)
+}
 
-	// Fall back to standard tunnel (no auth injection possible)
+// tunnel relays a CONNECT request's bytes without inspecting them
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
 	targetConn, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
 	if err != nil {
 		p.logger.LogError(r.Host, err)
@@ -169,6 +267,42 @@ func (p *Proxy) handleConnectMITM(w http.ResponseWriter, r *http.Request, hostna
 	wg.Wait()
 }
 
+// connListener serves a single intercepted connection to an http.Server
+type connListener struct {
+	conn net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func newConnListener(conn net.Conn) *connListener {
+	return &connListener{conn: conn, done: make(chan struct{})}
+}
+
+// Accept returns the connection once, then blocks until the listener closes
+func (l *connListener) Accept() (net.Conn, error) {
+	if conn := l.conn; conn != nil {
+		l.conn = nil
+		return conn, nil
+	}
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return dummyAddr{}
+}
+
+// dummyAddr is the address of a connListener
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string { return "tcp" }
+func (dummyAddr) String() string  { return "agentbox-mitm" }
+
 // handleHTTP handles plain HTTP proxy requests with auth injection
 func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	host := r.URL.Host
@@ -193,8 +327,27 @@ func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 		p.logger.LogAuthInjected(host, r.RemoteAddr)
 	}
 
+	// Swap placeholders for real secrets (only for the secret's own hosts)
+	swapped, leaked := p.surrogates.Swap(outReq, hostname)
+	for _, name := range leaked {
+		p.logger.LogExfiltration(host, r.RemoteAddr, name)
+	}
+	if len(swapped) > 0 {
+		p.logger.LogAuthInjected(host, r.RemoteAddr)
+	}
+
+	// Re-sign AWS requests with host credentials
+	if p.awsSigner.Matches(hostname) {
+		if err := p.awsSigner.Sign(outReq, hostname); err != nil {
+			p.logger.LogDenied(host, r.RemoteAddr, err.Error())
+			http.Error(w, "agentbox: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		p.logger.LogSigned(host, r.RemoteAddr)
+	}
+
 	// Forward the request
-	resp, err := http.DefaultTransport.RoundTrip(outReq)
+	resp, err := p.transport.RoundTrip(outReq)
 	if err != nil {
 		p.logger.LogError(host, err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
@@ -210,13 +363,32 @@ func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Copy status code and body
+	// Copy status code and body, flushing as it arrives so streamed
+	// responses (e.g., server-sent events) aren't held back
 	w.WriteHeader(resp.StatusCode)
-	io.Copy(w, resp.Body)
+	copyFlush(w, resp.Body)
 
 	p.logger.LogPass(host, r.RemoteAddr)
 }
 
+// copyFlush copies body to w, flushing after every read
+func copyFlush(w http.ResponseWriter, body io.Reader) {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 var hopHeaders = []string{
 	"Connection",
 	"Keep-Alive",
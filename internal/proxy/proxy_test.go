@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/secrets"
+)
+
+// recordingTransport stands in for the upstream servers, recording what
+// the proxy sends them
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(r.Body)
+	rt.mu.Lock()
+	rt.requests = append(rt.requests, r)
+	rt.bodies = append(rt.bodies, string(body))
+	rt.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("ok")),
+		Request:    r,
+	}, nil
+}
+
+func (rt *recordingTransport) last(t *testing.T) (*http.Request, string) {
+	t.Helper()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.requests) == 0 {
+		t.Fatal("no request reached the upstream")
+	}
+	return rt.requests[len(rt.requests)-1], rt.bodies[len(rt.bodies)-1]
+}
+
+// startProxy serves p with a fresh CA and returns a client that sends all
+// traffic through it and trusts the CA, like a guest would
+func startProxy(t *testing.T, p *Proxy) (*http.Client, *recordingTransport, string) {
+	t.Helper()
+
+	ca, err := LoadCA(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetCA(ca)
+	upstream := &recordingTransport{}
+	p.transport = upstream
+
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	proxyURL, _ := url.Parse(srv.URL)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+	t.Cleanup(client.CloseIdleConnections)
+	return client, upstream, srv.URL
+}
+
+// newTestLogger returns a logger writing to a temp network.log
+func newTestLogger(t *testing.T) (*Logger, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "network.log")
+	l, err := NewLogger(path, secrets.NewRedactor(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, path
+}
+
+func TestConnectSignsAWS(t *testing.T) {
+	logger, _ := newTestLogger(t)
+	creds := map[string]string{"AWS_ACCESS_KEY_ID": "AKIAHOSTKEY", "AWS_SECRET_ACCESS_KEY": "host-secret"}
+	p := New(config.NetworkConfig{
+		AWSSigning: &config.AWSSigningConfig{Services: []string{"sts"}},
+	}, nil, func(ref string) string { return creds[ref] }, logger)
+	client, upstream, _ := startProxy(t, p)
+
+	req, _ := http.NewRequest("POST", "https://sts.amazonaws.com/", strings.NewReader("Action=GetCallerIdentity&Version=2011-06-15"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIAAGENTBOXPROXY000/20240101/us-east-1/sts/aws4_request, SignedHeaders=host, Signature=00")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got, body := upstream.last(t)
+	if got.URL.String() != "https://sts.amazonaws.com:443/" {
+		t.Errorf("forwarded to %s", got.URL)
+	}
+	if auth := got.Header.Get("Authorization"); !strings.Contains(auth, "Credential=AKIAHOSTKEY/") {
+		t.Errorf("request was not re-signed over HTTPS: %q", auth)
+	}
+	if !strings.Contains(body, "GetCallerIdentity") {
+		t.Errorf("body = %q", body)
+	}
+
+	// Services outside the allowlist are refused
+	req, _ = http.NewRequest("GET", "https://s3.amazonaws.com/", nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestConnectInjectsAuth(t *testing.T) {
+	logger, _ := newTestLogger(t)
+	p := New(config.NetworkConfig{
+		InjectAuth: []config.AuthConfig{{Host: "api.anthropic.com", Header: "x-api-key", Env: "KEY"}},
+	}, nil, func(ref string) string { return "sk-ant-host" }, logger)
+	client, upstream, _ := startProxy(t, p)
+
+	// The Host header can't redirect an intercepted connection
+	req, _ := http.NewRequest("GET", "https://api.anthropic.com/v1/models", nil)
+	req.Host = "evil.example.com"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "ok" {
+		t.Errorf("response = %q", data)
+	}
+
+	got, _ := upstream.last(t)
+	if got.Header.Get("x-api-key") != "sk-ant-host" {
+		t.Errorf("auth not injected over HTTPS: %v", got.Header)
+	}
+	if got.URL.Host != "api.anthropic.com:443" || got.Host != "api.anthropic.com" {
+		t.Errorf("forwarded to %s (Host %s)", got.URL.Host, got.Host)
+	}
+}
+
+func TestConnectWithoutCATunnels(t *testing.T) {
+	logger, path := newTestLogger(t)
+	p := New(config.NetworkConfig{
+		InjectAuth: []config.AuthConfig{{Host: "127.0.0.1", Header: "x-api-key", Env: "KEY"}},
+	}, nil, func(ref string) string { return "sk-host" }, logger)
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "key="+r.Header.Get("x-api-key"))
+	}))
+	defer upstream.Close()
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	proxyURL, _ := url.Parse(srv.URL)
+	transport := upstream.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "key=" {
+		t.Errorf("without a CA the connection should be tunneled untouched, got %q", data)
+	}
+
+	logger.Close()
+	log, _ := os.ReadFile(path)
+	if !strings.Contains(string(log), "passing through") {
+		t.Errorf("skipped injection should be logged: %s", log)
+	}
+}
+
+func TestConnectSwapsSurrogates(t *testing.T) {
+	logger, path := newTestLogger(t)
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-real-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(config.NetworkConfig{
+		InjectAuth: []config.AuthConfig{{Host: "api.anthropic.com", Header: "x-api-key", Env: "KEY"}},
+	}, []*secrets.Surrogate{sur}, func(ref string) string { return "sk-ant-host" }, logger)
+	client, upstream, _ := startProxy(t, p)
+
+	req, _ := http.NewRequest("POST", "https://api.openai.com/v1/chat", strings.NewReader(`{"key":"`+sur.Placeholder+`"}`))
+	req.Header.Set("Authorization", "Bearer "+sur.Placeholder)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got, body := upstream.last(t)
+	if got.Header.Get("Authorization") != "Bearer sk-real-secret-value" {
+		t.Errorf("placeholder not swapped over HTTPS: %q", got.Header.Get("Authorization"))
+	}
+	if !strings.Contains(body, "sk-real-secret-value") {
+		t.Errorf("body not swapped over HTTPS: %q", body)
+	}
+
+	// Another intercepted host gets the placeholder, and it's reported
+	req, _ = http.NewRequest("POST", "https://api.anthropic.com/v1/messages", strings.NewReader("k="+sur.Placeholder))
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	_, body = upstream.last(t)
+	if strings.Contains(body, "sk-real-secret-value") || !strings.Contains(body, sur.Placeholder) {
+		t.Errorf("real value must never reach another host: %q", body)
+	}
+	logger.Close()
+	log, _ := os.ReadFile(path)
+	if !strings.Contains(string(log), "EXFIL") || !strings.Contains(string(log), "api.anthropic.com") {
+		t.Errorf("leak over HTTPS should be logged: %s", log)
+	}
+}
+
+func TestConnectSurrogatesNeedCA(t *testing.T) {
+	logger, path := newTestLogger(t)
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-real-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(config.NetworkConfig{}, []*secrets.Surrogate{sur}, nil, logger)
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	proxyURL, _ := url.Parse(srv.URL)
+	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	defer transport.CloseIdleConnections()
+	if _, err := (&http.Client{Transport: transport}).Get("https://api.openai.com/v1/models"); err == nil {
+		t.Fatal("expected the CONNECT to be refused without a CA")
+	}
+
+	logger.Close()
+	log, _ := os.ReadFile(path)
+	if !strings.Contains(string(log), "need TLS interception") {
+		t.Errorf("refusal should be logged: %s", log)
+	}
+}
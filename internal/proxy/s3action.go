@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// s3Actions maps an S3 request to its IAM action, keyed by method, what the
+// request addresses (service, bucket or object) and the subresource
+// ("" for none)
+var s3Actions = map[string]string{
+	"GET service ": "ListAllMyBuckets",
+
+	"GET bucket ":                    "ListBucket",
+	"HEAD bucket ":                   "ListBucket",
+	"GET bucket acl":                 "GetBucketAcl",
+	"GET bucket accelerate":          "GetAccelerateConfiguration",
+	"GET bucket analytics":           "GetAnalyticsConfiguration",
+	"GET bucket cors":                "GetBucketCORS",
+	"GET bucket encryption":          "GetEncryptionConfiguration",
+	"GET bucket intelligent-tiering": "GetIntelligentTieringConfiguration",
+	"GET bucket inventory":           "GetInventoryConfiguration",
+	"GET bucket lifecycle":           "GetLifecycleConfiguration",
+	"GET bucket location":            "GetBucketLocation",
+	"GET bucket logging":             "GetBucketLogging",
+	"GET bucket metrics":             "GetMetricsConfiguration",
+	"GET bucket notification":        "GetBucketNotification",
+	"GET bucket object-lock":         "GetBucketObjectLockConfiguration",
+	"GET bucket ownershipControls":   "GetBucketOwnershipControls",
+	"GET bucket policy":              "GetBucketPolicy",
+	"GET bucket policyStatus":        "GetBucketPolicyStatus",
+	"GET bucket publicAccessBlock":   "GetBucketPublicAccessBlock",
+	"GET bucket replication":         "GetReplicationConfiguration",
+	"GET bucket requestPayment":      "GetBucketRequestPayment",
+	"GET bucket tagging":             "GetBucketTagging",
+	"GET bucket uploads":             "ListBucketMultipartUploads",
+	"GET bucket versioning":          "GetBucketVersioning",
+	"GET bucket versions":            "ListBucketVersions",
+	"GET bucket website":             "GetBucketWebsite",
+
+	"PUT bucket ":                    "CreateBucket",
+	"PUT bucket acl":                 "PutBucketAcl",
+	"PUT bucket accelerate":          "PutAccelerateConfiguration",
+	"PUT bucket analytics":           "PutAnalyticsConfiguration",
+	"PUT bucket cors":                "PutBucketCORS",
+	"PUT bucket encryption":          "PutEncryptionConfiguration",
+	"PUT bucket intelligent-tiering": "PutIntelligentTieringConfiguration",
+	"PUT bucket inventory":           "PutInventoryConfiguration",
+	"PUT bucket lifecycle":           "PutLifecycleConfiguration",
+	"PUT bucket logging":             "PutBucketLogging",
+	"PUT bucket metrics":             "PutMetricsConfiguration",
+	"PUT bucket notification":        "PutBucketNotification",
+	"PUT bucket object-lock":         "PutBucketObjectLockConfiguration",
+	"PUT bucket ownershipControls":   "PutBucketOwnershipControls",
+	"PUT bucket policy":              "PutBucketPolicy",
+	"PUT bucket publicAccessBlock":   "PutBucketPublicAccessBlock",
+	"PUT bucket replication":         "PutReplicationConfiguration",
+	"PUT bucket requestPayment":      "PutBucketRequestPayment",
+	"PUT bucket tagging":             "PutBucketTagging",
+	"PUT bucket versioning":          "PutBucketVersioning",
+	"PUT bucket website":             "PutBucketWebsite",
+
+	"DELETE bucket ":                    "DeleteBucket",
+	"DELETE bucket analytics":           "PutAnalyticsConfiguration",
+	"DELETE bucket cors":                "PutBucketCORS",
+	"DELETE bucket encryption":          "PutEncryptionConfiguration",
+	"DELETE bucket intelligent-tiering": "PutIntelligentTieringConfiguration",
+	"DELETE bucket inventory":           "PutInventoryConfiguration",
+	"DELETE bucket lifecycle":           "PutLifecycleConfiguration",
+	"DELETE bucket metrics":             "PutMetricsConfiguration",
+	"DELETE bucket ownershipControls":   "PutBucketOwnershipControls",
+	"DELETE bucket policy":              "DeleteBucketPolicy",
+	"DELETE bucket publicAccessBlock":   "PutBucketPublicAccessBlock",
+	"DELETE bucket replication":         "PutReplicationConfiguration",
+	"DELETE bucket tagging":             "PutBucketTagging",
+	"DELETE bucket website":             "DeleteBucketWebsite",
+
+	"POST bucket ":       "PutObject",
+	"POST bucket delete": "DeleteObject",
+
+	"GET object ":           "GetObject",
+	"HEAD object ":          "GetObject",
+	"GET object acl":        "GetObjectAcl",
+	"GET object attributes": "GetObjectAttributes",
+	"GET object legal-hold": "GetObjectLegalHold",
+	"GET object retention":  "GetObjectRetention",
+	"GET object tagging":    "GetObjectTagging",
+	"GET object torrent":    "GetObjectTorrent",
+	"GET object uploadId":   "ListMultipartUploadParts",
+
+	"PUT object ":           "PutObject",
+	"PUT object acl":        "PutObjectAcl",
+	"PUT object legal-hold": "PutObjectLegalHold",
+	"PUT object retention":  "PutObjectRetention",
+	"PUT object tagging":    "PutObjectTagging",
+
+	"DELETE object ":         "DeleteObject",
+	"DELETE object tagging":  "DeleteObjectTagging",
+	"DELETE object uploadId": "AbortMultipartUpload",
+
+	"POST object uploads":  "PutObject",
+	"POST object uploadId": "PutObject",
+	"POST object restore":  "RestoreObject",
+	"POST object select":   "GetObject",
+}
+
+// s3VersionActions are the actions on a specific object version (versionId)
+var s3VersionActions = map[string]string{
+	"GetObject":           "GetObjectVersion",
+	"DeleteObject":        "DeleteObjectVersion",
+	"GetObjectAcl":        "GetObjectVersionAcl",
+	"PutObjectAcl":        "PutObjectVersionAcl",
+	"GetObjectTagging":    "GetObjectVersionTagging",
+	"PutObjectTagging":    "PutObjectVersionTagging",
+	"DeleteObjectTagging": "DeleteObjectVersionTagging",
+}
+
+// s3Params are query parameters that don't change the action
+var s3Params = map[string]bool{
+	"continuation-token": true, "delimiter": true, "encoding-type": true,
+	"fetch-owner": true, "id": true, "key-marker": true, "list-type": true,
+	"marker": true, "max-keys": true, "max-parts": true, "max-uploads": true,
+	"part-number-marker": true, "partNumber": true, "prefix": true,
+	"start-after": true, "upload-id-marker": true, "uploadId": true,
+	"version-id-marker": true, "versionId": true, "x-id": true,
+}
+
+// s3Action derives the IAM action of an S3 request from its method, path
+// and subresource. Unknown parameters are refused rather than guessed at
+func s3Action(r *http.Request, hostname string) (string, error) {
+	target := "service"
+	switch bucket, key := s3Path(r, hostname); {
+	case key != "":
+		target = "object"
+	case bucket != "":
+		target = "bucket"
+	}
+
+	query := r.URL.Query()
+	subresource := ""
+	for name := range query {
+		lower := strings.ToLower(name)
+		switch {
+		case s3Actions[r.Method+" "+target+" "+name] != "":
+			if subresource != "" {
+				return "", fmt.Errorf("S3 request with subresources %q and %q", subresource, name)
+			}
+			subresource = name
+		case s3Params[name], strings.HasPrefix(lower, "response-"), strings.HasPrefix(lower, "x-amz-"):
+		default:
+			return "", fmt.Errorf("unrecognized S3 parameter %q", name)
+		}
+	}
+
+	action, ok := s3Actions[r.Method+" "+target+" "+subresource]
+	if !ok {
+		return "", fmt.Errorf("unrecognized S3 request %s on %s", r.Method, target)
+	}
+	if query.Has("versionId") && s3VersionActions[action] != "" {
+		action = s3VersionActions[action]
+	}
+	return action, nil
+}
+
+// s3Path splits a request into bucket and key, for virtual-hosted
+// (bucket.s3.region.amazonaws.com) and path-style (s3.region.amazonaws.com/bucket)
+// endpoints
+func s3Path(r *http.Request, hostname string) (bucket, key string) {
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(hostname, ".")), ".")
+	for i, label := range labels {
+		if label == "s3" {
+			if i > 0 {
+				return strings.Join(labels[:i], "."), p
+			}
+			break
+		}
+	}
+	bucket, key, _ = strings.Cut(p, "/")
+	return bucket, key
+}
@@ -0,0 +1,442 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	defaultAWSRegion = "us-east-1"
+)
+
+// AWSSigner re-signs AWS API requests with host-side credentials
+// The guest signs with dummy credentials; the signer strips that signature,
+// checks the request against the allowlist and signs it again with SigV4
+type AWSSigner struct {
+	services []string
+	regions  []string
+	actions  []string
+
+	accessKeyEnv    string
+	secretKeyEnv    string
+	sessionTokenEnv string
+
+	lookup func(ref string) string
+	now    func() time.Time
+}
+
+// awsCredentials holds the host credentials used for signing
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// awsScope identifies the service and region a request is signed for
+type awsScope struct {
+	service string
+	region  string
+}
+
+// NewAWSSigner creates a signer from config
+// lookup resolves the credential env settings (var names or secret://name);
+// nil reads the host environment. Returns nil if AWS signing is not configured
+func NewAWSSigner(cfg *config.AWSSigningConfig, lookup func(ref string) string) *AWSSigner {
+	if lookup == nil {
+		lookup = os.Getenv
+	}
+	if cfg == nil || len(cfg.Services) == 0 {
+		return nil
+	}
+
+	s := &AWSSigner{
+		services:        lowerAll(cfg.Services),
+		regions:         lowerAll(cfg.Regions),
+		actions:         cfg.Actions,
+		accessKeyEnv:    cfg.AccessKeyEnv,
+		secretKeyEnv:    cfg.SecretKeyEnv,
+		sessionTokenEnv: cfg.SessionTokenEnv,
+		lookup:          lookup,
+		now:             time.Now,
+	}
+	if s.accessKeyEnv == "" {
+		s.accessKeyEnv = "AWS_ACCESS_KEY_ID"
+	}
+	if s.secretKeyEnv == "" {
+		s.secretKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	}
+	if s.sessionTokenEnv == "" {
+		s.sessionTokenEnv = "AWS_SESSION_TOKEN"
+	}
+	return s
+}
+
+// Matches checks if a host is an AWS endpoint handled by the signer
+func (s *AWSSigner) Matches(hostname string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := scopeFromHost(hostname)
+	return ok
+}
+
+// Sign validates the request against the allowlist and re-signs it in place
+// The request body is read and replaced so it can be hashed
+func (s *AWSSigner) Sign(r *http.Request, hostname string) error {
+	hostScope, ok := scopeFromHost(hostname)
+	if !ok {
+		return fmt.Errorf("%s is not an AWS endpoint", hostname)
+	}
+
+	// Prefer the scope the guest SDK signed for - it knows the signing name
+	// for endpoints like monitoring.* or email.*. AWS rejects a scope that
+	// doesn't match the endpoint, so the guest can't use it to widen access.
+	scope, ok := scopeFromAuthorization(r.Header.Get("Authorization"))
+	if !ok {
+		scope = hostScope
+	}
+
+	if !matchAny(s.services, scope.service) {
+		return fmt.Errorf("service %q is not allowed", scope.service)
+	}
+	if len(s.regions) > 0 && !matchAny(s.regions, scope.region) {
+		return fmt.Errorf("region %q is not allowed", scope.region)
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	if len(s.actions) > 0 {
+		action, err := requestAction(r, body, hostname, scope.service)
+		if err != nil {
+			return fmt.Errorf("refusing %s request: %w", scope.service, err)
+		}
+		if !matchAny(s.actions, scope.service+":"+action) {
+			return fmt.Errorf("action %s:%s is not allowed", scope.service, action)
+		}
+	}
+
+	creds, err := s.credentials()
+	if err != nil {
+		return err
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if strings.HasPrefix(payloadHash, "STREAMING-") {
+		return fmt.Errorf("streaming payload signatures cannot be re-signed")
+	}
+	if payloadHash != unsignedPayload {
+		payloadHash = hashHex(body)
+	}
+
+	// Strip the guest's signature
+	r.Header.Del("Authorization")
+	r.Header.Del("X-Amz-Date")
+	r.Header.Del("X-Amz-Security-Token")
+	r.Header.Del("X-Amz-Content-Sha256")
+
+	signV4(r, creds, scope, payloadHash, s.now().UTC())
+	return nil
+}
+
+// credentials reads host credentials from the configured env vars or secrets
+func (s *AWSSigner) credentials() (awsCredentials, error) {
+	creds := awsCredentials{
+		accessKey:    s.lookup(s.accessKeyEnv),
+		secretKey:    s.lookup(s.secretKeyEnv),
+		sessionToken: s.lookup(s.sessionTokenEnv),
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return creds, fmt.Errorf("host credentials not set (%s, %s)", s.accessKeyEnv, s.secretKeyEnv)
+	}
+	return creds, nil
+}
+
+// signV4 adds SigV4 headers to the request
+func signV4(r *http.Request, creds awsCredentials, scope awsScope, payloadHash string, now time.Time) {
+	amzDate := now.Format(sigV4TimeFormat)
+	date := now.Format(sigV4DateFormat)
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	if scope.service == "s3" {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(r.Header, host)
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL, scope.service),
+		canonicalQuery(r.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := strings.Join([]string{date, scope.region, scope.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		credentialScope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	key = hmacSHA256(key, scope.region)
+	key = hmacSHA256(key, scope.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.accessKey, credentialScope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the signed header list and canonical header block
+// Signs host, content-type and every x-amz-* header
+func canonicalHeaders(h http.Header, host string) (string, string) {
+	values := map[string]string{"host": strings.TrimSpace(host)}
+	for k, vv := range h {
+		lk := strings.ToLower(k)
+		if lk != "content-type" && !strings.HasPrefix(lk, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(vv))
+		for i, v := range vv {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[lk] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteString(k)
+		b.WriteByte(':')
+		b.WriteString(values[k])
+		b.WriteByte('\n')
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// canonicalURI returns the SigV4 canonical path
+// S3 uses the path as sent; other services encode it a second time
+func canonicalURI(u *url.URL, service string) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the sorted, AWS-encoded query string
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vals := append([]string(nil), query[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything except RFC 3986 unreserved characters
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// scopeFromHost derives service and region from an AWS endpoint hostname
+// Handles <service>.<region>.amazonaws.com, global <service>.amazonaws.com
+// and S3 virtual-hosted bucket endpoints
+func scopeFromHost(hostname string) (awsScope, bool) {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	rest, ok := strings.CutSuffix(hostname, ".amazonaws.com")
+	if !ok || rest == "" {
+		return awsScope{}, false
+	}
+
+	labels := strings.Split(rest, ".")
+	for i, label := range labels {
+		if label == "s3" {
+			region := defaultAWSRegion
+			if i+1 < len(labels) {
+				region = labels[i+1]
+			}
+			return awsScope{service: "s3", region: region}, true
+		}
+	}
+
+	switch len(labels) {
+	case 1:
+		return awsScope{service: labels[0], region: defaultAWSRegion}, true
+	case 2:
+		return awsScope{service: labels[0], region: labels[1]}, true
+	}
+	return awsScope{}, false
+}
+
+// scopeFromAuthorization extracts service and region from a SigV4
+// Authorization header (Credential=AKID/date/region/service/aws4_request)
+func scopeFromAuthorization(auth string) (awsScope, bool) {
+	_, after, ok := strings.Cut(auth, "Credential=")
+	if !ok {
+		return awsScope{}, false
+	}
+	cred, _, _ := strings.Cut(after, ",")
+	parts := strings.Split(strings.TrimSpace(cred), "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return awsScope{}, false
+	}
+	return awsScope{service: strings.ToLower(parts[3]), region: strings.ToLower(parts[2])}, true
+}
+
+// requestAction determines the API action of a request the way AWS does,
+// so the guest can't name an allowed action and perform another one
+// JSON protocols use X-Amz-Target, query protocols the Action parameter
+// (URL or form body), S3 the method, path and subresource; other REST-style
+// services fall back to the HTTP method. Sources that disagree are refused
+func requestAction(r *http.Request, body []byte, hostname, service string) (string, error) {
+	if service == "s3" {
+		return s3Action(r, hostname)
+	}
+
+	var targets []string
+	for _, target := range r.Header.Values("X-Amz-Target") {
+		if i := strings.LastIndex(target, "."); i >= 0 {
+			target = target[i+1:]
+		}
+		targets = append(targets, target)
+	}
+	actions := r.URL.Query()["Action"]
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", fmt.Errorf("invalid form body: %w", err)
+		}
+		actions = append(actions, form["Action"]...)
+	}
+
+	// AWS only reads X-Amz-Target on JSON protocol requests
+	sources := actions
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-amz-json-") {
+		if len(targets) == 0 {
+			return "", fmt.Errorf("JSON request without X-Amz-Target")
+		}
+		sources = append(targets, actions...)
+	} else if len(targets) > 0 && len(actions) == 0 {
+		return "", fmt.Errorf("X-Amz-Target %q on a non-JSON request", targets[0])
+	} else {
+		sources = append(actions, targets...)
+	}
+
+	if len(sources) == 0 {
+		return r.Method, nil
+	}
+	for _, action := range sources[1:] {
+		if action != sources[0] {
+			return "", fmt.Errorf("conflicting actions %q and %q", sources[0], action)
+		}
+	}
+	return sources[0], nil
+}
+
+// readBody reads the request body and replaces it with a re-readable copy
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	return body, nil
+}
+
+// matchAny reports whether value matches any of the glob patterns
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(v)
+	}
+	return out
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
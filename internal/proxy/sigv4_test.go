@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestSignV4KnownVector(t *testing.T) {
+	// Example request from the AWS SigV4 documentation
+	req, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds := awsCredentials{
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	signV4(req, creds, awsScope{service: "iam", region: "us-east-1"}, hashHex(nil), now)
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("unexpected Authorization header:\n got: %s\nwant: %s", got, expected)
+	}
+}
+
+func TestScopeFromHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		service string
+		region  string
+		ok      bool
+	}{
+		{"sts.amazonaws.com", "sts", "us-east-1", true},
+		{"dynamodb.eu-west-1.amazonaws.com", "dynamodb", "eu-west-1", true},
+		{"s3.us-west-2.amazonaws.com", "s3", "us-west-2", true},
+		{"mybucket.s3.us-west-2.amazonaws.com", "s3", "us-west-2", true},
+		{"mybucket.s3.amazonaws.com", "s3", "us-east-1", true},
+		{"api.anthropic.com", "", "", false},
+		{"amazonaws.com.evil.com", "", "", false},
+	}
+
+	for _, tt := range tests {
+		scope, ok := scopeFromHost(tt.host)
+		if ok != tt.ok {
+			t.Errorf("scopeFromHost(%q) ok = %v, want %v", tt.host, ok, tt.ok)
+			continue
+		}
+		if scope.service != tt.service || scope.region != tt.region {
+			t.Errorf("scopeFromHost(%q) = %s/%s, want %s/%s", tt.host, scope.service, scope.region, tt.service, tt.region)
+		}
+	}
+}
+
+func TestAWSSignerReplacesGuestSignature(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAHOSTKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "host-secret")
+
+	signer := NewAWSSigner(&config.AWSSigningConfig{Services: []string{"sts"}}, nil)
+
+	req, _ := http.NewRequest("POST", "http://sts.amazonaws.com/", strings.NewReader("Action=GetCallerIdentity&Version=2011-06-15"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIAAGENTBOXPROXY000/20240101/us-east-1/sts/aws4_request, SignedHeaders=host, Signature=abc")
+
+	if err := signer.Sign(req, "sts.amazonaws.com"); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.Contains(auth, "Credential=AKIAHOSTKEY/") {
+		t.Errorf("expected host credentials in Authorization, got %q", auth)
+	}
+	if strings.Contains(auth, "AGENTBOX") {
+		t.Error("guest signature should be stripped")
+	}
+}
+
+func TestAWSSignerAllowlist(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAHOSTKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "host-secret")
+
+	signer := NewAWSSigner(&config.AWSSigningConfig{
+		Services: []string{"dynamodb"},
+		Regions:  []string{"us-east-1"},
+		Actions:  []string{"dynamodb:Get*", "dynamodb:Query"},
+	}, nil)
+
+	tests := []struct {
+		host    string
+		target  string
+		allowed bool
+	}{
+		{"dynamodb.us-east-1.amazonaws.com", "DynamoDB_20120810.GetItem", true},
+		{"dynamodb.us-east-1.amazonaws.com", "DynamoDB_20120810.Query", true},
+		{"dynamodb.us-east-1.amazonaws.com", "DynamoDB_20120810.DeleteTable", false},
+		{"dynamodb.eu-west-1.amazonaws.com", "DynamoDB_20120810.GetItem", false},
+		{"sqs.us-east-1.amazonaws.com", "AmazonSQS.SendMessage", false},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "http://"+tt.host+"/", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+		req.Header.Set("X-Amz-Target", tt.target)
+
+		err := signer.Sign(req, tt.host)
+		if tt.allowed && err != nil {
+			t.Errorf("%s %s: expected allowed, got %v", tt.host, tt.target, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("%s %s: expected denied", tt.host, tt.target)
+		}
+	}
+}
+
+func TestAWSSignerActionSpoofing(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAHOSTKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "host-secret")
+
+	signer := NewAWSSigner(&config.AWSSigningConfig{
+		Services: []string{"sts", "s3", "dynamodb"},
+		Actions:  []string{"sts:GetCallerIdentity", "s3:Get*", "s3:ListBucket", "dynamodb:GetItem"},
+	}, nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		target      string
+		body        string
+		allowed     bool
+	}{
+		{"query action", "GET", "http://sts.amazonaws.com/?Action=GetCallerIdentity", "", "", "", true},
+		{"form action", "POST", "http://sts.amazonaws.com/", "application/x-www-form-urlencoded", "", "Action=GetCallerIdentity", true},
+		{"json target", "POST", "http://dynamodb.us-east-1.amazonaws.com/", "application/x-amz-json-1.0", "DynamoDB_20120810.GetItem", "{}", true},
+		{"s3 get object", "GET", "http://bucket.s3.us-east-1.amazonaws.com/key.txt", "", "", "", true},
+		{"s3 list path-style", "GET", "http://s3.us-east-1.amazonaws.com/bucket?list-type=2&prefix=a", "", "", "", true},
+
+		// X-Amz-Target is ignored by query and REST APIs
+		{"target over form action", "POST", "http://sts.amazonaws.com/", "application/x-www-form-urlencoded", "x.GetCallerIdentity", "Action=AssumeRole", false},
+		{"target over query action", "GET", "http://sts.amazonaws.com/?Action=AssumeRole", "", "x.GetCallerIdentity", "", false},
+		{"target on s3 delete", "DELETE", "http://bucket.s3.us-east-1.amazonaws.com/key.txt", "", "x.GET", "", false},
+		{"target on s3 put", "PUT", "http://s3.us-east-1.amazonaws.com/bucket/key.txt", "", "x.GetObject", "data", false},
+		{"target without json", "POST", "http://dynamodb.us-east-1.amazonaws.com/", "", "DynamoDB_20120810.GetItem", "{}", false},
+
+		// Sources that disagree
+		{"query and form", "POST", "http://sts.amazonaws.com/?Action=GetCallerIdentity", "application/x-www-form-urlencoded", "", "Action=AssumeRole", false},
+		{"repeated action", "GET", "http://sts.amazonaws.com/?Action=GetCallerIdentity&Action=AssumeRole", "", "", "", false},
+		{"json and query", "POST", "http://dynamodb.us-east-1.amazonaws.com/?Action=DeleteTable", "application/x-amz-json-1.0", "DynamoDB_20120810.GetItem", "{}", false},
+		{"json without target", "POST", "http://dynamodb.us-east-1.amazonaws.com/", "application/x-amz-json-1.0", "", "{}", false},
+
+		// S3 actions come from the method, path and subresource
+		{"s3 bucket policy", "GET", "http://bucket.s3.us-east-1.amazonaws.com/?policy", "", "", "", true},
+		{"s3 put policy", "PUT", "http://bucket.s3.us-east-1.amazonaws.com/?policy", "", "", "{}", false},
+		{"s3 delete bucket", "DELETE", "http://s3.us-east-1.amazonaws.com/bucket", "", "", "", false},
+		{"s3 unknown parameter", "GET", "http://bucket.s3.us-east-1.amazonaws.com/?made-up", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		var body io.Reader
+		if tt.body != "" {
+			body = strings.NewReader(tt.body)
+		}
+		req, _ := http.NewRequest(tt.method, tt.url, body)
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.target != "" {
+			req.Header.Set("X-Amz-Target", tt.target)
+		}
+
+		err := signer.Sign(req, req.URL.Hostname())
+		if tt.allowed && err != nil {
+			t.Errorf("%s: expected allowed, got %v", tt.name, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("%s: expected denied", tt.name)
+		}
+	}
+}
+
+func TestS3Action(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		want   string
+	}{
+		{"GET", "http://s3.amazonaws.com/", "ListAllMyBuckets"},
+		{"GET", "http://bucket.s3.amazonaws.com/", "ListBucket"},
+		{"HEAD", "http://s3.amazonaws.com/bucket", "ListBucket"},
+		{"GET", "http://bucket.s3.amazonaws.com/a/b.txt", "GetObject"},
+		{"GET", "http://bucket.s3.amazonaws.com/a/b.txt?versionId=3", "GetObjectVersion"},
+		{"GET", "http://bucket.s3.amazonaws.com/a/b.txt?response-content-type=text/plain&x-id=GetObject", "GetObject"},
+		{"PUT", "http://s3.amazonaws.com/bucket/a.txt?partNumber=1&uploadId=x", "PutObject"},
+		{"GET", "http://s3.amazonaws.com/bucket/a.txt?uploadId=x", "ListMultipartUploadParts"},
+		{"DELETE", "http://s3.amazonaws.com/bucket/a.txt?uploadId=x", "AbortMultipartUpload"},
+		{"DELETE", "http://my.dotted.bucket.s3.us-west-2.amazonaws.com/a.txt", "DeleteObject"},
+		{"POST", "http://bucket.s3.amazonaws.com/?delete", "DeleteObject"},
+		{"PUT", "http://bucket.s3.amazonaws.com/?tagging", "PutBucketTagging"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.url, nil)
+		got, err := s3Action(req, req.URL.Hostname())
+		if err != nil || got != tt.want {
+			t.Errorf("%s %s = %q, %v; want %q", tt.method, tt.url, got, err, tt.want)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "http://bucket.s3.amazonaws.com/a.txt?acl&tagging", nil)
+	if _, err := s3Action(req, req.URL.Hostname()); err == nil {
+		t.Error("two subresources should be refused")
+	}
+}
+
+func TestNewAWSSignerDisabled(t *testing.T) {
+	if NewAWSSigner(nil, nil) != nil {
+		t.Error("expected nil signer without config")
+	}
+
+	var signer *AWSSigner
+	if signer.Matches("s3.amazonaws.com") {
+		t.Error("nil signer should not match any host")
+	}
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/davidsenack/agentbox/internal/secrets"
+)
+
+// maxSwapBody is the largest request body scanned for placeholders
+const maxSwapBody = 1 << 20
+
+// SurrogateSwapper replaces per-session placeholders with real secrets
+// Placeholders are only swapped for the secret's configured hosts; anywhere
+// else they are left untouched and reported as leaks
+type SurrogateSwapper struct {
+	mu         sync.RWMutex
+	surrogates []*secrets.Surrogate
+}
+
+// NewSurrogateSwapper creates a swapper for the session's surrogates
+func NewSurrogateSwapper(surrogates []*secrets.Surrogate) *SurrogateSwapper {
+	return &SurrogateSwapper{surrogates: surrogates}
+}
+
+// Update replaces real values by secret name (e.g., after a rotation)
+// Placeholders stay the same, so the guest doesn't need to change anything.
+// An empty value (a removed secret) stops the placeholder being swapped.
+func (s *SurrogateSwapper) Update(values map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sur := range s.surrogates {
+		if val, ok := values[sur.Name]; ok {
+			sur.Value = val
+		}
+	}
+}
+
+// NeedsInjection checks if a host receives any real value
+func (s *SurrogateSwapper) NeedsInjection(hostname string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sur := range s.surrogates {
+		if sur.AllowsHost(hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Swap replaces placeholders in the request headers, URL and body
+// Returns the names of secrets swapped in and the names of placeholders
+// found in a request to a host that isn't allowed to receive them. Only
+// requests the proxy can read get here: plain HTTP and intercepted TLS.
+func (s *SurrogateSwapper) Swap(r *http.Request, hostname string) (swapped, leaked []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.surrogates) == 0 {
+		return nil, nil
+	}
+
+	body := readSwapBody(r)
+
+	for _, sur := range s.surrogates {
+		if sur.Value == "" && sur.AllowsHost(hostname) {
+			// Removed from the store: nothing to swap in, and no leak
+			continue
+		}
+		found := false
+
+		for k, vv := range r.Header {
+			for i, v := range vv {
+				if strings.Contains(v, sur.Placeholder) {
+					found = true
+					if sur.AllowsHost(hostname) {
+						r.Header[k][i] = strings.ReplaceAll(v, sur.Placeholder, sur.Value)
+					}
+				}
+			}
+		}
+
+		if strings.Contains(r.URL.RawQuery, sur.Placeholder) || strings.Contains(r.URL.Path, sur.Placeholder) {
+			found = true
+			if sur.AllowsHost(hostname) {
+				r.URL.RawQuery = strings.ReplaceAll(r.URL.RawQuery, sur.Placeholder, sur.Value)
+				r.URL.Path = strings.ReplaceAll(r.URL.Path, sur.Placeholder, sur.Value)
+				r.URL.RawPath = ""
+			}
+		}
+
+		if bytes.Contains(body, []byte(sur.Placeholder)) {
+			found = true
+			if sur.AllowsHost(hostname) {
+				body = bytes.ReplaceAll(body, []byte(sur.Placeholder), []byte(sur.Value))
+			}
+		}
+
+		if !found {
+			continue
+		}
+		if sur.AllowsHost(hostname) {
+			swapped = append(swapped, sur.Name)
+		} else {
+			leaked = append(leaked, sur.Name)
+		}
+	}
+
+	if body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+
+	return swapped, leaked
+}
+
+// readSwapBody buffers a request body of up to maxSwapBody bytes, including
+// chunked bodies of unknown length. Larger bodies are passed on unscanned
+func readSwapBody(r *http.Request) []byte {
+	if r.ContentLength > maxSwapBody {
+		return nil
+	}
+	if r.ContentLength >= 0 {
+		body, _ := readBody(r)
+		return body
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSwapBody+1))
+	if err != nil || len(body) > maxSwapBody {
+		// Put back what was read so the request is forwarded unchanged
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+	return body
+}
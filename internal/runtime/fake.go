@@ -0,0 +1,514 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+)
+
+// Fake is an in-memory runtime for tests
+// It records every call and keeps per-box state (running, secrets, files)
+// so commands can be driven end to end without a VM. Register it with
+//
+//	f := runtime.NewFake()
+//	runtime.Register("fake", f.Factory)
+//
+// and select it with runtime: fake or AGENTBOX_RUNTIME=fake.
+type Fake struct {
+	mu    sync.Mutex
+	boxes map[string]*FakeBox
+	calls []string
+
+	// Errors makes an operation fail, keyed by method name (e.g., "Start")
+	Errors map[string]error
+	// ShellFunc runs while the "interactive" shell is open; nil returns at once
+	ShellFunc func(project string) error
+	// ExecFunc handles Exec; nil succeeds without output
+	ExecFunc func(project string, command []string, opts ExecOptions) error
+	// ProvisionFunc handles Provision; nil succeeds without output
+	ProvisionFunc func(spec Spec, sections []string, out io.Writer) error
+}
+
+// FakeBox is the state of one fake sandbox
+type FakeBox struct {
+	Spec    Spec
+	Running bool
+	Secrets map[string]string
+	Files   map[string][]byte // Guest path -> content
+	CACert  []byte            // Proxy CA from InstallCA
+}
+
+// NewFake creates an empty fake runtime
+func NewFake() *Fake {
+	return &Fake{
+		boxes:  make(map[string]*FakeBox),
+		Errors: make(map[string]error),
+	}
+}
+
+// Factory returns the fake itself for any config, so every command in a
+// test sees the same boxes
+func (f *Fake) Factory(cfg *config.Config) (Runtime, error) {
+	return f, nil
+}
+
+// Calls returns the recorded calls as "Method project" strings
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// Box returns a copy of a box's state
+func (f *Fake) Box(project string) (FakeBox, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	box, ok := f.boxes[project]
+	if !ok {
+		return FakeBox{}, false
+	}
+	out := *box
+	out.Secrets = make(map[string]string, len(box.Secrets))
+	for k, v := range box.Secrets {
+		out.Secrets[k] = v
+	}
+	return out, true
+}
+
+// record logs a call and returns ctx's error or the configured error for
+// it, if any
+func (f *Fake) record(ctx context.Context, method, project string) error {
+	f.calls = append(f.calls, method+" "+project)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Errors[method]
+}
+
+// box returns an existing box or an error naming the instance
+func (f *Fake) box(project string) (*FakeBox, error) {
+	box, ok := f.boxes[project]
+	if !ok {
+		return nil, fmt.Errorf("instance %q does not exist", f.InstanceName(project))
+	}
+	return box, nil
+}
+
+// Name returns "fake"
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// InstanceName mirrors the Lima naming
+func (f *Fake) InstanceName(project string) string {
+	return "agentbox-" + strings.ReplaceAll(project, "/", "-")
+}
+
+// Create adds a stopped box
+func (f *Fake) Create(ctx context.Context, spec Spec) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "Create", spec.Name); err != nil {
+		return err
+	}
+	if _, ok := f.boxes[spec.Name]; ok {
+		return fmt.Errorf("instance %q already exists", f.InstanceName(spec.Name))
+	}
+	f.boxes[spec.Name] = &FakeBox{
+		Spec:    spec,
+		Secrets: make(map[string]string),
+		Files:   make(map[string][]byte),
+	}
+	return nil
+}
+
+// Start marks a box running
+func (f *Fake) Start(ctx context.Context, project string) error {
+	return f.setRunning(ctx, "Start", project, true)
+}
+
+// Stop marks a box stopped; like the tmpfs in the VM, secrets are lost
+func (f *Fake) Stop(ctx context.Context, project string) error {
+	return f.setRunning(ctx, "Stop", project, false)
+}
+
+func (f *Fake) setRunning(ctx context.Context, method, project string, running bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, method, project); err != nil {
+		return err
+	}
+	box, err := f.box(project)
+	if err != nil {
+		return err
+	}
+	box.Running = running
+	if !running {
+		box.Secrets = make(map[string]string)
+	}
+	return nil
+}
+
+// Delete removes a box
+func (f *Fake) Delete(ctx context.Context, project string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "Delete", project); err != nil {
+		return err
+	}
+	if _, err := f.box(project); err != nil {
+		return err
+	}
+	delete(f.boxes, project)
+	return nil
+}
+
+// Exists checks if a box exists
+func (f *Fake) Exists(ctx context.Context, project string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.Errors["Exists"]; err != nil {
+		return false, err
+	}
+	_, ok := f.boxes[project]
+	return ok, nil
+}
+
+// Status reports a box's state
+func (f *Fake) Status(ctx context.Context, project string) (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.Errors["Status"]; err != nil {
+		return "", err
+	}
+	box, ok := f.boxes[project]
+	switch {
+	case !ok:
+		return StatusNotCreated, nil
+	case box.Running:
+		return StatusRunning, nil
+	default:
+		return StatusStopped, nil
+	}
+}
+
+// Statuses reports every box, keyed by instance name
+func (f *Fake) Statuses(ctx context.Context) (map[string]Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "Statuses", ""); err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]Status, len(f.boxes))
+	for project, box := range f.boxes {
+		status := StatusStopped
+		if box.Running {
+			status = StatusRunning
+		}
+		statuses[f.InstanceName(project)] = status
+	}
+	return statuses, nil
+}
+
+// Shell runs ShellFunc, if set, against a running box
+func (f *Fake) Shell(ctx context.Context, project string) error {
+	f.mu.Lock()
+	if err := f.record(ctx, "Shell", project); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	box, err := f.box(project)
+	if err == nil && !box.Running {
+		err = fmt.Errorf("instance %q is not running", f.InstanceName(project))
+	}
+	handler := f.ShellFunc
+	f.mu.Unlock()
+
+	if err != nil || handler == nil {
+		return err
+	}
+	return handler(project)
+}
+
+// Exec runs ExecFunc, if set, against a running box
+func (f *Fake) Exec(ctx context.Context, project string, command []string, opts ExecOptions) error {
+	f.mu.Lock()
+	if err := f.record(ctx, "Exec", project); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	box, err := f.box(project)
+	if err == nil && !box.Running {
+		err = fmt.Errorf("instance %q is not running", f.InstanceName(project))
+	}
+	handler := f.ExecFunc
+	f.mu.Unlock()
+
+	if err != nil || handler == nil {
+		return err
+	}
+	return handler(project, command, opts)
+}
+
+// Provision runs ProvisionFunc, if set, against a running box
+func (f *Fake) Provision(ctx context.Context, spec Spec, sections []string, out io.Writer) error {
+	f.mu.Lock()
+	if err := f.record(ctx, "Provision", spec.Name); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	box, err := f.box(spec.Name)
+	if err == nil && !box.Running {
+		err = fmt.Errorf("instance %q is not running", f.InstanceName(spec.Name))
+	}
+	handler := f.ProvisionFunc
+	f.mu.Unlock()
+
+	if err != nil || handler == nil {
+		return err
+	}
+	return handler(spec, sections, out)
+}
+
+// Plan compares spec.Config with the config the box was created (or last
+// resized) with
+func (f *Fake) Plan(ctx context.Context, spec Spec) ([]Change, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "Plan", spec.Name); err != nil {
+		return nil, err
+	}
+	box, err := f.box(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	return planVM(fakeVM(box.Spec.Config), fakeVM(spec.Config))
+}
+
+// Resize updates a stopped box's config with res
+func (f *Fake) Resize(ctx context.Context, spec Spec, res Resources) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "Resize", spec.Name); err != nil {
+		return err
+	}
+	box, err := f.stoppedBox(spec.Name)
+	if err != nil {
+		return err
+	}
+	cfg := *box.Spec.Config
+	if res.CPUs > 0 {
+		cfg.VM.CPUs = res.CPUs
+	}
+	if res.Memory != "" {
+		cfg.VM.Memory = res.Memory
+	}
+	if res.Disk != "" {
+		cfg.VM.Disk = res.Disk
+	}
+	box.Spec.Config = &cfg
+	return nil
+}
+
+// fakeVM stands in for a box's template
+func fakeVM(cfg *config.Config) lima.TemplateVM {
+	vm := lima.TemplateVM{
+		Type:    cfg.VM.Type,
+		Arch:    cfg.VM.Arch,
+		Rosetta: cfg.VM.Rosetta != nil && *cfg.VM.Rosetta,
+		CPUs:    cfg.VM.CPUs,
+		Memory:  cfg.VM.Memory,
+		Disk:    cfg.VM.Disk,
+	}
+	// Like a real template, the type and arch are always recorded
+	if vm.Type == "" {
+		vm.Type = "auto"
+	}
+	if vm.Arch == "" {
+		vm.Arch = "auto"
+	}
+	for _, img := range cfg.VM.Images {
+		vm.Images = append(vm.Images, img.Location)
+	}
+	for _, m := range cfg.Mounts {
+		mode := "ro"
+		if m.Writable != nil && *m.Writable {
+			mode = "rw"
+		}
+		vm.Mounts = append(vm.Mounts, fmt.Sprintf("%s -> %s (%s)", m.Host, m.Guest, mode))
+	}
+	return vm
+}
+
+// CopyTo stores a host file's content at guestPath
+func (f *Fake) CopyTo(ctx context.Context, project, hostPath, guestPath string) error {
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "CopyTo", project); err != nil {
+		return err
+	}
+	box, err := f.box(project)
+	if err != nil {
+		return err
+	}
+	box.Files[guestPath] = data
+	return nil
+}
+
+// CopyFrom writes a stored guest file to hostPath
+func (f *Fake) CopyFrom(ctx context.Context, project, guestPath, hostPath string) error {
+	f.mu.Lock()
+	if err := f.record(ctx, "CopyFrom", project); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	box, err := f.box(project)
+	var data []byte
+	var ok bool
+	if err == nil {
+		data, ok = box.Files[guestPath]
+	}
+	f.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s: no such file in %s", guestPath, f.InstanceName(project))
+	}
+	return os.WriteFile(hostPath, data, 0644)
+}
+
+// InstallCA records the proxy CA the box trusts
+func (f *Fake) InstallCA(ctx context.Context, project string, certPEM []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "InstallCA", project); err != nil {
+		return err
+	}
+	box, err := f.box(project)
+	if err != nil {
+		return err
+	}
+	box.CACert = append([]byte(nil), certPEM...)
+	return nil
+}
+
+// InjectSecrets replaces a running box's secrets
+func (f *Fake) InjectSecrets(ctx context.Context, project string, secretEnv map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "InjectSecrets", project); err != nil {
+		return err
+	}
+	box, err := f.box(project)
+	if err != nil {
+		return err
+	}
+	box.Secrets = make(map[string]string, len(secretEnv))
+	for k, v := range secretEnv {
+		if v != "" {
+			box.Secrets[k] = v
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot writes a stopped box's files to dir/fake.json
+func (f *Fake) SaveSnapshot(ctx context.Context, project, dir string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "SaveSnapshot", project); err != nil {
+		return err
+	}
+	box, err := f.stoppedBox(project)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(box.Files)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "fake.json"), data, 0600)
+}
+
+// RestoreSnapshot replaces a stopped box's files with dir/fake.json
+func (f *Fake) RestoreSnapshot(ctx context.Context, project, dir string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "RestoreSnapshot", project); err != nil {
+		return err
+	}
+	box, err := f.stoppedBox(project)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "fake.json"))
+	if err != nil {
+		return err
+	}
+	files := make(map[string][]byte)
+	if err := json.Unmarshal(data, &files); err != nil {
+		return err
+	}
+	box.Files = files
+	return nil
+}
+
+// stoppedBox returns an existing box that isn't running
+func (f *Fake) stoppedBox(project string) (*FakeBox, error) {
+	box, err := f.box(project)
+	if err != nil {
+		return nil, err
+	}
+	if box.Running {
+		return nil, fmt.Errorf("instance %q is running; stop it first", f.InstanceName(project))
+	}
+	return box, nil
+}
+
+// WipeSecrets clears a box's secrets
+func (f *Fake) WipeSecrets(ctx context.Context, project string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.record(ctx, "WipeSecrets", project); err != nil {
+		return err
+	}
+	box, err := f.box(project)
+	if err != nil {
+		return err
+	}
+	box.Secrets = make(map[string]string)
+	return nil
+}
@@ -0,0 +1,268 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/davidsenack/agentbox/internal/config"
+	"github.com/davidsenack/agentbox/internal/lima"
+	"github.com/davidsenack/agentbox/internal/secrets"
+)
+
+func init() {
+	Register("lima", NewLima)
+}
+
+// Lima runs boxes in Lima VMs
+type Lima struct {
+	mgr *lima.Manager
+}
+
+// NewLima creates the Lima runtime, filtering limactl's environment with
+// the project's secrets.env and applying the lima section
+// AGENTBOX_LIMACTL overrides lima.limactl
+func NewLima(cfg *config.Config) (Runtime, error) {
+	filter, err := secrets.NewEnvFilter(cfg.Secrets.Env)
+	if err != nil {
+		return nil, err
+	}
+	timeouts, err := lima.ParseTimeouts(cfg.Lima.Timeouts)
+	if err != nil {
+		return nil, err
+	}
+	limactl := cfg.Lima.Limactl
+	if env := os.Getenv("AGENTBOX_LIMACTL"); env != "" {
+		limactl = env
+	}
+
+	mgr := lima.NewManager()
+	mgr.SetEnvFilter(filter)
+	mgr.SetLimactl(limactl)
+	mgr.SetTimeouts(timeouts)
+	return &Lima{mgr: mgr}, nil
+}
+
+// Name returns "lima"
+func (l *Lima) Name() string {
+	return "lima"
+}
+
+// InstanceName returns the Lima VM name for a project
+func (l *Lima) InstanceName(project string) string {
+	return lima.VMName(project)
+}
+
+// Create writes the Lima template to .agentbox/lima.yaml and creates the VM
+// Local images are checked against their digests first
+func (l *Lima) Create(ctx context.Context, spec Spec) error {
+	var template string
+	var err error
+	if spec.RepoURL != "" {
+		template, err = lima.GenerateTemplateGasTown(spec.Config, spec.ProjectDir, spec.Name, spec.RepoURL)
+	} else {
+		template, err = lima.GenerateTemplate(spec.Config, spec.ProjectDir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate Lima template: %w", err)
+	}
+
+	// Lima checks digests as it downloads; check local images up front so
+	// a tampered one fails before any VM is created
+	vm, err := lima.ResolveVMSettings(spec.Config.VM)
+	if err != nil {
+		return err
+	}
+	images, err := lima.ResolveImages(spec.Config.VM, spec.ProjectDir, vm.Arch)
+	if err != nil {
+		return err
+	}
+	if err := lima.VerifyImages(images); err != nil {
+		return err
+	}
+	if lima.UsesFallback(spec.Config.VM) {
+		fmt.Fprintf(os.Stderr, "Warning: vm.image_policy is fallback: if none of vm.images boots, %s boots an unpinned built-in image\n", l.InstanceName(spec.Name))
+	}
+
+	templatePath := filepath.Join(spec.ProjectDir, ".agentbox", "lima.yaml")
+	if err := os.WriteFile(templatePath, []byte(template), 0600); err != nil {
+		return fmt.Errorf("failed to write Lima template: %w", err)
+	}
+
+	if err := l.mgr.Create(ctx, l.InstanceName(spec.Name), templatePath); err != nil {
+		return err
+	}
+	return lima.WriteProvisionHash(filepath.Join(spec.ProjectDir, ".agentbox"), spec.Config.Provision)
+}
+
+// Start boots the VM
+func (l *Lima) Start(ctx context.Context, project string) error {
+	return l.mgr.Start(ctx, l.InstanceName(project))
+}
+
+// Stop shuts the VM down
+func (l *Lima) Stop(ctx context.Context, project string) error {
+	return l.mgr.Stop(ctx, l.InstanceName(project))
+}
+
+// Delete destroys the VM and its disk
+func (l *Lima) Delete(ctx context.Context, project string) error {
+	return l.mgr.Delete(ctx, l.InstanceName(project))
+}
+
+// Exists checks if the VM exists
+func (l *Lima) Exists(ctx context.Context, project string) (bool, error) {
+	return l.mgr.Exists(ctx, l.InstanceName(project))
+}
+
+// Status reports whether the VM exists and is running
+func (l *Lima) Status(ctx context.Context, project string) (Status, error) {
+	inst, err := l.mgr.Inspect(ctx, l.InstanceName(project))
+	if err != nil {
+		return "", err
+	}
+	return instanceStatus(inst), nil
+}
+
+// Statuses reports every Lima VM from one listing
+func (l *Lima) Statuses(ctx context.Context) (map[string]Status, error) {
+	instances, err := l.mgr.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]Status, len(instances))
+	for i := range instances {
+		statuses[instances[i].Name] = instanceStatus(&instances[i])
+	}
+	return statuses, nil
+}
+
+// instanceStatus maps a Lima instance (nil if missing) to a Status
+func instanceStatus(inst *lima.Instance) Status {
+	switch {
+	case inst == nil:
+		return StatusNotCreated
+	case inst.Running():
+		return StatusRunning
+	default:
+		return StatusStopped
+	}
+}
+
+// Shell opens a login shell as the agent user
+func (l *Lima) Shell(ctx context.Context, project string) error {
+	return l.mgr.Shell(ctx, l.InstanceName(project))
+}
+
+// Exec runs a command in the VM as the agent user
+func (l *Lima) Exec(ctx context.Context, project string, command []string, opts ExecOptions) error {
+	stdout := opts.Stdout
+	if f, ok := stdout.(*os.File); ok && !opts.TTY {
+		// limactl asks for a terminal when its stdout is one; a pipe stops it
+		stdout = struct{ io.Writer }{f}
+	}
+	err := l.mgr.Exec(ctx, l.InstanceName(project), opts.Dir, envCommand(command, opts.Env), opts.Stdin, stdout, opts.Stderr)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// CopyTo copies a host path into the VM
+func (l *Lima) CopyTo(ctx context.Context, project, hostPath, guestPath string) error {
+	return l.mgr.Copy(ctx, hostPath, l.InstanceName(project)+":"+guestPath)
+}
+
+// CopyFrom copies a VM path to the host
+func (l *Lima) CopyFrom(ctx context.Context, project, guestPath, hostPath string) error {
+	return l.mgr.Copy(ctx, l.InstanceName(project)+":"+guestPath, hostPath)
+}
+
+// InstallCA adds the proxy's CA to the VM's trust store
+func (l *Lima) InstallCA(ctx context.Context, project string, certPEM []byte) error {
+	return l.mgr.InstallCA(ctx, l.InstanceName(project), certPEM)
+}
+
+// InjectSecrets writes secrets to the VM's root-only secrets dir
+func (l *Lima) InjectSecrets(ctx context.Context, project string, secretEnv map[string]string) error {
+	return l.mgr.InjectSecrets(ctx, l.InstanceName(project), secretEnv)
+}
+
+// WipeSecrets removes all secrets from the VM
+func (l *Lima) WipeSecrets(ctx context.Context, project string) error {
+	return l.mgr.WipeSecrets(ctx, l.InstanceName(project))
+}
+
+// Provision runs the provision script for spec's config in the running VM
+// A run that covers the provision section records its hash, like Create
+func (l *Lima) Provision(ctx context.Context, spec Spec, sections []string, out io.Writer) error {
+	script, err := lima.ProvisionScript(spec.Config, sections)
+	if err != nil {
+		return err
+	}
+
+	vmName := l.InstanceName(spec.Name)
+	running, err := l.mgr.IsRunning(ctx, vmName)
+	if err != nil {
+		return err
+	}
+	if !running {
+		return fmt.Errorf("VM %s is not running", vmName)
+	}
+
+	err = l.mgr.RunScript(ctx, vmName, script, out)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run provision script: %w", err)
+	}
+
+	if len(sections) == 0 || (slices.Contains(sections, lima.SectionPackages) && slices.Contains(sections, lima.SectionSteps)) {
+		return lima.WriteProvisionHash(filepath.Join(spec.ProjectDir, ".agentbox"), spec.Config.Provision)
+	}
+	return nil
+}
+
+// Plan compares the VM's template (.agentbox/lima.yaml) with the one
+// spec.Config generates
+func (l *Lima) Plan(ctx context.Context, spec Spec) ([]Change, error) {
+	data, err := os.ReadFile(filepath.Join(spec.ProjectDir, ".agentbox", "lima.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the VM's Lima template (run 'agentbox reset %s' to regenerate it): %w", spec.Name, err)
+	}
+	current, err := lima.ReadTemplateVM(data)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := lima.DesiredTemplateVM(spec.Config, spec.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+	return planVM(current, desired)
+}
+
+// Resize edits the stopped VM and updates .agentbox/lima.yaml to match
+func (l *Lima) Resize(ctx context.Context, spec Spec, res Resources) error {
+	if err := l.mgr.Resize(ctx, l.InstanceName(spec.Name), res.CPUs, res.Memory, res.Disk); err != nil {
+		return err
+	}
+	return lima.SetTemplateResources(filepath.Join(spec.ProjectDir, ".agentbox", "lima.yaml"), res.CPUs, res.Memory, res.Disk)
+}
+
+// SaveSnapshot copies the stopped VM's disks into dir
+func (l *Lima) SaveSnapshot(ctx context.Context, project, dir string) error {
+	return l.mgr.SaveDisks(ctx, l.InstanceName(project), dir)
+}
+
+// RestoreSnapshot puts saved disks back into the stopped VM
+func (l *Lima) RestoreSnapshot(ctx context.Context, project, dir string) error {
+	return l.mgr.RestoreDisks(ctx, l.InstanceName(project), dir)
+}
@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/lima"
+)
+
+// Change is one difference between agentbox.yaml and a sandbox
+type Change struct {
+	Field   string // agentbox.yaml key (e.g., vm.memory)
+	From    string
+	To      string
+	InPlace bool   // Resize can make it; otherwise it takes a reset
+	Reason  string // Why it takes a reset
+}
+
+// Resources are the sandbox settings Resize changes ("" or 0 keeps one)
+type Resources struct {
+	CPUs   int
+	Memory string
+	Disk   string
+}
+
+// InPlaceResources collects the in-place changes in a plan
+func InPlaceResources(changes []Change) Resources {
+	var res Resources
+	for _, c := range changes {
+		if !c.InPlace {
+			continue
+		}
+		switch c.Field {
+		case "vm.cpus":
+			res.CPUs, _ = strconv.Atoi(c.To)
+		case "vm.memory":
+			res.Memory = c.To
+		case "vm.disk":
+			res.Disk = c.To
+		}
+	}
+	return res
+}
+
+// planVM compares a VM's settings with the ones agentbox.yaml asks for
+// CPUs, memory and disk growth can be changed in place; everything else
+// is fixed when the VM is created
+func planVM(current, desired lima.TemplateVM) ([]Change, error) {
+	var changes []Change
+	reset := func(field, from, to, reason string) {
+		if from != to {
+			changes = append(changes, Change{Field: field, From: from, To: to, Reason: reason})
+		}
+	}
+
+	// Templates from older versions don't record the type or arch: unknown,
+	// not a change
+	if current.Type != "" {
+		reset("vm.type", current.Type, desired.Type, "the VM type is fixed when the VM is created")
+	}
+	if current.Arch != "" {
+		reset("vm.arch", current.Arch, desired.Arch, "the guest architecture is fixed when the VM is created")
+	}
+	reset("vm.rosetta", strconv.FormatBool(current.Rosetta), strconv.FormatBool(desired.Rosetta), "Rosetta is set up when the VM is created")
+
+	if current.CPUs != desired.CPUs {
+		changes = append(changes, Change{Field: "vm.cpus", From: strconv.Itoa(current.CPUs), To: strconv.Itoa(desired.CPUs), InPlace: true})
+	}
+	if current.Memory != desired.Memory {
+		changes = append(changes, Change{Field: "vm.memory", From: current.Memory, To: desired.Memory, InPlace: true})
+	}
+	if current.Disk != desired.Disk {
+		from, err := lima.ParseSize(current.Disk)
+		if err != nil {
+			return nil, err
+		}
+		to, err := lima.ParseSize(desired.Disk)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vm.disk: %w", err)
+		}
+		switch {
+		case to > from:
+			changes = append(changes, Change{Field: "vm.disk", From: current.Disk, To: desired.Disk, InPlace: true})
+		case to < from:
+			changes = append(changes, Change{Field: "vm.disk", From: current.Disk, To: desired.Disk, Reason: "disks can only grow"})
+		}
+	}
+
+	reset("vm.images", strings.Join(current.Images, ", "), strings.Join(desired.Images, ", "), "the image is only used to create the VM")
+	reset("mounts", strings.Join(current.Mounts, ", "), strings.Join(desired.Mounts, ", "), "mounts are set up when the VM is created")
+	return changes, nil
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/lima"
+)
+
+func TestPlanVM(t *testing.T) {
+	current := lima.TemplateVM{Type: "qemu", Arch: "aarch64", CPUs: 4, Memory: "8GiB", Disk: "50GiB"}
+
+	tests := []struct {
+		name    string
+		modify  func(*lima.TemplateVM)
+		field   string
+		inPlace bool
+	}{
+		{name: "cpus", modify: func(vm *lima.TemplateVM) { vm.CPUs = 8 }, field: "vm.cpus", inPlace: true},
+		{name: "memory", modify: func(vm *lima.TemplateVM) { vm.Memory = "16GiB" }, field: "vm.memory", inPlace: true},
+		{name: "disk grows", modify: func(vm *lima.TemplateVM) { vm.Disk = "100GiB" }, field: "vm.disk", inPlace: true},
+		{name: "disk shrinks", modify: func(vm *lima.TemplateVM) { vm.Disk = "20GiB" }, field: "vm.disk"},
+		{name: "type", modify: func(vm *lima.TemplateVM) { vm.Type = "vz" }, field: "vm.type"},
+		{name: "mounts", modify: func(vm *lima.TemplateVM) { vm.Mounts = []string{"/data -> /data (ro)"} }, field: "mounts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := current
+			tt.modify(&desired)
+			changes, err := planVM(current, desired)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(changes) != 1 || changes[0].Field != tt.field || changes[0].InPlace != tt.inPlace {
+				t.Fatalf("unexpected plan: %+v", changes)
+			}
+			if !tt.inPlace && changes[0].Reason == "" {
+				t.Error("a change that needs a reset should say why")
+			}
+		})
+	}
+
+	// The same size written differently is no change
+	desired := current
+	desired.Disk = "50G"
+	if changes, _ := planVM(current, desired); len(changes) != 0 {
+		t.Errorf("equal disk sizes should not be a change: %+v", changes)
+	}
+
+	// A template from before arch was recorded has no arch: unknown, not a change
+	old := current
+	old.Arch = ""
+	desired = current
+	if changes, _ := planVM(old, desired); len(changes) != 0 {
+		t.Errorf("a missing arch should not be a change: %+v", changes)
+	}
+}
+
+func TestInPlaceResources(t *testing.T) {
+	res := InPlaceResources([]Change{
+		{Field: "vm.cpus", To: "8", InPlace: true},
+		{Field: "vm.disk", To: "20GiB"},
+		{Field: "vm.memory", To: "16GiB", InPlace: true},
+	})
+	if res != (Resources{CPUs: 8, Memory: "16GiB"}) {
+		t.Errorf("unexpected resources: %+v", res)
+	}
+}
@@ -0,0 +1,167 @@
+// Package runtime abstracts the sandbox backend (Lima VMs, test fakes)
+// behind one interface selected by config.Runtime
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// DefaultRuntime is used when agentbox.yaml doesn't set runtime
+const DefaultRuntime = "lima"
+
+// Status is the state of a box's sandbox
+type Status string
+
+const (
+	StatusRunning    Status = "running"
+	StatusStopped    Status = "stopped"
+	StatusNotCreated Status = "not created"
+)
+
+// Spec describes the sandbox to create for a project
+type Spec struct {
+	Name       string         // Project name
+	ProjectDir string         // Absolute path of the project directory
+	Config     *config.Config // Project configuration
+	RepoURL    string         // Gas Town repo to build a rig from on first boot (optional)
+}
+
+// ExecOptions configures a non-interactive command
+type ExecOptions struct {
+	Dir    string   // Guest working directory ("" uses the agent's home)
+	Env    []string // Extra KEY=VALUE vars for the command
+	TTY    bool     // Allocate a terminal (needs Stdin/Stdout to be one)
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// envCommand prefixes command with env(1) to set opts.Env in the guest
+func envCommand(command []string, env []string) []string {
+	if len(env) == 0 {
+		return command
+	}
+	wrapped := append([]string{"env"}, env...)
+	return append(wrapped, command...)
+}
+
+// ExitError reports a command that ran but exited non-zero
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Runtime creates and drives the sandboxes boxes run in
+// Methods take the project name; InstanceName maps it to the backend's name
+type Runtime interface {
+	// Name returns the runtime's config.Runtime value
+	Name() string
+	// InstanceName returns the backend name for a project (e.g., the VM name)
+	InstanceName(project string) string
+
+	Create(ctx context.Context, spec Spec) error
+	Start(ctx context.Context, project string) error
+	Stop(ctx context.Context, project string) error
+	Delete(ctx context.Context, project string) error
+	Exists(ctx context.Context, project string) (bool, error)
+	Status(ctx context.Context, project string) (Status, error)
+
+	// Shell opens an interactive shell as the agent user
+	Shell(ctx context.Context, project string) error
+	// Exec runs a command as the agent user; a non-zero exit is an *ExitError
+	Exec(ctx context.Context, project string, command []string, opts ExecOptions) error
+	// CopyTo copies a host path into the sandbox
+	CopyTo(ctx context.Context, project, hostPath, guestPath string) error
+	// CopyFrom copies a sandbox path to the host
+	CopyFrom(ctx context.Context, project, guestPath, hostPath string) error
+
+	// InstallCA makes the sandbox trust the proxy's CA certificate (PEM), so
+	// the proxy can intercept TLS for hosts it injects secrets into
+	InstallCA(ctx context.Context, project string, certPEM []byte) error
+
+	// InjectSecrets replaces the secrets available to the agent wrappers
+	InjectSecrets(ctx context.Context, project string, secretEnv map[string]string) error
+	// WipeSecrets removes every injected secret
+	WipeSecrets(ctx context.Context, project string) error
+
+	// Provision re-runs provisioning from spec.Config in the running sandbox,
+	// limited to sections (all when empty); output goes to out and a
+	// non-zero exit is an *ExitError
+	Provision(ctx context.Context, spec Spec, sections []string, out io.Writer) error
+
+	// Plan lists how spec.Config differs from the sandbox as it was created
+	Plan(ctx context.Context, spec Spec) ([]Change, error)
+	// Resize changes the stopped sandbox's CPUs, memory and disk
+	Resize(ctx context.Context, spec Spec, res Resources) error
+
+	// SaveSnapshot copies the stopped sandbox's guest state into dir
+	SaveSnapshot(ctx context.Context, project, dir string) error
+	// RestoreSnapshot replaces the stopped sandbox's guest state with a
+	// state saved by SaveSnapshot
+	RestoreSnapshot(ctx context.Context, project, dir string) error
+}
+
+// Lister is implemented by runtimes that can report many sandboxes with a
+// single query, so listing projects doesn't query once per project
+type Lister interface {
+	// Statuses maps instance names to their status; missing ones aren't created
+	Statuses(ctx context.Context) (map[string]Status, error)
+}
+
+// Factory builds a runtime for a project's configuration
+type Factory func(cfg *config.Config) (Runtime, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a runtime available under name, replacing any existing one
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Names returns the registered runtime names, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the runtime selected by cfg.Runtime
+// AGENTBOX_RUNTIME overrides the config (e.g., to run the CLI against a fake)
+func New(cfg *config.Config) (Runtime, error) {
+	name := cfg.Runtime
+	if env := os.Getenv("AGENTBOX_RUNTIME"); env != "" {
+		name = env
+	}
+	if name == "" {
+		name = DefaultRuntime
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime %q (available: %v)", name, Names())
+	}
+	return factory(cfg)
+}
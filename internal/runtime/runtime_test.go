@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestNewSelectsRuntime(t *testing.T) {
+	t.Setenv("AGENTBOX_RUNTIME", "")
+	fake := NewFake()
+	Register("test-fake", fake.Factory)
+
+	tests := []struct {
+		runtime string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{runtime: "", want: "lima"},
+		{runtime: "lima", want: "lima"},
+		{runtime: "test-fake", want: "fake"},
+		{runtime: "lima", env: "test-fake", want: "fake"},
+		{runtime: "nope", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("AGENTBOX_RUNTIME", tt.env)
+		cfg := config.DefaultConfig()
+		cfg.Runtime = tt.runtime
+
+		rt, err := New(cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q) succeeded, want error", tt.runtime)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("New(%q): %v", tt.runtime, err)
+		}
+		if rt.Name() != tt.want {
+			t.Errorf("New(%q) with env %q = %s, want %s", tt.runtime, tt.env, rt.Name(), tt.want)
+		}
+	}
+}
+
+func TestFakeLifecycle(t *testing.T) {
+	f := NewFake()
+
+	if st, _ := f.Status(t.Context(), "demo"); st != StatusNotCreated {
+		t.Errorf("status before create = %s", st)
+	}
+	if err := f.Create(t.Context(), Spec{Name: "demo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Shell(t.Context(), "demo"); err == nil {
+		t.Error("shell in a stopped box should fail")
+	}
+
+	f.Start(t.Context(), "demo")
+	f.InjectSecrets(t.Context(), "demo", map[string]string{"TOKEN": "value", "EMPTY": ""})
+	if box, _ := f.Box("demo"); len(box.Secrets) != 1 {
+		t.Errorf("secrets = %v", box.Secrets)
+	}
+
+	f.Errors["Stop"] = errors.New("boom")
+	if err := f.Stop(t.Context(), "demo"); err == nil {
+		t.Error("expected injected Stop error")
+	}
+	delete(f.Errors, "Stop")
+
+	f.Stop(t.Context(), "demo")
+	if box, _ := f.Box("demo"); box.Running || len(box.Secrets) != 0 {
+		t.Errorf("stopped box = %+v", box)
+	}
+}
+
+func TestFakeCopyAndExec(t *testing.T) {
+	f := NewFake()
+	f.Create(t.Context(), Spec{Name: "demo"})
+	f.Start(t.Context(), "demo")
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "in.txt")
+	os.WriteFile(src, []byte("hello"), 0644)
+
+	if err := f.CopyTo(t.Context(), "demo", src, "/workspace/in.txt"); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "out.txt")
+	if err := f.CopyFrom(t.Context(), "demo", "/workspace/in.txt", dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("copied %q", data)
+	}
+
+	f.ExecFunc = func(project string, command []string, opts ExecOptions) error {
+		return &ExitError{Code: 3}
+	}
+	var exitErr *ExitError
+	if err := f.Exec(t.Context(), "demo", []string{"false"}, ExecOptions{}); !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("Exec error = %v, want exit status 3", err)
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	tests := []struct {
+		env  []string
+		want string
+	}{
+		{nil, "make test"},
+		{[]string{"CI=1"}, "env CI=1 make test"},
+		{[]string{"CI=1", "GOFLAGS=-count=1"}, "env CI=1 GOFLAGS=-count=1 make test"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(envCommand([]string{"make", "test"}, tt.env), " ")
+		if got != tt.want {
+			t.Errorf("envCommand(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
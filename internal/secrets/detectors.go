@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Confidence describes how likely a detector match is a real secret
+type Confidence string
+
+const (
+	ConfidenceHigh   Confidence = "high"
+	ConfidenceMedium Confidence = "medium"
+	ConfidenceLow    Confidence = "low"
+)
+
+// Detector is a named secret pattern
+// Matches are redacted as [REDACTED:<name>]
+type Detector struct {
+	Name       string
+	Pattern    *regexp.Regexp
+	Group      int // Submatch holding the secret (0 = whole match)
+	Confidence Confidence
+
+	// validate filters candidate matches (e.g., by entropy)
+	validate func(string) bool
+}
+
+// builtinDetectors is the library of known secret formats
+var builtinDetectors = []*Detector{
+	{
+		Name:       "aws_access_key",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	{
+		Name:       "aws_secret_key",
+		Confidence: ConfidenceMedium,
+		Pattern:    regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|sk).{0,20}?['"\s:=]+([A-Za-z0-9/+=]{40})\b`),
+		Group:      1,
+	},
+	{
+		Name:       "github_pat",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`\b(?:ghp_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`),
+	},
+	{
+		Name:       "github_oauth",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`\bgho_[A-Za-z0-9]{36}\b`),
+	},
+	{
+		Name:       "slack_token",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	},
+	{
+		Name:       "google_api_key",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),
+	},
+	{
+		Name:       "stripe_key",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`\b(?:sk|rk)_(?:live|test)_[0-9A-Za-z]{16,}\b`),
+	},
+	{
+		Name:       "jwt",
+		Confidence: ConfidenceMedium,
+		Pattern:    regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`),
+	},
+	{
+		Name:       "private_key",
+		Confidence: ConfidenceHigh,
+		Pattern:    regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+	},
+	{
+		Name:       "generic_secret",
+		Confidence: ConfidenceLow,
+		Pattern:    regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:_KEY|_TOKEN|_SECRET)\b\s*[:=]\s*["']?([A-Za-z0-9/+=_.-]{16,})`),
+		Group:      1,
+		validate:   highEntropy,
+	},
+}
+
+// BuiltinDetectors returns all built-in detectors
+func BuiltinDetectors() []*Detector {
+	return append([]*Detector(nil), builtinDetectors...)
+}
+
+// DetectorNames returns the names of all built-in detectors, sorted
+func DetectorNames() []string {
+	names := make([]string, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnabledDetectors returns the built-in detectors left enabled by toggles
+// Detectors are on by default; set a name to false to disable it
+func EnabledDetectors(toggles map[string]bool) ([]*Detector, error) {
+	known := make(map[string]bool, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		known[d.Name] = true
+	}
+	for name := range toggles {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown secret detector %q (available: %s)", name, strings.Join(DetectorNames(), ", "))
+		}
+	}
+
+	enabled := make([]*Detector, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		if on, ok := toggles[d.Name]; ok && !on {
+			continue
+		}
+		enabled = append(enabled, d)
+	}
+	return enabled, nil
+}
+
+// find returns the [start, end) ranges of secrets matched by the detector
+func (d *Detector) find(s string) [][2]int {
+	var spans [][2]int
+	for _, loc := range d.Pattern.FindAllStringSubmatchIndex(s, -1) {
+		start, end := loc[2*d.Group], loc[2*d.Group+1]
+		if start < 0 {
+			continue
+		}
+		if d.validate != nil && !d.validate(s[start:end]) {
+			continue
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+// redact replaces every match with [REDACTED:<name>]
+func (d *Detector) redact(s string) string {
+	spans := d.find(s)
+	if len(spans) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		b.WriteString(s[last:sp[0]])
+		b.WriteString("[REDACTED:" + d.Name + "]")
+		last = sp[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// highEntropy reports whether s looks random enough to be a secret
+func highEntropy(s string) bool {
+	return shannonEntropy(s) >= 3.5
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	n := float64(len([]rune(s)))
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,206 @@
+package secrets
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+// Env filter modes
+const (
+	EnvModeDenylist  = "denylist"
+	EnvModeAllowlist = "allowlist"
+)
+
+// builtinEnvBlock matches host vars that commonly hold credentials
+var builtinEnvBlock = []string{
+	"AWS_*",
+	"GOOGLE_*",
+	"AZURE_*",
+	"SSH_*",
+	"GPG_*",
+	"HOMEBREW_*",
+	"ANTHROPIC_*",
+	"OPENAI_*",
+	"*_TOKEN",
+	"*_SECRET",
+	"*_KEY",
+	"*_PASSWORD",
+	"*_PASSWD",
+	"*_CREDENTIALS",
+	"*_API_KEY",
+	"*_AUTH",
+	"*_DSN",
+	"*DATABASE_URL",
+}
+
+// builtinEnvAllow is what allowlist mode passes without configuration:
+// just enough for limactl and a usable terminal
+var builtinEnvAllow = []string{
+	"PATH",
+	"HOME",
+	"USER",
+	"LOGNAME",
+	"SHELL",
+	"TERM",
+	"COLORTERM",
+	"LANG",
+	"LC_*",
+	"TZ",
+	"TMPDIR",
+	"LIMA_*",
+}
+
+// EnvDecision explains whether a host env var is propagated
+type EnvDecision struct {
+	Name   string `json:"name"`
+	Pass   bool   `json:"pass"`
+	Reason string `json:"reason"`
+}
+
+// EnvFilter decides which host env vars are propagated to limactl
+// Precedence: secrets.env.block, secrets.env.allow, built-in block
+// patterns, then the mode (denylist passes the rest; allowlist passes only
+// the built-in essentials)
+type EnvFilter struct {
+	allowlist    bool
+	allow        []envRule
+	block        []envRule
+	builtinBlock []envRule
+	builtinAllow []envRule
+}
+
+// envRule is a glob or "re:" regexp matched against a var name
+type envRule struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// NewEnvFilter creates a filter from secrets.env
+func NewEnvFilter(cfg config.EnvFilterConfig) (*EnvFilter, error) {
+	f := &EnvFilter{}
+
+	switch cfg.Mode {
+	case "", EnvModeDenylist:
+	case EnvModeAllowlist:
+		f.allowlist = true
+	default:
+		return nil, fmt.Errorf("invalid secrets.env.mode %q (use denylist or allowlist)", cfg.Mode)
+	}
+
+	var err error
+	if f.allow, err = compileEnvRules(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("invalid secrets.env.allow: %w", err)
+	}
+	if f.block, err = compileEnvRules(cfg.Block); err != nil {
+		return nil, fmt.Errorf("invalid secrets.env.block: %w", err)
+	}
+	f.builtinBlock, _ = compileEnvRules(builtinEnvBlock)
+	f.builtinAllow, _ = compileEnvRules(builtinEnvAllow)
+	return f, nil
+}
+
+// DefaultEnvFilter returns the denylist filter with only built-in patterns
+func DefaultEnvFilter() *EnvFilter {
+	f, _ := NewEnvFilter(config.EnvFilterConfig{})
+	return f
+}
+
+// Decide reports whether a var passes and which rule decided it
+func (f *EnvFilter) Decide(name string) EnvDecision {
+	d := EnvDecision{Name: name}
+
+	if p, ok := matchEnvRules(f.block, name); ok {
+		d.Reason = "blocked by secrets.env.block " + p
+		return d
+	}
+	if p, ok := matchEnvRules(f.allow, name); ok {
+		d.Pass = true
+		d.Reason = "allowed by secrets.env.allow " + p
+		return d
+	}
+	if p, ok := matchEnvRules(f.builtinBlock, name); ok {
+		d.Reason = "blocked by built-in pattern " + p
+		return d
+	}
+
+	if !f.allowlist {
+		d.Pass = true
+		d.Reason = "no block pattern matched"
+		return d
+	}
+	if p, ok := matchEnvRules(f.builtinAllow, name); ok {
+		d.Pass = true
+		d.Reason = "allowed by built-in pattern " + p
+		return d
+	}
+	d.Reason = "not in allowlist"
+	return d
+}
+
+// Filter returns the KEY=VALUE entries that pass
+func (f *EnvFilter) Filter(env []string) []string {
+	result := make([]string, 0, len(env))
+	for _, e := range env {
+		name, _, ok := strings.Cut(e, "=")
+		if !ok {
+			continue
+		}
+		if f.Decide(name).Pass {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Explain returns a decision for every var in env, sorted by name
+func (f *EnvFilter) Explain(env []string) []EnvDecision {
+	decisions := make([]EnvDecision, 0, len(env))
+	for _, e := range env {
+		name, _, ok := strings.Cut(e, "=")
+		if !ok || name == "" {
+			continue
+		}
+		decisions = append(decisions, f.Decide(name))
+	}
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].Name < decisions[j].Name })
+	return decisions
+}
+
+func compileEnvRules(patterns []string) ([]envRule, error) {
+	rules := make([]envRule, 0, len(patterns))
+	for _, p := range patterns {
+		rule := envRule{pattern: p}
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", p, err)
+			}
+			rule.re = re
+		} else if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("%q: %w", p, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchEnvRules returns the first pattern matching name
+func matchEnvRules(rules []envRule, name string) (string, bool) {
+	for _, r := range rules {
+		if r.re != nil {
+			if r.re.MatchString(name) {
+				return r.pattern, true
+			}
+			continue
+		}
+		if ok, _ := path.Match(r.pattern, name); ok {
+			return r.pattern, true
+		}
+	}
+	return "", false
+}
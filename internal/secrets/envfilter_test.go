@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+func TestEnvFilterDenylist(t *testing.T) {
+	f, err := NewEnvFilter(config.EnvFilterConfig{
+		Allow: []string{"GIT_SSH_KEY_PATH_HINT", "re:^MY_[A-Z]+_KEY$"},
+		Block: []string{"STAGING_*", "re:^INTERNAL_"},
+	})
+	if err != nil {
+		t.Fatalf("NewEnvFilter: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		pass   bool
+		reason string
+	}{
+		{"PATH", true, "no block pattern"},
+		{"EDITOR", true, "no block pattern"},
+		{"AWS_PROFILE", false, "built-in pattern AWS_*"},
+		{"GITHUB_TOKEN", false, "built-in pattern *_TOKEN"},
+		{"NPM_AUTH", false, "built-in pattern *_AUTH"},
+		{"DATABASE_URL", false, "built-in pattern *DATABASE_URL"},
+		{"STAGING_HOST", false, "secrets.env.block STAGING_*"},
+		{"INTERNAL_ENDPOINT", false, "secrets.env.block re:^INTERNAL_"},
+		{"GIT_SSH_KEY_PATH_HINT", true, "secrets.env.allow GIT_SSH_KEY_PATH_HINT"},
+		{"MY_PUBLIC_KEY", true, "secrets.env.allow re:^MY_[A-Z]+_KEY$"},
+	}
+	for _, tt := range tests {
+		d := f.Decide(tt.name)
+		if d.Pass != tt.pass || !strings.Contains(d.Reason, tt.reason) {
+			t.Errorf("Decide(%q) = %v %q, want %v containing %q", tt.name, d.Pass, d.Reason, tt.pass, tt.reason)
+		}
+	}
+}
+
+func TestEnvFilterAllowlist(t *testing.T) {
+	f, err := NewEnvFilter(config.EnvFilterConfig{
+		Mode:  EnvModeAllowlist,
+		Allow: []string{"EDITOR", "GOPROXY"},
+		Block: []string{"LC_SECRET"},
+	})
+	if err != nil {
+		t.Fatalf("NewEnvFilter: %v", err)
+	}
+
+	env := []string{
+		"PATH=/usr/bin",
+		"LC_ALL=C",
+		"LC_SECRET=x",
+		"EDITOR=vim",
+		"GOPROXY=direct",
+		"DATABASE_URL=postgres://u:p@db/app",
+		"RANDOM_VAR=1",
+		"malformed",
+	}
+	got := f.Filter(env)
+	want := []string{"PATH=/usr/bin", "LC_ALL=C", "EDITOR=vim", "GOPROXY=direct"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Filter = %v, want %v", got, want)
+	}
+
+	if d := f.Decide("RANDOM_VAR"); d.Pass || d.Reason != "not in allowlist" {
+		t.Errorf("Decide(RANDOM_VAR) = %+v", d)
+	}
+}
+
+func TestEnvFilterExplainSorted(t *testing.T) {
+	decisions := DefaultEnvFilter().Explain([]string{"ZED=1", "AWS_REGION=us-east-1", "HOME=/root"})
+	if len(decisions) != 3 || decisions[0].Name != "AWS_REGION" || decisions[2].Name != "ZED" {
+		t.Fatalf("Explain = %+v", decisions)
+	}
+	if decisions[0].Pass || !decisions[1].Pass {
+		t.Errorf("unexpected decisions: %+v", decisions)
+	}
+}
+
+func TestNewEnvFilterErrors(t *testing.T) {
+	tests := []config.EnvFilterConfig{
+		{Mode: "strict"},
+		{Block: []string{"re:("}},
+		{Allow: []string{"[A-"}},
+	}
+	for _, cfg := range tests {
+		if _, err := NewEnvFilter(cfg); err == nil {
+			t.Errorf("NewEnvFilter(%+v) succeeded, want error", cfg)
+		}
+	}
+}
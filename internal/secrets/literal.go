@@ -0,0 +1,216 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// minLiteralLength is the shortest value redacted literally
+// Shorter values are too likely to show up in logs by chance
+const minLiteralLength = 6
+
+// literalMatcher finds many literal strings in one pass (Aho-Corasick)
+type literalMatcher struct {
+	nodes  []acNode
+	labels []string // pattern index -> label
+	sizes  []int    // pattern index -> length
+}
+
+type acNode struct {
+	next map[byte]int
+	fail int
+	out  []int // patterns ending at this node (including via fail links)
+}
+
+// literalMatch is a located literal
+type literalMatch struct {
+	start, end int
+	label      string
+}
+
+// newLiteralMatcher builds a matcher for value -> label pairs
+func newLiteralMatcher(values map[string]string) *literalMatcher {
+	m := &literalMatcher{nodes: []acNode{{next: map[byte]int{}}}}
+
+	// Sort for deterministic pattern order
+	keys := make([]string, 0, len(values))
+	for v := range values {
+		keys = append(keys, v)
+	}
+	sort.Strings(keys)
+
+	for _, v := range keys {
+		cur := 0
+		for i := 0; i < len(v); i++ {
+			nxt, ok := m.nodes[cur].next[v[i]]
+			if !ok {
+				m.nodes = append(m.nodes, acNode{next: map[byte]int{}})
+				nxt = len(m.nodes) - 1
+				m.nodes[cur].next[v[i]] = nxt
+			}
+			cur = nxt
+		}
+		m.nodes[cur].out = append(m.nodes[cur].out, len(m.labels))
+		m.labels = append(m.labels, values[v])
+		m.sizes = append(m.sizes, len(v))
+	}
+
+	// Breadth-first pass to build failure links
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for c, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for f != 0 {
+				if _, ok := m.nodes[f].next[c]; ok {
+					break
+				}
+				f = m.nodes[f].fail
+			}
+			if nxt, ok := m.nodes[f].next[c]; ok && nxt != child {
+				m.nodes[child].fail = nxt
+			}
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[m.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+
+	return m
+}
+
+// find returns non-overlapping matches, preferring the leftmost and longest
+func (m *literalMatcher) find(s string) []literalMatch {
+	if m == nil || len(m.labels) == 0 {
+		return nil
+	}
+
+	var all []literalMatch
+	cur := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		for cur != 0 {
+			if _, ok := m.nodes[cur].next[c]; ok {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		if nxt, ok := m.nodes[cur].next[c]; ok {
+			cur = nxt
+		}
+		for _, p := range m.nodes[cur].out {
+			all = append(all, literalMatch{start: i + 1 - m.sizes[p], end: i + 1, label: m.labels[p]})
+		}
+	}
+
+	sort.Slice(all, func(a, b int) bool {
+		if all[a].start != all[b].start {
+			return all[a].start < all[b].start
+		}
+		return all[a].end > all[b].end
+	})
+
+	matches := all[:0]
+	last := 0
+	for _, lm := range all {
+		if lm.start < last {
+			continue
+		}
+		matches = append(matches, lm)
+		last = lm.end
+	}
+	return matches
+}
+
+// redact replaces every literal with [REDACTED:<label>]
+func (m *literalMatcher) redact(s string) string {
+	if m == nil {
+		return s
+	}
+	matches := m.find(s)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, lm := range matches {
+		b.WriteString(s[last:lm.start])
+		b.WriteString("[REDACTED:" + lm.label + "]")
+		last = lm.end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// literalEncodings returns value plus the forms it commonly takes in logs:
+// base64 (standard and URL alphabets, at any alignment inside a larger
+// encoded blob such as a Basic auth header) and URL encoding
+func literalEncodings(value string) []string {
+	forms := []string{value, url.QueryEscape(value), url.PathEscape(value)}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		for offset := 0; offset < 3; offset++ {
+			data := strings.Repeat("\x00", offset) + value
+			encoded := enc.EncodeToString([]byte(data))
+
+			// Drop characters that mix in prefix bits or padding
+			start := (offset*8 + 5) / 6
+			end := (len(data) / 3) * 4
+			if offset == 0 {
+				// Unaligned tail is stable when the value ends the blob
+				forms = append(forms, encoded, strings.TrimRight(encoded, "="))
+			}
+			if end > start {
+				forms = append(forms, encoded[start:end])
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(forms))
+	out := forms[:0]
+	for _, f := range forms {
+		if len(f) < minLiteralLength || seen[f] {
+			continue
+		}
+		seen[f] = true
+		out = append(out, f)
+	}
+	return out
+}
+
+// AddLiterals adds exact secret values to redact, keyed by a label such as
+// the env var they came from. Encoded forms of each value are matched too.
+// Values shorter than 6 characters are ignored.
+func (r *Redactor) AddLiterals(values map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	forms := make(map[string]string)
+	for _, existing := range r.literalValues {
+		for _, f := range literalEncodings(existing.value) {
+			forms[f] = existing.label
+		}
+	}
+	for label, value := range values {
+		if len(value) < minLiteralLength {
+			continue
+		}
+		r.literalValues = append(r.literalValues, literalValue{label: label, value: value})
+		for _, f := range literalEncodings(value) {
+			forms[f] = label
+		}
+	}
+	r.literals = newLiteralMatcher(forms)
+}
+
+// literalValue is a secret registered with AddLiterals
+type literalValue struct {
+	label string
+	value string
+}
@@ -0,0 +1,91 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLiteralRedaction(t *testing.T) {
+	r := NewRedactor(nil)
+	r.AddLiterals(map[string]string{
+		"DB_PASSWORD": "hunter2-correct-horse",
+		"SHORT":       "abc",
+	})
+
+	result := r.Redact("connecting with hunter2-correct-horse as abc")
+	if strings.Contains(result, "hunter2-correct-horse") {
+		t.Errorf("literal not redacted: %q", result)
+	}
+	if !strings.Contains(result, "[REDACTED:DB_PASSWORD]") {
+		t.Errorf("expected labeled marker, got %q", result)
+	}
+	if !strings.Contains(result, " abc") {
+		t.Errorf("short values should be ignored, got %q", result)
+	}
+}
+
+func TestLiteralEncodedForms(t *testing.T) {
+	secret := "p@ss/word+with&chars"
+	r := NewRedactor(nil)
+	r.AddLiterals(map[string]string{"TOKEN": secret})
+
+	inputs := []string{
+		"plain " + secret,
+		"b64 " + base64.StdEncoding.EncodeToString([]byte(secret)),
+		"b64url " + base64.RawURLEncoding.EncodeToString([]byte(secret)),
+		"query ?t=" + url.QueryEscape(secret),
+		"path /" + url.PathEscape(secret),
+		// Secret embedded at each alignment inside a larger base64 blob
+		"Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:"+secret)),
+		"Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("me:"+secret)),
+		"Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("bob:"+secret+"!")),
+	}
+
+	for _, input := range inputs {
+		result := r.Redact(input)
+		if !strings.Contains(result, "[REDACTED:TOKEN]") {
+			t.Errorf("expected redaction in %q, got %q", input, result)
+		}
+	}
+}
+
+func TestLiteralOverlappingValues(t *testing.T) {
+	r := NewRedactor(nil)
+	r.AddLiterals(map[string]string{
+		"SHORT_ONE": "secretvalue",
+		"LONG_ONE":  "secretvalue-extended",
+	})
+
+	result := r.Redact("x secretvalue-extended y secretvalue z")
+	if result != "x [REDACTED:LONG_ONE] y [REDACTED:SHORT_ONE] z" {
+		t.Errorf("unexpected result: %q", result)
+	}
+}
+
+func TestLiteralAddIsCumulative(t *testing.T) {
+	r := NewRedactor(nil)
+	r.AddLiterals(map[string]string{"A": "first-secret"})
+	r.AddLiterals(map[string]string{"B": "second-secret"})
+
+	result := r.Redact("first-secret second-secret")
+	if result != "[REDACTED:A] [REDACTED:B]" {
+		t.Errorf("unexpected result: %q", result)
+	}
+}
+
+func TestLiteralStreamedAcrossWrites(t *testing.T) {
+	r := NewRedactor(nil)
+	r.AddLiterals(map[string]string{"DB_PASSWORD": "hunter2-correct-horse"})
+
+	var out strings.Builder
+	w := r.Writer(&out)
+	w.Write([]byte("password is hunter2-cor"))
+	w.Write([]byte("rect-horse\n"))
+	w.Close()
+
+	if strings.Contains(out.String(), "hunter2") {
+		t.Errorf("literal leaked across writes: %q", out.String())
+	}
+}
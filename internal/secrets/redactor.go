@@ -2,11 +2,17 @@ package secrets
 
 import (
 	"regexp"
+	"sync"
 )
 
 // Redactor replaces sensitive patterns in text
 type Redactor struct {
-	patterns []*regexp.Regexp
+	patterns  []*regexp.Regexp
+	detectors []*Detector
+
+	mu            sync.RWMutex // Guards literals, which can grow while in use
+	literals      *literalMatcher
+	literalValues []literalValue
 }
 
 // NewRedactor creates a new redactor with the given patterns
@@ -24,8 +30,21 @@ func NewRedactor(patterns []string) *Redactor {
 	return r
 }
 
+// NewRedactorWithDetectors creates a redactor with custom patterns plus
+// named detectors, whose matches are reported as [REDACTED:<name>]
+func NewRedactorWithDetectors(patterns []string, detectors []*Detector) *Redactor {
+	r := NewRedactor(patterns)
+	r.detectors = detectors
+	return r
+}
+
 // Redact replaces all matches with redaction markers
 func (r *Redactor) Redact(s string) string {
+	// Exact host values first, so they're labeled by where they came from
+	s = r.literalMatcher().redact(s)
+	for _, d := range r.detectors {
+		s = d.redact(s)
+	}
 	for _, re := range r.patterns {
 		s = re.ReplaceAllStringFunc(s, func(match string) string {
 			if len(match) <= 8 {
@@ -37,3 +56,31 @@ func (r *Redactor) Redact(s string) string {
 	}
 	return s
 }
+
+// spans returns the byte ranges matched by any detector or pattern
+func (r *Redactor) spans(s string) [][2]int {
+	var spans [][2]int
+	for _, lm := range r.literalMatcher().find(s) {
+		spans = append(spans, [2]int{lm.start, lm.end})
+	}
+	for _, d := range r.detectors {
+		// Hold back the whole match, not just the secret group, so a
+		// prefix like "API_KEY=" isn't emitted before its value arrives
+		for _, loc := range d.Pattern.FindAllStringIndex(s, -1) {
+			spans = append(spans, [2]int{loc[0], loc[1]})
+		}
+	}
+	for _, re := range r.patterns {
+		for _, loc := range re.FindAllStringIndex(s, -1) {
+			spans = append(spans, [2]int{loc[0], loc[1]})
+		}
+	}
+	return spans
+}
+
+// literalMatcher returns the current literal matcher
+func (r *Redactor) literalMatcher() *literalMatcher {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.literals
+}
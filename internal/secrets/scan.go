@@ -0,0 +1,205 @@
+package secrets
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxScanSize skips files larger than this (5 MiB)
+const defaultMaxScanSize = 5 << 20
+
+// maxPreviewLength caps the redacted line shown for a finding
+const maxPreviewLength = 120
+
+// Finding is a secret found while scanning files
+type Finding struct {
+	Path       string     `json:"path"` // Relative to the scan base
+	Line       int        `json:"line"`
+	Column     int        `json:"column"`
+	Detector   string     `json:"detector"`
+	Confidence Confidence `json:"confidence"`
+	Preview    string     `json:"preview"` // The line with the secret redacted
+}
+
+// Scanner walks directories looking for secrets
+type Scanner struct {
+	Detectors        []*Detector
+	RespectGitignore bool
+	MaxFileSize      int64 // 0 uses the default (5 MiB)
+
+	literals *literalMatcher
+}
+
+// NewScanner creates a scanner with the given detectors
+func NewScanner(detectors []*Detector) *Scanner {
+	return &Scanner{Detectors: detectors}
+}
+
+// AddLiterals makes the scanner report exact host secret values (and their
+// encodings) as high-confidence host_secret:<label> findings
+func (s *Scanner) AddLiterals(values map[string]string) {
+	forms := make(map[string]string)
+	for label, value := range values {
+		if len(value) < minLiteralLength {
+			continue
+		}
+		for _, f := range literalEncodings(value) {
+			forms[f] = label
+		}
+	}
+	s.literals = newLiteralMatcher(forms)
+}
+
+// Scan walks each root and returns findings with paths relative to base
+// Missing roots are skipped
+func (s *Scanner) Scan(base string, roots ...string) ([]Finding, error) {
+	var findings []Finding
+	for _, root := range roots {
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		found, err := s.scanRoot(base, root)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+func (s *Scanner) scanRoot(base, root string) ([]Finding, error) {
+	maxSize := s.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxScanSize
+	}
+
+	var ignore gitignore
+	var findings []Finding
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are skipped, not fatal
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		relSlash := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if s.RespectGitignore {
+				if rel != "." && ignore.ignored(relSlash, true) {
+					return filepath.SkipDir
+				}
+				ignore.load(root, rel)
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if s.RespectGitignore && ignore.ignored(relSlash, false) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil || info.Size() > maxSize {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+
+		display, err := filepath.Rel(base, path)
+		if err != nil {
+			display = path
+		}
+		findings = append(findings, s.scanContent(filepath.ToSlash(display), string(data))...)
+		return nil
+	})
+
+	return findings, err
+}
+
+// scanContent returns findings for one file's content
+func (s *Scanner) scanContent(path, content string) []Finding {
+	type hit struct {
+		start, end int
+		detector   string
+		confidence Confidence
+	}
+
+	var hits []hit
+	for _, lm := range s.literals.find(content) {
+		hits = append(hits, hit{lm.start, lm.end, "host_secret:" + lm.label, ConfidenceHigh})
+	}
+	for _, d := range s.Detectors {
+		for _, sp := range d.find(content) {
+			hits = append(hits, hit{sp[0], sp[1], d.Name, d.Confidence})
+		}
+	}
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].start < hits[b].start })
+
+	// A host value that also matches a detector is reported once, as the host value
+	deduped := hits[:0]
+	for _, h := range hits {
+		if len(deduped) > 0 && h.start == deduped[len(deduped)-1].start {
+			continue
+		}
+		deduped = append(deduped, h)
+	}
+	hits = deduped
+
+	// Redacts any other secrets sharing a line with a finding
+	preview := &Redactor{detectors: s.Detectors, literals: s.literals}
+
+	findings := make([]Finding, 0, len(hits))
+	for _, h := range hits {
+		line := strings.Count(content[:h.start], "\n") + 1
+		lineStart := strings.LastIndexByte(content[:h.start], '\n') + 1
+		lineEnd := strings.IndexByte(content[h.start:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += h.start
+		}
+
+		// Preview the line with this secret masked; a multi-line match
+		// (PEM block) only shows its first line
+		end := min(h.end, lineEnd)
+		text := content[lineStart:h.start] + "[REDACTED:" + h.detector + "]" + content[end:lineEnd]
+		text = strings.TrimSpace(preview.Redact(text))
+		if len(text) > maxPreviewLength {
+			// Cut on a rune boundary so the preview stays valid UTF-8
+			cut := maxPreviewLength
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "..."
+		}
+
+		findings = append(findings, Finding{
+			Path:       path,
+			Line:       line,
+			Column:     h.start - lineStart + 1,
+			Detector:   h.detector,
+			Confidence: h.confidence,
+			Preview:    text,
+		})
+	}
+	return findings
+}
+
+// isBinary reports whether data looks like a binary file
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
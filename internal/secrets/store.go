@@ -0,0 +1,479 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidsenack/agentbox/internal/config"
+)
+
+const (
+	storeVersion    = 1
+	storeIterations = 600000
+	storeCheckValue = "agentbox-secret-store"
+
+	keySourcePassphrase = "passphrase"
+	keySourceKeyfile    = "keyfile"
+)
+
+// RefPrefix marks a config value that refers to the secret store
+const RefPrefix = "secret://"
+
+// ErrSecretNotFound is returned when a store has no secret by that name
+var ErrSecretNotFound = errors.New("secret not found")
+
+// namePattern is the syntax of secret names: env var names, which are also
+// safe as file names in the sandbox's secrets dir
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidName reports whether name can name a secret, in the store or in
+// the sandbox
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Store is an encrypted secret store backed by a JSON file
+// Values are sealed with AES-256-GCM under a key derived (PBKDF2-SHA256)
+// from AGENTBOX_PASSPHRASE or, if unset when the store is created, a
+// random host keyfile in the agentbox config directory
+type Store struct {
+	path string
+	file storeFile
+	key  []byte
+}
+
+// SecretInfo describes a stored secret without its value
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	RotatedAt time.Time `json:"rotated_at,omitempty"`
+}
+
+// storeFile is the on-disk format
+type storeFile struct {
+	Version    int                    `json:"version"`
+	KeySource  string                 `json:"key_source"`
+	Salt       []byte                 `json:"salt"`
+	Iterations int                    `json:"iterations"`
+	Check      []byte                 `json:"check"`
+	Secrets    map[string]storedValue `json:"secrets"`
+}
+
+type storedValue struct {
+	Sealed    []byte    `json:"sealed"` // nonce || ciphertext
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	RotatedAt time.Time `json:"rotated_at,omitempty"`
+}
+
+// GlobalStorePath returns the path of the store shared by all projects
+func GlobalStorePath() (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secrets", "global.json"), nil
+}
+
+// ProjectStorePath returns the path of a project's store
+// Projects are keyed by name, like their VMs
+func ProjectStorePath(project string) (string, error) {
+	dir, err := config.UserDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secrets", "projects", filepath.Base(project)+".json"), nil
+}
+
+// OpenStore opens the store at path, creating an empty one in memory if it
+// doesn't exist yet (it is written on Save)
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, s.init()
+	case err != nil:
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.file); err != nil {
+		return nil, fmt.Errorf("failed to parse secret store %s: %w", path, err)
+	}
+	if s.file.Version != storeVersion {
+		return nil, fmt.Errorf("unsupported secret store version %d", s.file.Version)
+	}
+	if s.file.Secrets == nil {
+		s.file.Secrets = make(map[string]storedValue)
+	}
+
+	if s.key, err = deriveKey(s.file.KeySource, s.file.Salt, s.file.Iterations); err != nil {
+		return nil, err
+	}
+	check, err := s.open(s.file.Check, "")
+	if err != nil || check != storeCheckValue {
+		return nil, fmt.Errorf("cannot unlock secret store %s: wrong passphrase or keyfile", path)
+	}
+	return s, nil
+}
+
+// init sets up a new store with a fresh salt and key
+func (s *Store) init() error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	source := keySourceKeyfile
+	if os.Getenv("AGENTBOX_PASSPHRASE") != "" {
+		source = keySourcePassphrase
+	}
+
+	s.file = storeFile{
+		Version:    storeVersion,
+		KeySource:  source,
+		Salt:       salt,
+		Iterations: storeIterations,
+		Secrets:    make(map[string]storedValue),
+	}
+
+	var err error
+	if s.key, err = deriveKey(source, salt, storeIterations); err != nil {
+		return err
+	}
+	s.file.Check, err = s.seal(storeCheckValue, "")
+	return err
+}
+
+// Get returns a secret's value
+func (s *Store) Get(name string) (string, error) {
+	v, ok := s.file.Secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return s.open(v.Sealed, name)
+}
+
+// Set stores a secret, creating or replacing it
+func (s *Store) Set(name, value string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid secret name %q (use letters, digits and _, not starting with a digit)", name)
+	}
+
+	sealed, err := s.seal(value, name)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	v, ok := s.file.Secrets[name]
+	if !ok {
+		v.CreatedAt = now
+	}
+	v.Sealed = sealed
+	v.UpdatedAt = now
+	s.file.Secrets[name] = v
+	return nil
+}
+
+// Rotate replaces an existing secret's value and records the rotation
+func (s *Store) Rotate(name, value string) error {
+	if _, ok := s.file.Secrets[name]; !ok {
+		return ErrSecretNotFound
+	}
+	if err := s.Set(name, value); err != nil {
+		return err
+	}
+	v := s.file.Secrets[name]
+	v.RotatedAt = v.UpdatedAt
+	s.file.Secrets[name] = v
+	return nil
+}
+
+// Delete removes a secret; returns false if it didn't exist
+func (s *Store) Delete(name string) bool {
+	if _, ok := s.file.Secrets[name]; !ok {
+		return false
+	}
+	delete(s.file.Secrets, name)
+	return true
+}
+
+// List returns stored secrets sorted by name
+func (s *Store) List() []SecretInfo {
+	infos := make([]SecretInfo, 0, len(s.file.Secrets))
+	for name, v := range s.file.Secrets {
+		infos = append(infos, SecretInfo{
+			Name:      name,
+			CreatedAt: v.CreatedAt,
+			UpdatedAt: v.UpdatedAt,
+			RotatedAt: v.RotatedAt,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Save writes the store atomically with mode 0600
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".store-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// seal encrypts value, binding it to the secret name
+func (s *Store) seal(value, name string) ([]byte, error) {
+	gcm, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, []byte(value), []byte(name)), nil
+}
+
+// open decrypts a sealed value for the given secret name
+func (s *Store) open(sealed []byte, name string) (string, error) {
+	gcm, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("corrupt secret %q", name)
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %q: %w", name, err)
+	}
+	return string(plain), nil
+}
+
+func (s *Store) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the store key from the passphrase or host keyfile
+func deriveKey(source string, salt []byte, iterations int) ([]byte, error) {
+	var material string
+	switch source {
+	case keySourcePassphrase:
+		material = os.Getenv("AGENTBOX_PASSPHRASE")
+		if material == "" {
+			return nil, fmt.Errorf("secret store is passphrase-protected: set AGENTBOX_PASSPHRASE")
+		}
+	case keySourceKeyfile:
+		key, err := loadKeyfile()
+		if err != nil {
+			return nil, err
+		}
+		material = base64.StdEncoding.EncodeToString(key)
+	default:
+		return nil, fmt.Errorf("unknown secret store key source %q", source)
+	}
+	return pbkdf2.Key(sha256.New, material, salt, iterations, 32)
+}
+
+// loadKeyfile reads the host keyfile, creating it on first use
+// AGENTBOX_KEYFILE overrides the default location in the config directory
+func loadKeyfile() ([]byte, error) {
+	path := os.Getenv("AGENTBOX_KEYFILE")
+	if path == "" {
+		dir, err := config.UserDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "keyfile")
+	}
+
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) < 32 {
+			return nil, fmt.Errorf("keyfile %s is too short", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			// Another process created it first
+			return loadKeyfile()
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Write(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsRef reports whether a config value refers to the secret store
+func IsRef(ref string) bool {
+	return strings.HasPrefix(ref, RefPrefix)
+}
+
+// ParseEnvRef splits an allowed_env_vars entry into the guest var name and
+// the reference to resolve: "VAR" -> (VAR, VAR), "secret://name" ->
+// (name, secret://name), "VAR=secret://name" -> (VAR, secret://name)
+func ParseEnvRef(entry string) (string, string) {
+	if name, ref, ok := strings.Cut(entry, "="); ok {
+		return name, ref
+	}
+	if IsRef(entry) {
+		return strings.TrimPrefix(entry, RefPrefix), entry
+	}
+	return entry, entry
+}
+
+// Resolver looks up config values that may refer to the secret store
+// secret://name checks the project store, then the global one; any other
+// value is read from the host environment. Stores are opened on first use
+// and cached until Refresh.
+type Resolver struct {
+	mu      sync.Mutex
+	paths   []string
+	stores  map[string]*Store
+	missing map[string]bool
+}
+
+// NewResolver creates a resolver for a project
+func NewResolver(project string) (*Resolver, error) {
+	projectPath, err := ProjectStorePath(project)
+	if err != nil {
+		return nil, err
+	}
+	globalPath, err := GlobalStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return NewResolverWithPaths(projectPath, globalPath), nil
+}
+
+// NewResolverWithPaths creates a resolver that checks the given store files
+// in order
+func NewResolverWithPaths(paths ...string) *Resolver {
+	r := &Resolver{paths: paths}
+	r.Refresh()
+	return r
+}
+
+// Refresh drops cached stores so the next lookup sees rotated values
+func (r *Resolver) Refresh() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stores = make(map[string]*Store)
+	r.missing = make(map[string]bool)
+}
+
+// Lookup resolves a reference, returning "" if it isn't set
+// An error means a store exists but couldn't be read or unlocked
+func (r *Resolver) Lookup(ref string) (string, error) {
+	if !IsRef(ref) {
+		return os.Getenv(ref), nil
+	}
+	name := strings.TrimPrefix(ref, RefPrefix)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, path := range r.paths {
+		store, err := r.open(path)
+		if err != nil {
+			return "", err
+		}
+		if store == nil {
+			continue
+		}
+		val, err := store.Get(name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		return val, err
+	}
+	return "", nil
+}
+
+// Value is Lookup without the error, for callers that treat an unreadable
+// store like an unset secret
+func (r *Resolver) Value(ref string) string {
+	val, _ := r.Lookup(ref)
+	return val
+}
+
+// open returns the cached store at path, or nil if there is no such file
+// Missing stores aren't created, so resolving never writes a keyfile
+func (r *Resolver) open(path string) (*Store, error) {
+	if store, ok := r.stores[path]; ok {
+		return store, nil
+	}
+	if r.missing[path] {
+		return nil, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		r.missing[path] = true
+		return nil, nil
+	}
+	store, err := OpenStore(path)
+	if err != nil {
+		return nil, err
+	}
+	r.stores[path] = store
+	return store, nil
+}
@@ -0,0 +1,200 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AGENTBOX_CONFIG_DIR", dir)
+	t.Setenv("AGENTBOX_PASSPHRASE", "correct horse battery staple")
+
+	path := filepath.Join(dir, "store.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	if err := s.Set("OPENAI_API_KEY", "sk-test-value-123"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("store mode = %o, want 600", info.Mode().Perm())
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "sk-test-value-123") {
+		t.Error("store file contains the plaintext value")
+	}
+
+	s, err = OpenStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := s.Get("OPENAI_API_KEY")
+	if err != nil || got != "sk-test-value-123" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if _, err := s.Get("MISSING"); err != ErrSecretNotFound {
+		t.Errorf("Get(MISSING) error = %v, want ErrSecretNotFound", err)
+	}
+
+	if err := s.Rotate("OPENAI_API_KEY", "sk-rotated-456"); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	list := s.List()
+	if len(list) != 1 || list[0].RotatedAt.IsZero() {
+		t.Errorf("List = %+v, want one rotated secret", list)
+	}
+	if err := s.Rotate("MISSING", "x"); err != ErrSecretNotFound {
+		t.Errorf("Rotate(MISSING) error = %v, want ErrSecretNotFound", err)
+	}
+
+	t.Setenv("AGENTBOX_PASSPHRASE", "wrong")
+	if _, err := OpenStore(path); err == nil {
+		t.Error("OpenStore with the wrong passphrase succeeded")
+	}
+}
+
+func TestStoreKeyfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AGENTBOX_CONFIG_DIR", dir)
+	t.Setenv("AGENTBOX_PASSPHRASE", "")
+
+	path := filepath.Join(dir, "store.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	if err := s.Set("TOKEN", "value-from-keyfile"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "keyfile"))
+	if err != nil {
+		t.Fatalf("keyfile not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("keyfile mode = %o, want 600", info.Mode().Perm())
+	}
+
+	s, err = OpenStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, _ := s.Get("TOKEN"); got != "value-from-keyfile" {
+		t.Errorf("Get = %q", got)
+	}
+
+	// A different keyfile can't unlock it
+	other := filepath.Join(t.TempDir(), "keyfile")
+	os.WriteFile(other, []byte(strings.Repeat("k", 32)), 0600)
+	t.Setenv("AGENTBOX_KEYFILE", other)
+	if _, err := OpenStore(path); err == nil {
+		t.Error("OpenStore with another keyfile succeeded")
+	}
+}
+
+func TestStoreInvalidName(t *testing.T) {
+	t.Setenv("AGENTBOX_CONFIG_DIR", t.TempDir())
+	t.Setenv("AGENTBOX_PASSPHRASE", "pass")
+
+	s, err := OpenStore(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Names become env var and file names in the sandbox
+	for _, name := range []string{"", "1ABC", "has space", "a/b", "openai.key", "gh-token"} {
+		if err := s.Set(name, "v"); err == nil {
+			t.Errorf("Set(%q) succeeded", name)
+		}
+	}
+	if err := s.Set("openai_key", "v"); err != nil {
+		t.Errorf("Set(openai_key) failed: %v", err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AGENTBOX_CONFIG_DIR", dir)
+	t.Setenv("AGENTBOX_PASSPHRASE", "pass")
+	t.Setenv("PLAIN_VAR", "from-env")
+
+	projectPath := filepath.Join(dir, "project.json")
+	globalPath := filepath.Join(dir, "global.json")
+
+	global, _ := OpenStore(globalPath)
+	global.Set("shared", "global-value")
+	global.Set("overridden", "global-override")
+	if err := global.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolverWithPaths(projectPath, globalPath)
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"PLAIN_VAR", "from-env"},
+		{"UNSET_VAR", ""},
+		{"secret://shared", "global-value"},
+		{"secret://overridden", "global-override"},
+		{"secret://missing", ""},
+	}
+	for _, tt := range tests {
+		got, err := r.Lookup(tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", tt.ref, got, err, tt.want)
+		}
+	}
+
+	// The project store wins once it exists, after a refresh
+	project, _ := OpenStore(projectPath)
+	project.Set("overridden", "project-value")
+	if err := project.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Value("secret://overridden"); got != "global-override" {
+		t.Errorf("before Refresh = %q, want cached global value", got)
+	}
+	r.Refresh()
+	if got := r.Value("secret://overridden"); got != "project-value" {
+		t.Errorf("after Refresh = %q, want project-value", got)
+	}
+
+	// Resolving never creates a keyfile
+	if _, err := os.Stat(filepath.Join(dir, "keyfile")); !os.IsNotExist(err) {
+		t.Error("resolver created a keyfile")
+	}
+}
+
+func TestParseEnvRef(t *testing.T) {
+	tests := []struct {
+		entry    string
+		wantName string
+		wantRef  string
+	}{
+		{"OPENAI_API_KEY", "OPENAI_API_KEY", "OPENAI_API_KEY"},
+		{"secret://GITHUB_TOKEN", "GITHUB_TOKEN", "secret://GITHUB_TOKEN"},
+		{"GH_TOKEN=secret://github", "GH_TOKEN", "secret://github"},
+	}
+	for _, tt := range tests {
+		name, ref := ParseEnvRef(tt.entry)
+		if name != tt.wantName || ref != tt.wantRef {
+			t.Errorf("ParseEnvRef(%q) = %q, %q; want %q, %q", tt.entry, name, ref, tt.wantName, tt.wantRef)
+		}
+	}
+}
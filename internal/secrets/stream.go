@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"io"
+)
+
+// DefaultLookahead is how many trailing bytes a stream holds back so a
+// secret split across writes can still be matched
+const DefaultLookahead = 4096
+
+// streamState buffers the tail of a stream between chunks
+type streamState struct {
+	redactor  *Redactor
+	lookahead int
+	buf       []byte
+}
+
+// process appends a chunk and returns the redacted output that is safe to
+// emit. The last lookahead bytes are held back, and the cut point is moved
+// back so it never splits a match. If final is set everything is flushed.
+func (s *streamState) process(chunk []byte, final bool) []byte {
+	s.buf = append(s.buf, chunk...)
+
+	if final {
+		out := s.redactor.Redact(string(s.buf))
+		s.buf = nil
+		return []byte(out)
+	}
+
+	if len(s.buf) <= s.lookahead {
+		return nil
+	}
+
+	cut := len(s.buf) - s.lookahead
+	spans := s.redactor.spans(string(s.buf))
+	for moved := true; moved; {
+		moved = false
+		for _, sp := range spans {
+			// A match reaching the cut (or the end of the buffer) may
+			// continue in the next chunk - hold all of it back
+			if sp[0] < cut && sp[1] >= cut {
+				cut = sp[0]
+				moved = true
+			}
+		}
+	}
+
+	// Bound the buffer: a match longer than the lookahead is emitted anyway
+	if cut <= 0 {
+		if len(s.buf) < 2*s.lookahead {
+			return nil
+		}
+		cut = len(s.buf) - s.lookahead
+	}
+
+	out := s.redactor.Redact(string(s.buf[:cut]))
+	s.buf = append(s.buf[:0], s.buf[cut:]...)
+	return []byte(out)
+}
+
+// redactWriter redacts data written through it
+type redactWriter struct {
+	w     io.Writer
+	state streamState
+}
+
+// Writer returns a writer that redacts secrets before writing to w
+// Matches split across writes are caught; the final bytes are only written
+// on Close, which does not close w. Not safe for concurrent use.
+func (r *Redactor) Writer(w io.Writer) io.WriteCloser {
+	return &redactWriter{
+		w:     w,
+		state: streamState{redactor: r, lookahead: DefaultLookahead},
+	}
+}
+
+// Write buffers p and writes any redacted output that is ready
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if out := rw.state.process(p, false); len(out) > 0 {
+		if _, err := rw.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close flushes the held-back tail
+func (rw *redactWriter) Close() error {
+	if out := rw.state.process(nil, true); len(out) > 0 {
+		if _, err := rw.w.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactReader redacts data read through it
+type redactReader struct {
+	src   io.Reader
+	state streamState
+	chunk []byte
+	out   []byte
+	err   error
+}
+
+// Reader returns a reader that redacts secrets read from src
+// The held-back tail is flushed when src returns EOF or an error
+func (r *Redactor) Reader(src io.Reader) io.Reader {
+	return &redactReader{
+		src:   src,
+		state: streamState{redactor: r, lookahead: DefaultLookahead},
+		chunk: make([]byte, 32*1024),
+	}
+}
+
+// Read returns redacted bytes from the underlying reader
+func (rr *redactReader) Read(p []byte) (int, error) {
+	for len(rr.out) == 0 {
+		if rr.err != nil {
+			return 0, rr.err
+		}
+
+		n, err := rr.src.Read(rr.chunk)
+		if err != nil {
+			rr.err = err
+		}
+		rr.out = append(rr.out, rr.state.process(rr.chunk[:n], err != nil)...)
+	}
+
+	n := copy(p, rr.out)
+	rr.out = rr.out[n:]
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const streamKey = "sk-ant-REDACTED"
+
+func TestWriterSplitAcrossWrites(t *testing.T) {
+	r := NewRedactor([]string{`sk-ant-[a-zA-Z0-9-]+`})
+	input := "before " + streamKey + " after\n"
+
+	// Split the input at every offset, including inside the key
+	for i := 0; i <= len(input); i++ {
+		var out bytes.Buffer
+		w := r.Writer(&out)
+		w.Write([]byte(input[:i]))
+		w.Write([]byte(input[i:]))
+		if err := w.Close(); err != nil {
+			t.Fatalf("close failed: %v", err)
+		}
+
+		if strings.Contains(out.String(), streamKey) {
+			t.Fatalf("split at %d: key leaked in %q", i, out.String())
+		}
+		if want := r.Redact(input); out.String() != want {
+			t.Fatalf("split at %d: got %q, want %q", i, out.String(), want)
+		}
+	}
+}
+
+func TestWriterHoldsBackUntilClose(t *testing.T) {
+	r := NewRedactor([]string{`sk-ant-[a-zA-Z0-9-]+`})
+
+	var out bytes.Buffer
+	w := r.Writer(&out)
+	w.Write([]byte("partial sk-ant-api03"))
+
+	if out.Len() != 0 {
+		t.Errorf("short input should be held back, got %q", out.String())
+	}
+
+	w.Close()
+	if !strings.Contains(out.String(), "[REDACTED]") {
+		t.Errorf("expected flush on close to redact, got %q", out.String())
+	}
+}
+
+func TestWriterManySmallWrites(t *testing.T) {
+	r := NewRedactor([]string{`sk-ant-[a-zA-Z0-9-]+`})
+	input := strings.Repeat("log line without secrets\n", 500) + streamKey + "\n" + strings.Repeat("more output\n", 500)
+
+	var out bytes.Buffer
+	w := r.Writer(&out)
+	for i := 0; i < len(input); i += 7 {
+		w.Write([]byte(input[i:min(i+7, len(input))]))
+	}
+	w.Close()
+
+	if strings.Contains(out.String(), streamKey) {
+		t.Error("key leaked through small writes")
+	}
+	if out.String() != r.Redact(input) {
+		t.Error("streamed output differs from whole-string redaction")
+	}
+}
+
+func TestStreamBoundedLookahead(t *testing.T) {
+	r := NewRedactor([]string{`x+`})
+	state := streamState{redactor: r, lookahead: 16}
+
+	// A match that never ends must not grow the buffer without bound
+	for i := 0; i < 100; i++ {
+		state.process([]byte(strings.Repeat("x", 8)), false)
+		if len(state.buf) > 2*state.lookahead+8 {
+			t.Fatalf("buffer grew to %d bytes", len(state.buf))
+		}
+	}
+}
+
+func TestReaderOneByteReads(t *testing.T) {
+	r := NewRedactor([]string{`sk-ant-[a-zA-Z0-9-]+`})
+	input := "token: " + streamKey + "\n"
+
+	rd := r.Reader(iotest.OneByteReader(strings.NewReader(input)))
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	if strings.Contains(string(data), streamKey) {
+		t.Errorf("key leaked in %q", data)
+	}
+	if string(data) != r.Redact(input) {
+		t.Errorf("got %q, want %q", data, r.Redact(input))
+	}
+}
+
+func TestReaderPropagatesError(t *testing.T) {
+	r := NewRedactor([]string{`sk-ant-[a-zA-Z0-9-]+`})
+	src := io.MultiReader(strings.NewReader("data "), iotest.ErrReader(io.ErrUnexpectedEOF))
+
+	data, err := io.ReadAll(r.Reader(src))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected ErrUnexpectedEOF, got %v", err)
+	}
+	if string(data) != "data " {
+		t.Errorf("expected buffered data to be flushed, got %q", data)
+	}
+}
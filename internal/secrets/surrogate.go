@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Surrogate is a random per-session placeholder that stands in for a secret
+// The guest only ever sees the placeholder; the proxy swaps in the real value
+// for requests to the secret's hosts
+type Surrogate struct {
+	Name        string   // Env var name (e.g., OPENAI_API_KEY)
+	Placeholder string   // Value handed to the guest
+	Value       string   // Real secret, kept on the host
+	Hosts       []string // Hosts allowed to receive the real value
+}
+
+// NewSurrogate creates a surrogate with a fresh random placeholder
+func NewSurrogate(name, value string, hosts []string) (*Surrogate, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate placeholder: %w", err)
+	}
+
+	lower := make([]string, len(hosts))
+	for i, h := range hosts {
+		lower[i] = strings.ToLower(h)
+	}
+
+	return &Surrogate{
+		Name:        name,
+		Placeholder: placeholderPrefix(value) + "agentbox-" + hex.EncodeToString(buf),
+		Value:       value,
+		Hosts:       lower,
+	}, nil
+}
+
+// AllowsHost checks if the real value may be sent to the given host
+func (s *Surrogate) AllowsHost(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	for _, h := range s.Hosts {
+		if h == hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// knownPrefixes are well-known key prefixes kept on placeholders so tools
+// that sanity-check the key format still start
+var knownPrefixes = []string{
+	"sk-ant-", "sk-proj-", "sk-",
+	"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_",
+	"glpat-",
+	"xoxb-", "xoxp-", "xapp-",
+	"hf_", "npm_", "pypi-",
+}
+
+// placeholderPrefix returns the longest known prefix of value. Nothing else
+// of the value is kept, since the guest sees the placeholder
+func placeholderPrefix(value string) string {
+	prefix := ""
+	for _, p := range knownPrefixes {
+		if strings.HasPrefix(value, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,134 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Session marks one agentbox process attached to a box
+// Each session is a file named after its PID in the box's sessions dir,
+// holding the process's start time so a reused PID isn't mistaken for it
+type Session struct {
+	dir  string
+	path string
+}
+
+// Dir returns the sessions directory for a project's .agentbox state dir
+func Dir(stateDir string) string {
+	return filepath.Join(stateDir, "sessions")
+}
+
+// Begin records a session for the current process
+func Begin(dir string) (*Session, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create sessions dir: %w", err)
+	}
+
+	// Without a start time, Active falls back to checking the PID is alive
+	started, _ := startTime(os.Getpid())
+	path := filepath.Join(dir, strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(path, []byte(started), 0600); err != nil {
+		return nil, fmt.Errorf("failed to record session: %w", err)
+	}
+
+	return &Session{dir: dir, path: path}, nil
+}
+
+// End removes the session and reports whether it was the last active one
+func (s *Session) End() (bool, error) {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	active, err := Active(s.dir)
+	if err != nil {
+		return false, err
+	}
+	return len(active) == 0, nil
+}
+
+// Active returns the PIDs of live sessions, pruning ones whose process died
+// (or whose PID now belongs to a different process)
+func Active(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		recorded, err := os.ReadFile(path)
+		if err != nil || !running(pid, string(recorded)) {
+			os.Remove(path)
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// running checks that pid is still the process that recorded started
+func running(pid int, started string) bool {
+	current, err := startTime(pid)
+	if err != nil {
+		// Either gone, or this host can't tell; only a session that
+		// couldn't record its start time falls back to the PID
+		return started == "" && alive(pid)
+	}
+	return current == started
+}
+
+// startTime identifies a process instance: PIDs are reused, start times
+// aren't. Linux reads /proc; elsewhere ps reports it.
+func startTime(pid int) (string, error) {
+	if goruntime.GOOS == "linux" {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			return "", err
+		}
+		// The command name is in parens and may contain spaces; the start
+		// time is the 20th field after it
+		i := bytes.LastIndexByte(data, ')')
+		fields := strings.Fields(string(data[i+1:]))
+		if i < 0 || len(fields) < 20 {
+			return "", fmt.Errorf("unexpected /proc/%d/stat", pid)
+		}
+		return fields[19], nil
+	}
+
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	started := strings.TrimSpace(string(out))
+	if started == "" {
+		return "", fmt.Errorf("no process %d", pid)
+	}
+	return started, nil
+}
+
+// alive checks if a process exists
+func alive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
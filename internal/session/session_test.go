@@ -0,0 +1,100 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestBeginEnd(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sessions")
+
+	sess, err := Begin(dir)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	active, err := Active(dir)
+	if err != nil {
+		t.Fatalf("Active failed: %v", err)
+	}
+	if len(active) != 1 || active[0] != os.Getpid() {
+		t.Errorf("expected current pid active, got %v", active)
+	}
+
+	last, err := sess.End()
+	if err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	if !last {
+		t.Error("expected last session")
+	}
+}
+
+func TestEndWithOtherSessions(t *testing.T) {
+	dir := t.TempDir()
+
+	// Another live process (our parent) holds a session
+	started, err := startTime(os.Getppid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(os.Getppid())), []byte(started), 0600); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	sess, err := Begin(dir)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	last, err := sess.End()
+	if err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	if last {
+		t.Error("should not be last while another session is active")
+	}
+}
+
+func TestActivePrunesDeadSessions(t *testing.T) {
+	dir := t.TempDir()
+
+	// PIDs this large aren't valid on Linux or macOS
+	stale := filepath.Join(dir, "99999999")
+	if err := os.WriteFile(stale, nil, 0600); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	active, err := Active(dir)
+	if err != nil {
+		t.Fatalf("Active failed: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("expected no active sessions, got %v", active)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale session file should be removed")
+	}
+}
+
+func TestActivePrunesReusedPID(t *testing.T) {
+	dir := t.TempDir()
+
+	// A live PID recorded by a process that has since exited
+	reused := filepath.Join(dir, strconv.Itoa(os.Getppid()))
+	if err := os.WriteFile(reused, []byte("1"), 0600); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	active, err := Active(dir)
+	if err != nil {
+		t.Fatalf("Active failed: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("a reused PID must not count as a session, got %v", active)
+	}
+	if _, err := os.Stat(reused); !os.IsNotExist(err) {
+		t.Error("session file with a different start time should be removed")
+	}
+}
@@ -0,0 +1,161 @@
+// Package snapshot keeps the metadata for saved box states
+// Each snapshot is a directory under .agentbox/snapshots holding a
+// snapshot.json and whatever the runtime saved (e.g., Lima's VM disks).
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// metaFile is the metadata file in each snapshot directory
+const metaFile = "snapshot.json"
+
+// ErrNotFound is returned when a snapshot doesn't exist
+var ErrNotFound = errors.New("snapshot not found")
+
+// namePattern keeps snapshot names safe as directory names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot describes a saved box state
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Note    string    `json:"note,omitempty"`
+	Size    int64     `json:"size"`    // Bytes used on disk
+	Runtime string    `json:"runtime"` // Runtime that saved it; only it can restore
+}
+
+// Dir returns the snapshots directory for a project's .agentbox state dir
+func Dir(stateDir string) string {
+	return filepath.Join(stateDir, "snapshots")
+}
+
+// Path returns the directory holding one snapshot's data
+func Path(dir, name string) string {
+	return filepath.Join(dir, name)
+}
+
+// ValidateName checks that a snapshot name is usable
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// Get reads one snapshot's metadata
+func Get(dir, name string) (*Snapshot, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(Path(dir, name), metaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	return &s, nil
+}
+
+// List returns all snapshots, oldest first
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		s, err := Get(dir, e.Name())
+		if err != nil {
+			// Half-written snapshots have no metadata yet
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		snapshots = append(snapshots, *s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+// Write records a snapshot's metadata, measuring the size of its data
+// Write it last, so a snapshot only lists once its data is complete
+func Write(dir string, s *Snapshot) error {
+	path := Path(dir, s.Name)
+	size, err := DiskUsage(path)
+	if err != nil {
+		return fmt.Errorf("failed to measure snapshot: %w", err)
+	}
+	s.Size = size
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(path, metaFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes a snapshot and its data
+func Remove(dir, name string) error {
+	if _, err := Get(dir, name); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(Path(dir, name)); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s: %w", name, err)
+	}
+	return nil
+}
+
+// DiskUsage returns the bytes allocated to the files under path
+// VM disks are sparse, so this is far less than their apparent size
+func DiskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			total += st.Blocks * 512
+		} else {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,76 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteGetListRemove(t *testing.T) {
+	dir := Dir(t.TempDir())
+
+	for i, name := range []string{"newer", "older"} {
+		path := Path(dir, name)
+		if err := os.MkdirAll(path, 0700); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(path, "diffdisk"), make([]byte, 8192), 0600)
+
+		s := &Snapshot{
+			Name:    name,
+			Created: time.Date(2026, 1, 2-i, 0, 0, 0, 0, time.UTC),
+			Note:    "note " + name,
+			Runtime: "fake",
+		}
+		if err := Write(dir, s); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if s.Size <= 0 {
+			t.Errorf("size not measured: %d", s.Size)
+		}
+	}
+
+	// A save that never finished has no metadata and is not listed
+	os.MkdirAll(Path(dir, "partial"), 0700)
+
+	got, err := Get(dir, "newer")
+	if err != nil || got.Note != "note newer" || got.Runtime != "fake" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	list, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "older" || list[1].Name != "newer" {
+		t.Errorf("List = %+v, want older then newer", list)
+	}
+
+	if err := Remove(dir, "older"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get(dir, "older"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removed snapshot still found: %v", err)
+	}
+	if err := Remove(dir, "older"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("removing a missing snapshot = %v", err)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"tooled":     true,
+		"v1.2_base-": true,
+		"":           false,
+		".hidden":    false,
+		"../escape":  false,
+		"a/b":        false,
+		"with space": false,
+	} {
+		if err := ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid=%v", name, err, valid)
+		}
+	}
+}